@@ -4,19 +4,19 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"time"
 
 	"github.com/rasulov-emirlan/topenergy-interview/config"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/domains"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/storage/blob"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/storage/redis"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/transport/httprest"
 	"github.com/rasulov-emirlan/topenergy-interview/pkg/health"
 	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
-	"go.opentelemetry.io/otel"
-	"go.opentelemetry.io/otel/exporters/jaeger"
-	"go.opentelemetry.io/otel/propagation"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/ratelimit"
 )
 
 func main() {
@@ -28,33 +28,164 @@ func main() {
 		panic(err)
 	}
 
-	log, err := logging.NewLogger(cfg.LogLevel)
+	logOpts := []logging.Option{}
+	if cfg.Logging.SamplingThereafter > 0 {
+		logOpts = append(logOpts, logging.WithSampling(cfg.Logging.SamplingInitial, cfg.Logging.SamplingThereafter))
+	}
+	if cfg.Logging.File != "" {
+		logOpts = append(logOpts, logging.WithFile(
+			cfg.Logging.File, cfg.Logging.FileMaxSizeMB, cfg.Logging.FileMaxAgeDays, cfg.Logging.FileMaxBackups,
+		))
+	}
+	log, err := logging.NewLogger(cfg.LogLevel, logOpts...)
 	if err != nil {
 		panic(err)
 	}
+	// Deferred calls run last to first, so the log file is flushed and then closed after the server stops.
+	defer log.Close()
+	defer log.Sync()
+
+	// Meters have to be provided before anything creates instruments.
+	metricsHandler, metricsShutdown, err := PrometheusMeterProvider(cfg)
+	if err != nil {
+		log.Fatal("failed to initialize prometheus meter provider", logging.Error("err", err))
+	}
 
 	repo, err := redis.NewRepoCombiner(ctx, cfg)
 	if err != nil {
 		log.Fatal("failed to initialize redis repo", logging.Error("err", err))
 	}
 
-	doms, err := domains.NewDomainCombiner(domains.CommonDependencies{Log: log}, domains.TasksDependencies{Repo: repo.Tasks()})
+	// Data from before workspaces has nowhere to go without a default workspace, it is left as is.
+	if cfg.Tenancy.DefaultWorkspace != "" {
+		migrated, err := repo.Migrate(ctx, cfg.Tenancy.DefaultWorkspace)
+		if err != nil {
+			log.Fatal("failed to migrate redis data", logging.Error("err", err))
+		}
+		if migrated.Tasks > 0 || migrated.Users > 0 || migrated.APIKeys > 0 {
+			log.Info("moved data created before workspaces into the default workspace",
+				logging.Int("tasks", migrated.Tasks), logging.Int("users", migrated.Users), logging.Int("apikeys", migrated.APIKeys))
+		}
+	}
+
+	policy, err := auth.LoadPolicy(cfg.Auth.PolicyFile)
+	if err != nil {
+		log.Fatal("failed to load authorization policy", logging.Error("err", err))
+	}
+	if cfg.Auth.Disabled {
+		policy = auth.AllowAll
+	}
+
+	var blobs attachments.BlobStore
+	switch cfg.Blob.Backend {
+	case "fs":
+		blobs, err = blob.NewFSStore(cfg.Blob.Dir)
+	case "s3":
+		blobs, err = blob.NewS3Store(blob.S3Options{
+			Endpoint:  cfg.Blob.S3Endpoint,
+			Region:    cfg.Blob.S3Region,
+			Bucket:    cfg.Blob.S3Bucket,
+			AccessKey: cfg.Blob.S3AccessKey,
+			SecretKey: cfg.Blob.S3SecretKey,
+			PathStyle: cfg.Blob.S3PathStyle,
+		})
+	}
+	if err != nil {
+		log.Fatal("failed to initialize blob store", logging.Error("err", err))
+	}
+
+	doms, err := domains.NewDomainCombiner(
+		domains.CommonDependencies{Log: log},
+		domains.TasksDependencies{
+			Repo:           repo.Tasks(),
+			Policy:         policy,
+			MaxTasks:       cfg.Tenancy.MaxTasks,
+			OnParentDelete: cfg.Tasks.OnParentDelete,
+		},
+		domains.AuthDependencies{Repo: repo.APIKeys()},
+		domains.UsersDependencies{Repo: repo.Users(), Policy: policy},
+		domains.CommentsDependencies{Repo: repo.Comments(), Policy: policy},
+		domains.LabelsDependencies{Repo: repo.Labels(), Policy: policy},
+		domains.ProjectsDependencies{Repo: repo.Projects(), Policy: policy},
+		domains.AttachmentsDependencies{
+			Repo:   repo.Attachments(),
+			Blobs:  blobs,
+			Policy: policy,
+			Limits: attachments.Limits{
+				MaxSize:      int64(cfg.Attachments.MaxSizeMB) << 20,
+				AllowedTypes: cfg.Attachments.AllowedTypes,
+			},
+		},
+		domains.RecurringDependencies{
+			Repo:   repo.Recurring(),
+			Locker: repo.Locker(),
+			Policy: policy,
+			Scheduler: recurring.SchedulerOptions{
+				Lookahead:  cfg.Recurring.Lookahead,
+				MaxCatchUp: cfg.Recurring.MaxCatchUp,
+				LockTTL:    cfg.Recurring.LockTTL,
+			},
+		},
+	)
 	if err != nil {
 		log.Fatal("failed to initialize domains", logging.Error("err", err))
 	}
 
-	jeagerShutdown, err := JaegerTraceProvider(cfg)
+	tracesShutdown, err := TraceProvider(ctx, cfg)
 	if err != nil {
-		log.Fatal("failed to initialize jaeger trace provider", logging.Error("err", err))
+		log.Fatal("failed to initialize trace provider", logging.Error("err", err))
+	}
+
+	var limiter ratelimit.Limiter
+	switch cfg.RateLimit.Backend {
+	case "redis":
+		limiter = ratelimit.NewFallback(repo.RateLimiter(), ratelimit.NewMemory(), time.Minute, func(err error, failures int) {
+			log.Error("redis rate limiter failed, falling back to memory", logging.Int("failures", failures), logging.Error("err", err))
+		})
+	case "memory":
+		limiter = ratelimit.NewMemory()
+	}
+
+	monitor, err := health.NewMonitor(
+		health.Options{
+			Timeout:  cfg.Health.Timeout,
+			CacheTTL: cfg.Health.CacheTTL,
+			Liveness: health.Thresholds{
+				MaxGoroutines: cfg.Health.MaxGoroutines,
+				MaxHeapBytes:  uint64(cfg.Health.MaxHeapMB) << 20,
+			},
+		},
+		health.Registration{Name: "redis", Checker: repo.Check, Critical: true},
+	)
+	if err != nil {
+		log.Fatal("failed to initialize health checks", logging.Error("err", err))
+	}
+	if cfg.Health.Interval > 0 {
+		go monitor.Start(ctx, cfg.Health.Interval)
+	}
+	if cfg.Recurring.Interval > 0 {
+		go doms.Scheduler().Start(ctx, cfg.Recurring.Interval)
 	}
 
 	srv := httprest.NewServer(cfg)
 	go func() {
-		if err := srv.Start(log, doms, []health.Checker{repo.Check}); err != nil {
+		err := srv.Start(httprest.Dependencies{
+			Log:         log,
+			Domains:     doms,
+			Health:      monitor,
+			Limiter:     limiter,
+			Idempotency: repo.Idempotency(),
+			Metrics:     metricsHandler,
+		})
+		if err != nil {
 			log.Fatal("failed to start http server", logging.Error("err", err))
 		}
 	}()
 
+	// Migrations ran before the server started, warming up is filling the health check cache.
+	monitor.Checks(ctx)
+	monitor.MarkStarted()
+
 	log.Info("server started", logging.String("port", cfg.Server.Port))
 
 	// graceful shutdown
@@ -72,35 +203,13 @@ func main() {
 		log.Fatal("failed to close redis repo", logging.Error("err", err))
 	}
 
-	if err := jeagerShutdown(ctx); err != nil {
-		log.Fatal("failed to shutdown jaeger trace provider", logging.Error("err", err))
-	}
-
-	log.Info("server stopped")
-}
-
-func JaegerTraceProvider(cfg config.Config) (func(context.Context) error, error) {
-	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.JeagerURL)))
-	if err != nil {
-		return nil, err
+	if err := tracesShutdown(ctx); err != nil {
+		log.Fatal("failed to shutdown trace provider", logging.Error("err", err))
 	}
 
-	envKey := "production"
-	if cfg.Flags.DevMode {
-		envKey = "development"
+	if err := metricsShutdown(ctx); err != nil {
+		log.Fatal("failed to shutdown prometheus meter provider", logging.Error("err", err))
 	}
 
-	tp := sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(exp),
-		sdktrace.WithResource(resource.NewWithAttributes(
-			semconv.SchemaURL,
-			semconv.ServiceNameKey.String(httprest.ServiceName),
-			semconv.DeploymentEnvironmentKey.String(envKey),
-		)),
-	)
-
-	otel.SetTracerProvider(tp)
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
-
-	return tp.Shutdown, nil
+	log.Info("server stopped")
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+
+	"github.com/rasulov-emirlan/topenergy-interview/config"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/transport/httprest"
+)
+
+// TraceProvider sets up the global tracer provider with the exporter picked in config.
+// With the "none" exporter spans are still created, so trace ids propagate, but go nowhere.
+func TraceProvider(ctx context.Context, cfg config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(serviceResource(cfg)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.Tracing.SampleRatio))),
+	}
+
+	exp, err := traceExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+
+	tp := sdktrace.NewTracerProvider(opts...)
+	otel.SetTracerProvider(tp)
+
+	return tp.Shutdown, nil
+}
+
+func traceExporter(ctx context.Context, cfg config.Config) (sdktrace.SpanExporter, error) {
+	t := cfg.Tracing
+
+	switch t.Exporter {
+	case config.TracingExporterOTLPGRPC:
+		opts := []otlptracegrpc.Option{}
+		if t.Endpoint != "" {
+			opts = append(opts, otlptracegrpc.WithEndpoint(t.Endpoint))
+		}
+		if t.Insecure {
+			opts = append(opts, otlptracegrpc.WithInsecure())
+		}
+		return otlptracegrpc.New(ctx, opts...)
+	case config.TracingExporterOTLPHTTP:
+		opts := []otlptracehttp.Option{}
+		if t.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(t.Endpoint))
+		}
+		if t.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		return otlptracehttp.New(ctx, opts...)
+	case config.TracingExporterJaeger:
+		return jaeger.New(jaeger.WithCollectorEndpoint(jaeger.WithEndpoint(cfg.JeagerURL)))
+	case config.TracingExporterStdout:
+		return stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case config.TracingExporterNone:
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown tracing exporter %q", t.Exporter)
+}
+
+// PrometheusMeterProvider returns the handler for /metrics, it also exposes go runtime metrics.
+func PrometheusMeterProvider(cfg config.Config) (http.Handler, func(context.Context) error, error) {
+	registry := prometheus.NewRegistry()
+	exp, err := otelprom.New(otelprom.WithRegisterer(registry))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithReader(exp),
+		sdkmetric.WithResource(serviceResource(cfg)),
+	)
+	otel.SetMeterProvider(mp)
+
+	if err := runtime.Start(runtime.WithMeterProvider(mp)); err != nil {
+		return nil, nil, err
+	}
+
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{}), mp.Shutdown, nil
+}
+
+func serviceResource(cfg config.Config) *resource.Resource {
+	envKey := "production"
+	if cfg.Flags.DevMode {
+		envKey = "development"
+	}
+
+	instanceID := cfg.Service.InstanceID
+	if instanceID == "" {
+		instanceID, _ = os.Hostname()
+	}
+
+	return resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceNameKey.String(httprest.ServiceName),
+		semconv.ServiceVersionKey.String(cfg.Service.Version),
+		semconv.ServiceInstanceIDKey.String(instanceID),
+		semconv.DeploymentEnvironmentKey.String(envKey),
+	)
+}
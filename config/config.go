@@ -1,7 +1,14 @@
 package config
 
 import (
+	"errors"
 	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
@@ -13,6 +20,120 @@ type (
 		AllowedOrigins []string      `env:"ALLOWED_CORS_ORIGINS" env-default:"*"`
 		TimeoutRead    time.Duration `env:"SERVER_READ_TIMEOUT" env-default:"15s"`
 		TimeoutWrite   time.Duration `env:"SERVER_WRITE_TIMEOUT" env-default:"15s"`
+		IdempotencyTTL time.Duration `env:"IDEMPOTENCY_TTL" env-default:"24h"` // how long responses to requests with Idempotency-Key are kept
+		// How long a request with Idempotency-Key may be in progress, retries get 409 until then.
+		// It should be longer than any request takes, a replica that dies mid-request blocks retries for this long.
+		IdempotencyLockTTL time.Duration `env:"IDEMPOTENCY_LOCK_TTL" env-default:"30s"`
+		// RawLegacySunset is the date, as YYYY-MM-DD, after which unversioned routes are removed.
+		RawLegacySunset string `env:"LEGACY_API_SUNSET" env-default:"2027-07-01"`
+		LegacySunset    time.Time
+		CORS            cors
+		// RawTrustedProxies are CIDRs of proxies in front of us that are trusted to tell the client IP
+		// in X-Forwarded-For. Without them the IP of the connection is used and the header is ignored.
+		RawTrustedProxies []string `env:"TRUSTED_PROXIES"`
+		TrustedProxies    []*net.IPNet
+	}
+
+	cors struct {
+		// Origins are taken from server.AllowedOrigins, they can contain "*" and "?" wildcards,
+		// e.g. "https://*.example.com".
+		AllowedMethods   []string      `env:"ALLOWED_CORS_METHODS" env-default:"GET,HEAD,PUT,PATCH,POST,DELETE"`
+		AllowedHeaders   []string      `env:"ALLOWED_CORS_HEADERS" env-default:"Authorization,Content-Type,X-API-Key,X-Workspace-ID,Idempotency-Key,X-Request-ID,X-Checksum-SHA256"`
+		ExposedHeaders   []string      `env:"EXPOSED_CORS_HEADERS" env-default:"RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,Retry-After,Deprecation,Sunset,Link,Idempotent-Replayed,X-Request-ID,X-Checksum-SHA256,Content-Disposition"`
+		AllowCredentials bool          `env:"CORS_ALLOW_CREDENTIALS" env-default:"false"`
+		MaxAge           time.Duration `env:"CORS_MAX_AGE" env-default:"10m"` // how long browsers may cache preflight responses
+	}
+
+	auth struct {
+		Disabled    bool   `env:"AUTH_DISABLED" env-default:"false"`
+		JWTSecret   string `env:"AUTH_JWT_SECRET"`    // shared secret for HS256 tokens
+		JWKSFile    string `env:"AUTH_JWT_JWKS_FILE"` // path to a JWKS file with RS256 public keys
+		JWTIssuer   string `env:"AUTH_JWT_ISSUER"`
+		JWTAudience string `env:"AUTH_JWT_AUDIENCE"`
+		PolicyFile  string `env:"AUTH_POLICY_FILE"` // JSON file with role permissions, built-in policy is used if empty
+	}
+
+	tenancy struct {
+		DefaultWorkspace string `env:"TENANCY_DEFAULT_WORKSPACE" env-default:"default"` // for principals that are not members of any workspace
+		MaxTasks         int    `env:"TENANCY_MAX_TASKS" env-default:"10000"`           // per workspace, 0 means unlimited
+	}
+
+	tasks struct {
+		OnParentDelete string `env:"TASKS_ON_PARENT_DELETE" env-default:"reject"` // reject, orphan or cascade subtasks
+	}
+
+	attachments struct {
+		MaxSizeMB int `env:"ATTACHMENTS_MAX_SIZE_MB" env-default:"25"` // 0 means unlimited
+		// Media types detected from the content, "image/*" allows every image and an empty list allows everything.
+		AllowedTypes []string `env:"ATTACHMENTS_ALLOWED_TYPES" env-default:"image/*,application/pdf,text/plain,application/zip"`
+	}
+
+	blob struct {
+		Backend string `env:"BLOB_BACKEND" env-default:"fs"`           // fs or s3
+		Dir     string `env:"BLOB_DIR" env-default:"data/attachments"` // used by the fs backend
+
+		// Used by the s3 backend, any S3 compatible store works.
+		S3Endpoint  string `env:"BLOB_S3_ENDPOINT" env-default:"https://s3.amazonaws.com"`
+		S3Region    string `env:"BLOB_S3_REGION" env-default:"us-east-1"`
+		S3Bucket    string `env:"BLOB_S3_BUCKET"`
+		S3AccessKey string `env:"BLOB_S3_ACCESS_KEY"`
+		S3SecretKey string `env:"BLOB_S3_SECRET_KEY"`
+		S3PathStyle bool   `env:"BLOB_S3_PATH_STYLE" env-default:"true"` // "<endpoint>/<bucket>" instead of "<bucket>.<endpoint host>"
+	}
+
+	rateLimit struct {
+		Backend string `env:"RATE_LIMIT_BACKEND" env-default:"redis"` // redis, memory or off
+		// Comma separated "<resource>=<rate>/<burst>" rules, where rate is in requests per second.
+		// Resources are paths relative to the api version, "*" applies to all other resources.
+		RawRules string `env:"RATE_LIMIT_RULES" env-default:"tasks=20/40,admin/apikeys=1/5"`
+		Rules    map[string]RateLimitRule
+		// "<rate>/<burst>" for every client IP across all resources, checked before authentication,
+		// so that requests with bad credentials are limited too. Empty turns it off.
+		RawPerIP string `env:"RATE_LIMIT_PER_IP" env-default:"50/100"`
+		PerIP    *RateLimitRule
+	}
+
+	RateLimitRule struct {
+		Rate  float64
+		Burst int
+	}
+
+	service struct {
+		Version    string `env:"SERVICE_VERSION" env-default:"dev"`
+		InstanceID string `env:"SERVICE_INSTANCE_ID"` // hostname is used if empty
+	}
+
+	tracing struct {
+		Exporter    string  `env:"TRACING_EXPORTER" env-default:"jaeger"` // otlp-grpc, otlp-http, jaeger, stdout or none
+		Endpoint    string  `env:"TRACING_ENDPOINT"`                      // host:port of the otlp collector, the exporter's default if empty
+		Insecure    bool    `env:"TRACING_INSECURE" env-default:"false"`  // don't use TLS with the otlp collector
+		SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" env-default:"1"`  // share of traces started here that are sampled
+	}
+
+	healthChecks struct {
+		Timeout  time.Duration `env:"HEALTH_CHECK_TIMEOUT" env-default:"2s"`   // deadline of a single check
+		CacheTTL time.Duration `env:"HEALTH_CHECK_CACHE_TTL" env-default:"5s"` // how long results are reused
+		Interval time.Duration `env:"HEALTH_CHECK_INTERVAL" env-default:"0s"`  // checks run in the background this often, 0 disables
+
+		// Liveness fails above these, 0 disables them.
+		MaxGoroutines int `env:"HEALTH_MAX_GOROUTINES" env-default:"10000"`
+		MaxHeapMB     int `env:"HEALTH_MAX_HEAP_MB" env-default:"0"`
+	}
+
+	recurring struct {
+		Interval   time.Duration `env:"RECURRING_INTERVAL" env-default:"1m"`    // templates are looked at this often, 0 disables the scheduler
+		Lookahead  time.Duration `env:"RECURRING_LOOKAHEAD" env-default:"24h"`  // tasks are created this long before their occurrence
+		MaxCatchUp int           `env:"RECURRING_MAX_CATCH_UP" env-default:"1"` // past occurrences of a template that still get tasks after downtime
+		LockTTL    time.Duration `env:"RECURRING_LOCK_TTL" env-default:"2m"`    // a replica that dies mid run blocks others this long at most
+	}
+
+	logging struct {
+		SamplingInitial    int    `env:"LOG_SAMPLING_INITIAL" env-default:"100"`    // entries with the same message logged every second
+		SamplingThereafter int    `env:"LOG_SAMPLING_THEREAFTER" env-default:"100"` // then only every n-th of them is logged, 0 disables sampling
+		File               string `env:"LOG_FILE"`                                  // logs are also written here if set
+		FileMaxSizeMB      int    `env:"LOG_FILE_MAX_SIZE_MB" env-default:"100"`
+		FileMaxAgeDays     int    `env:"LOG_FILE_MAX_AGE_DAYS" env-default:"7"`
+		FileMaxBackups     int    `env:"LOG_FILE_MAX_BACKUPS" env-default:"5"`
 	}
 
 	flags struct {
@@ -25,12 +146,31 @@ type (
 		RedisURL      string `env:"REDIS_URL"`
 		RedisPassword string `env:"REDIS_PASSWORD"`
 		Server        server
+		Auth          auth
+		Tenancy       tenancy
+		Tasks         tasks
+		Attachments   attachments
+		Blob          blob
+		RateLimit     rateLimit
 		JeagerURL     string `env:"JAEGER_URL" env-default:"http://localhost:14268/api/traces"`
+		Tracing       tracing
+		Health        healthChecks
+		Recurring     recurring
+		Service       service
 		Flags         flags
 		LogLevel      string `env:"LOG_LEVEL" env-default:"debug"`
+		Logging       logging
 	}
 )
 
+const (
+	TracingExporterOTLPGRPC = "otlp-grpc"
+	TracingExporterOTLPHTTP = "otlp-http"
+	TracingExporterJaeger   = "jaeger"
+	TracingExporterStdout   = "stdout"
+	TracingExporterNone     = "none"
+)
+
 func LoadConfig() (Config, error) {
 	var cfg Config
 
@@ -43,19 +183,201 @@ func LoadConfig() (Config, error) {
 		if err := cleanenv.ReadConfig(cfg.Flags.envFilename, &cfg); err != nil {
 			return Config{}, err
 		}
-		cfg.Server.Port = ":" + cfg.Server.Port
-		return cfg, nil
+	} else if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return Config{}, err
 	}
 
-	if err := cleanenv.ReadEnv(&cfg); err != nil {
+	cfg.Server.Port = ":" + cfg.Server.Port
+
+	if err := cfg.Tracing.validate(); err != nil {
 		return Config{}, err
 	}
 
-	cfg.Server.Port = ":" + cfg.Server.Port
+	sunset, err := time.Parse("2006-01-02", cfg.Server.RawLegacySunset)
+	if err != nil {
+		return Config{}, fmt.Errorf("config: LEGACY_API_SUNSET must look like YYYY-MM-DD, got %q", cfg.Server.RawLegacySunset)
+	}
+	cfg.Server.LegacySunset = sunset
+
+	if cfg.Server.IdempotencyLockTTL <= 0 || cfg.Server.IdempotencyTTL < cfg.Server.IdempotencyLockTTL {
+		return Config{}, errors.New("config: IDEMPOTENCY_LOCK_TTL must be positive and not longer than IDEMPOTENCY_TTL")
+	}
+
+	if err := cfg.Server.validateCORS(); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Server.parseTrustedProxies(); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.RateLimit.parse(); err != nil {
+		return Config{}, err
+	}
+
+	if err := cfg.Blob.validate(); err != nil {
+		return Config{}, err
+	}
+
+	if cfg.Attachments.MaxSizeMB < 0 {
+		return Config{}, errors.New("config: ATTACHMENTS_MAX_SIZE_MB can't be negative")
+	}
+
+	if cfg.Recurring.Lookahead < 0 || cfg.Recurring.MaxCatchUp < 0 {
+		return Config{}, errors.New("config: RECURRING_LOOKAHEAD and RECURRING_MAX_CATCH_UP can't be negative")
+	}
 
 	return cfg, nil
 }
 
+func (t tracing) validate() error {
+	switch t.Exporter {
+	case TracingExporterOTLPGRPC, TracingExporterOTLPHTTP, TracingExporterJaeger, TracingExporterStdout, TracingExporterNone:
+	default:
+		return fmt.Errorf("config: TRACING_EXPORTER must be one of otlp-grpc, otlp-http, jaeger, stdout or none, got %q", t.Exporter)
+	}
+
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return fmt.Errorf("config: TRACING_SAMPLE_RATIO must be between 0 and 1, got %v", t.SampleRatio)
+	}
+
+	return nil
+}
+
+var corsMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+func (s server) validateCORS() error {
+	if len(s.AllowedOrigins) == 0 {
+		return errors.New("config: ALLOWED_CORS_ORIGINS can't be empty")
+	}
+
+	for _, origin := range s.AllowedOrigins {
+		if origin == "*" {
+			if s.CORS.AllowCredentials {
+				// Browsers refuse credentials for a wildcard origin, and reflecting
+				// every origin instead would let any site act on behalf of our users.
+				return errors.New("config: CORS_ALLOW_CREDENTIALS can't be used with \"*\" in ALLOWED_CORS_ORIGINS")
+			}
+			continue
+		}
+
+		u, err := url.Parse(strings.NewReplacer("*", "x", "?", "x").Replace(origin))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return fmt.Errorf("config: CORS origin %q must look like <scheme>://<host>[:<port>]", origin)
+		}
+	}
+
+	for _, method := range s.CORS.AllowedMethods {
+		if !corsMethods[strings.ToUpper(method)] {
+			return fmt.Errorf("config: %q is not a valid CORS method", method)
+		}
+	}
+
+	if s.CORS.MaxAge < 0 {
+		return errors.New("config: CORS_MAX_AGE can't be negative")
+	}
+
+	return nil
+}
+
+func (s *server) parseTrustedProxies() error {
+	s.TrustedProxies = nil
+	for _, raw := range s.RawTrustedProxies {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(raw)
+		if err != nil {
+			return fmt.Errorf("config: trusted proxy %q must look like <ip>/<prefix length>", raw)
+		}
+		s.TrustedProxies = append(s.TrustedProxies, network)
+	}
+	return nil
+}
+
+func (r *rateLimit) parse() error {
+	switch r.Backend {
+	case "redis", "memory", "off":
+	default:
+		return fmt.Errorf("config: RATE_LIMIT_BACKEND must be redis, memory or off, got %q", r.Backend)
+	}
+
+	r.Rules = map[string]RateLimitRule{}
+	for _, raw := range strings.Split(r.RawRules, ",") {
+		raw = strings.TrimSpace(raw)
+		if raw == "" {
+			continue
+		}
+
+		resource, limit, ok := strings.Cut(raw, "=")
+		if !ok {
+			return fmt.Errorf("config: rate limit rule %q must look like <resource>=<rate>/<burst>", raw)
+		}
+		rule, err := parseRateLimitRule(raw, limit)
+		if err != nil {
+			return err
+		}
+
+		r.Rules[strings.Trim(resource, "/")] = rule
+	}
+
+	if raw := strings.TrimSpace(r.RawPerIP); raw != "" {
+		rule, err := parseRateLimitRule("RATE_LIMIT_PER_IP", raw)
+		if err != nil {
+			return err
+		}
+		r.PerIP = &rule
+	}
+
+	return nil
+}
+
+// parseRateLimitRule parses "<rate>/<burst>", name is what errors call it.
+func parseRateLimitRule(name, limit string) (RateLimitRule, error) {
+	rate, burst, ok := strings.Cut(limit, "/")
+	if !ok {
+		return RateLimitRule{}, fmt.Errorf("config: rate limit rule %q must look like <rate>/<burst>", name)
+	}
+
+	var (
+		rule RateLimitRule
+		err  error
+	)
+	if rule.Rate, err = strconv.ParseFloat(rate, 64); err != nil || rule.Rate <= 0 {
+		return RateLimitRule{}, fmt.Errorf("config: rate limit rule %q has invalid rate", name)
+	}
+	if rule.Burst, err = strconv.Atoi(burst); err != nil || rule.Burst < 1 {
+		return RateLimitRule{}, fmt.Errorf("config: rate limit rule %q has invalid burst", name)
+	}
+	return rule, nil
+}
+
+func (b blob) validate() error {
+	switch b.Backend {
+	case "fs":
+		if b.Dir == "" {
+			return errors.New("config: BLOB_DIR can't be empty with the fs backend")
+		}
+	case "s3":
+		if b.S3Bucket == "" || b.S3AccessKey == "" || b.S3SecretKey == "" {
+			return errors.New("config: BLOB_S3_BUCKET, BLOB_S3_ACCESS_KEY and BLOB_S3_SECRET_KEY are required with the s3 backend")
+		}
+	default:
+		return fmt.Errorf("config: BLOB_BACKEND must be fs or s3, got %q", b.Backend)
+	}
+
+	return nil
+}
+
 func loadFlags() flags {
 	var f flags
 
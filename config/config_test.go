@@ -0,0 +1,87 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateCORS(t *testing.T) {
+	valid := func() server {
+		var s server
+		s.AllowedOrigins = []string{"https://app.example.com"}
+		s.CORS.AllowedMethods = []string{"GET", "post"}
+		s.CORS.MaxAge = 10 * time.Minute
+		return s
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(s *server)
+		wantErr string // empty if valid
+	}{
+		{"valid", func(s *server) {}, ""},
+		{"any origin", func(s *server) { s.AllowedOrigins = []string{"*"} }, ""},
+		{"wildcard subdomain", func(s *server) { s.AllowedOrigins = []string{"https://*.example.com"} }, ""},
+		{"origin with port", func(s *server) { s.AllowedOrigins = []string{"http://localhost:3000"} }, ""},
+		{"credentials with origin", func(s *server) { s.CORS.AllowCredentials = true }, ""},
+		{"no origins", func(s *server) { s.AllowedOrigins = nil }, "can't be empty"},
+		{
+			"credentials with any origin",
+			func(s *server) {
+				s.AllowedOrigins = []string{"https://app.example.com", "*"}
+				s.CORS.AllowCredentials = true
+			},
+			"CORS_ALLOW_CREDENTIALS",
+		},
+		{"origin without scheme", func(s *server) { s.AllowedOrigins = []string{"app.example.com"} }, "must look like"},
+		{"origin with other scheme", func(s *server) { s.AllowedOrigins = []string{"ftp://app.example.com"} }, "must look like"},
+		{"origin with path", func(s *server) { s.AllowedOrigins = []string{"https://app.example.com/app"} }, "must look like"},
+		{"unknown method", func(s *server) { s.CORS.AllowedMethods = []string{"GET", "BREW"} }, "not a valid CORS method"},
+		{"negative max age", func(s *server) { s.CORS.MaxAge = -time.Second }, "CORS_MAX_AGE"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := valid()
+			tt.modify(&s)
+
+			err := s.validateCORS()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("validateCORS() = %v, want no error", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("validateCORS() = nil, want error containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("validateCORS() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     []string
+		want    int
+		wantErr bool
+	}{
+		{"none", nil, 0, false},
+		{"ipv4 and ipv6", []string{"10.0.0.0/8", " fd00::/8 "}, 2, false},
+		{"empty entries", []string{"", "192.168.0.0/16"}, 1, false},
+		{"address without prefix", []string{"10.0.0.1"}, 0, true},
+		{"not an address", []string{"proxy.internal"}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := server{RawTrustedProxies: tt.raw}
+
+			err := s.parseTrustedProxies()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTrustedProxies() = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(s.TrustedProxies) != tt.want {
+				t.Fatalf("got %d networks, want %d", len(s.TrustedProxies), tt.want)
+			}
+		})
+	}
+}
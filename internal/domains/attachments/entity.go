@@ -0,0 +1,67 @@
+package attachments
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrBlobNotFound       = errors.New("attachment content not found")
+	ErrTooLarge           = errors.New("attachment is too large")
+	ErrTypeNotAllowed     = errors.New("attachment type is not allowed")
+	ErrChecksumMismatch   = errors.New("attachment checksum does not match")
+)
+
+// Attachment is the metadata of a file attached to a task, the content itself is kept in a BlobStore.
+type Attachment struct {
+	ID          string    `json:"id"`
+	TaskID      string    `json:"taskId"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"contentType"` // detected from the content, not taken from the client
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"` // hex encoded checksum of the content
+	UploadedBy  string    `json:"uploadedBy,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	BlobKey     string    `json:"-"`
+}
+
+// Upload is a file being attached, Content is read once until EOF.
+type Upload struct {
+	Filename string
+	Content  io.Reader
+	// SHA256 is the hex encoded checksum the client expects, it is not checked if empty.
+	SHA256 string
+}
+
+// Limits of uploads, zero values disable them.
+type Limits struct {
+	MaxSize int64
+	// AllowedTypes are media types like "image/png", "image/*" allows every image.
+	AllowedTypes []string
+}
+
+// Allows reports if contentType is one of AllowedTypes, parameters like charset are ignored.
+func (l Limits) Allows(contentType string) bool {
+	if len(l.AllowedTypes) == 0 {
+		return true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range l.AllowedTypes {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed == mediaType {
+			return true
+		}
+		if strings.HasSuffix(allowed, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(allowed, "*")) {
+			return true
+		}
+	}
+	return false
+}
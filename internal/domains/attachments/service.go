@@ -0,0 +1,364 @@
+package attachments
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+
+// sniffLen is how much of the content is looked at to detect its type.
+const sniffLen = 512
+
+type (
+	// Repository stores metadata of attachments next to the tasks they belong to.
+	Repository interface {
+		// Create fails with tasks.ErrTaskNotFound if the task doesn't exist.
+		Create(ctx context.Context, workspace string, attachment Attachment) (Attachment, error)
+		Read(ctx context.Context, workspace, taskID, id string) (Attachment, error)
+		// ReadAll returns all attachments of the task in no particular order.
+		ReadAll(ctx context.Context, workspace, taskID string) ([]Attachment, error)
+		Delete(ctx context.Context, workspace, taskID, id string) error
+	}
+
+	// BlobStore keeps the content of attachments, it knows nothing about tasks or workspaces.
+	BlobStore interface {
+		// Put stores everything read from r under a new key.
+		// If reading r fails, nothing is stored and the error is returned as is.
+		Put(ctx context.Context, r io.Reader, contentType string) (key string, err error)
+		// Get fails with ErrBlobNotFound if there is nothing under the key, the caller closes the reader.
+		Get(ctx context.Context, key string) (io.ReadCloser, error)
+		// Delete succeeds if there is nothing under the key.
+		Delete(ctx context.Context, key string) error
+	}
+
+	// TaskReader is used to check that the task is visible to the principal.
+	TaskReader interface {
+		Read(ctx context.Context, id string) (tasks.Task, error)
+	}
+
+	Service interface {
+		// Upload fails with ErrTooLarge, ErrTypeNotAllowed or ErrChecksumMismatch without keeping anything.
+		Upload(ctx context.Context, taskID string, upload Upload) (Attachment, error)
+		// ReadAll returns attachments of the task, oldest first.
+		ReadAll(ctx context.Context, taskID string) ([]Attachment, error)
+		// Download returns the attachment with its content, the caller closes it.
+		Download(ctx context.Context, taskID, id string) (Attachment, io.ReadCloser, error)
+		Delete(ctx context.Context, taskID, id string) error
+	}
+
+	service struct {
+		repo   Repository
+		blobs  BlobStore
+		tasks  TaskReader
+		guard  auth.Guard
+		limits Limits
+		log    *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, blobs BlobStore, tasks TaskReader, policy auth.Authorizer, limits Limits, log *logging.Logger) service {
+	return service{
+		repo:   repo,
+		blobs:  blobs,
+		tasks:  tasks,
+		guard:  auth.NewGuard(policy, log),
+		limits: limits,
+		log:    log,
+	}
+}
+
+// prepare checks that the task is readable and returns the workspace the operation is scoped to.
+// Errors of the tasks service are already logged and safe to return.
+func (s service) prepare(ctx context.Context, op, taskID string) (string, error) {
+	if _, err := s.tasks.Read(ctx, taskID); err != nil {
+		return "", err
+	}
+
+	ws, ok := auth.WorkspaceFrom(ctx)
+	if !ok {
+		s.log.DebugContext(ctx, op, logging.String("stage", "workspace"), logging.Error("err", auth.ErrWorkspaceRequired))
+		return "", auth.ErrWorkspaceRequired
+	}
+	return ws, nil
+}
+
+func (s service) read(ctx context.Context, op, ws, taskID, id string) (Attachment, error) {
+	a, err := s.repo.Read(ctx, ws, taskID, id)
+	if err != nil {
+		if errors.Is(err, ErrAttachmentNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Attachment{}, ErrAttachmentNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Attachment{}, errors.New("failed to read attachment")
+	}
+	return a, nil
+}
+
+// limitedReader fails with ErrTooLarge instead of stopping quietly like io.LimitedReader,
+// so that the blob store doesn't keep a truncated file.
+type limitedReader struct {
+	r    io.Reader
+	left int64 // negative means unlimited
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return l.r.Read(p)
+	}
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.left {
+		return 0, ErrTooLarge
+	}
+	l.left -= int64(n)
+	return n, err
+}
+
+// countingReader counts bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (s service) Upload(ctx context.Context, taskID string, upload Upload) (Attachment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "attachments.Upload")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "attachments.Upload", taskID)
+	if err != nil {
+		return Attachment{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "attachments.Upload", auth.ActionAttachmentsCreate, ""); err != nil {
+		return Attachment{}, err
+	}
+
+	// The type is detected from the content, the one the client sends can't be trusted.
+	content := bufio.NewReaderSize(upload.Content, sniffLen)
+	head, err := content.Peek(sniffLen)
+	if err != nil && err != io.EOF {
+		s.log.DebugContext(ctx, "attachments.Upload", logging.String("stage", "read"), logging.Error("err", err))
+		return Attachment{}, errors.New("failed to read attachment")
+	}
+	contentType := http.DetectContentType(head)
+	if !s.limits.Allows(contentType) {
+		s.log.DebugContext(ctx, "attachments.Upload", logging.String("stage", "type"), logging.String("type", contentType))
+		return Attachment{}, ErrTypeNotAllowed
+	}
+
+	maxSize := s.limits.MaxSize
+	if maxSize <= 0 {
+		maxSize = -1
+	}
+	hash := sha256.New()
+	counter := &countingReader{r: &limitedReader{r: content, left: maxSize}}
+	key, err := s.blobs.Put(ctx, io.TeeReader(counter, hash), contentType)
+	if err != nil {
+		if errors.Is(err, ErrTooLarge) {
+			s.log.DebugContext(ctx, "attachments.Upload", logging.String("stage", "size"), logging.Error("err", err))
+			return Attachment{}, ErrTooLarge
+		}
+		s.log.ErrorContext(ctx, "attachments.Upload", logging.String("stage", "blob"), logging.Error("err", err))
+		return Attachment{}, errors.New("failed to store attachment")
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if upload.SHA256 != "" && !strings.EqualFold(upload.SHA256, sum) {
+		s.log.DebugContext(ctx, "attachments.Upload", logging.String("stage", "checksum"), logging.String("sha256", sum))
+		s.discard(ctx, "attachments.Upload", key)
+		return Attachment{}, ErrChecksumMismatch
+	}
+
+	attachment := Attachment{
+		TaskID:      taskID,
+		Filename:    filepath.Base(filepath.Clean("/" + upload.Filename)),
+		ContentType: contentType,
+		Size:        counter.n,
+		SHA256:      sum,
+		CreatedAt:   time.Now().UTC(),
+		BlobKey:     key,
+	}
+	if attachment.Filename == "/" {
+		attachment.Filename = "attachment"
+	}
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		attachment.UploadedBy = p.ID
+	}
+
+	a, err := s.repo.Create(ctx, ws, attachment)
+	if err != nil {
+		s.discard(ctx, "attachments.Upload", key)
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			// The task was deleted while we were uploading.
+			s.log.DebugContext(ctx, "attachments.Upload", logging.String("stage", "db"), logging.Error("err", err))
+			return Attachment{}, tasks.ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "attachments.Upload", logging.String("stage", "db"), logging.Error("err", err))
+		return Attachment{}, errors.New("failed to create attachment")
+	}
+	s.log.InfoContext(ctx, "attachments.Upload",
+		logging.String("id", a.ID), logging.String("task", a.TaskID), logging.Int("size", int(a.Size)))
+	return a, nil
+}
+
+// discard deletes a blob that ended up without metadata, failures are only logged.
+func (s service) discard(ctx context.Context, op, key string) {
+	if err := s.blobs.Delete(ctx, key); err != nil {
+		s.log.ErrorContext(ctx, op, logging.String("stage", "discard"), logging.String("key", key), logging.Error("err", err))
+	}
+}
+
+func (s service) ReadAll(ctx context.Context, taskID string) ([]Attachment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "attachments.ReadAll")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "attachments.ReadAll", taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.repo.ReadAll(ctx, ws, taskID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "attachments.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read attachments")
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CreatedAt.Before(all[j].CreatedAt)
+	})
+	s.log.InfoContext(ctx, "attachments.ReadAll", logging.String("task", taskID), logging.Int("count", len(all)))
+	return all, nil
+}
+
+func (s service) Download(ctx context.Context, taskID, id string) (Attachment, io.ReadCloser, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "attachments.Download")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "attachments.Download", taskID)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	a, err := s.read(ctx, "attachments.Download", ws, taskID, id)
+	if err != nil {
+		return Attachment{}, nil, err
+	}
+
+	content, err := s.blobs.Get(ctx, a.BlobKey)
+	if err != nil {
+		if errors.Is(err, ErrBlobNotFound) {
+			// Metadata outlived the file, which is not something the client can fix.
+			s.log.ErrorContext(ctx, "attachments.Download", logging.String("stage", "blob"), logging.Error("err", err))
+			return Attachment{}, nil, ErrBlobNotFound
+		}
+		s.log.ErrorContext(ctx, "attachments.Download", logging.String("stage", "blob"), logging.Error("err", err))
+		return Attachment{}, nil, errors.New("failed to read attachment")
+	}
+	s.log.InfoContext(ctx, "attachments.Download", logging.String("id", a.ID), logging.String("task", a.TaskID))
+	return a, content, nil
+}
+
+func (s service) Delete(ctx context.Context, taskID, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "attachments.Delete")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "attachments.Delete", taskID)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.read(ctx, "attachments.Delete", ws, taskID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Authorize(ctx, "attachments.Delete", auth.ActionAttachmentsDelete, current.UploadedBy); err != nil {
+		return err
+	}
+
+	// The file goes first, metadata without a file can be deleted again, a file without metadata is lost.
+	if err := s.blobs.Delete(ctx, current.BlobKey); err != nil {
+		s.log.ErrorContext(ctx, "attachments.Delete", logging.String("stage", "blob"), logging.Error("err", err))
+		return errors.New("failed to delete attachment")
+	}
+
+	if err := s.repo.Delete(ctx, ws, taskID, id); err != nil {
+		if errors.Is(err, ErrAttachmentNotFound) {
+			s.log.DebugContext(ctx, "attachments.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrAttachmentNotFound
+		}
+		s.log.ErrorContext(ctx, "attachments.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete attachment")
+	}
+	s.log.InfoContext(ctx, "attachments.Delete", logging.String("id", id), logging.String("task", taskID))
+	return nil
+}
+
+// Cleaner removes attachments of tasks that are being deleted, see tasks.AttachmentRemover.
+// It doesn't authorize anything, the tasks service has already done it for the task.
+type Cleaner struct {
+	repo  Repository
+	blobs BlobStore
+	log   *logging.Logger
+}
+
+var _ tasks.AttachmentRemover = Cleaner{}
+
+func NewCleaner(repo Repository, blobs BlobStore, log *logging.Logger) Cleaner {
+	return Cleaner{
+		repo:  repo,
+		blobs: blobs,
+		log:   log,
+	}
+}
+
+func (c Cleaner) DeleteAll(ctx context.Context, workspace, taskID string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "attachments.DeleteAll")
+	defer span.End()
+
+	all, err := c.repo.ReadAll(ctx, workspace, taskID)
+	if err != nil {
+		return err
+	}
+	for _, a := range all {
+		if err := c.blobs.Delete(ctx, a.BlobKey); err != nil {
+			return err
+		}
+		if err := c.repo.Delete(ctx, workspace, taskID, a.ID); err != nil && !errors.Is(err, ErrAttachmentNotFound) {
+			return err
+		}
+	}
+	if len(all) > 0 {
+		c.log.InfoContext(ctx, "attachments.DeleteAll", logging.String("task", taskID), logging.Int("count", len(all)))
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrAPIKeyRevoked  = errors.New("api key revoked")
+	ErrInvalidScope   = errors.New("invalid scope")
+	ErrForbidden      = errors.New("forbidden")
+)
+
+// KnownScopes are the scopes an api key can be issued with.
+var KnownScopes = map[string]bool{
+	ScopeTasksRead:  true,
+	ScopeTasksWrite: true,
+}
+
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Owner      string     `json:"owner"`     // ID of the principal who issued the key
+	Workspace  string     `json:"workspace"` // the key can act only in this workspace
+	Scopes     []string   `json:"scopes"`
+	Hash       string     `json:"-"` // SHA-256 digest of the secret, the secret itself is never stored
+	CreatedAt  time.Time  `json:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"` // updated at most once a minute
+	RevokedAt  *time.Time `json:"revokedAt,omitempty"`
+}
+
+func (k APIKey) Revoked() bool {
+	return k.RevokedAt != nil
+}
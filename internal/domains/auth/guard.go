@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+)
+
+// Guard is what domain services use to check the principal and workspace of ctx.
+// It logs why a check failed, but returns only the bare auth errors, so that transport can tell them apart.
+type Guard struct {
+	policy Authorizer
+	log    *logging.Logger
+}
+
+func NewGuard(policy Authorizer, log *logging.Logger) Guard {
+	return Guard{policy: policy, log: log}
+}
+
+// Authorize checks that the principal may perform action on a resource owned by owner, which may be empty.
+func (g Guard) Authorize(ctx context.Context, op, action, owner string) error {
+	if err := g.policy.Authorize(ctx, action, owner); err != nil {
+		g.log.DebugContext(ctx, op, logging.String("stage", "policy"), logging.Error("err", err), Actor(ctx))
+		if errors.Is(err, ErrUnauthenticated) {
+			return ErrUnauthenticated
+		}
+		return ErrForbidden
+	}
+	return nil
+}
+
+// AuthorizeAny checks that the principal may perform the action at least on resources it owns.
+// It runs before the resource is read, so that callers who can't perform the action at all
+// get the same answer whether the resource exists or not.
+func (g Guard) AuthorizeAny(ctx context.Context, op, action string) error {
+	owner := ""
+	if p, ok := PrincipalFrom(ctx); ok {
+		owner = p.ID
+	}
+	return g.Authorize(ctx, op, action, owner)
+}
+
+// Workspace returns the workspace the operation is scoped to.
+func (g Guard) Workspace(ctx context.Context, op string) (string, error) {
+	w, ok := WorkspaceFrom(ctx)
+	if !ok {
+		g.log.DebugContext(ctx, op, logging.String("stage", "workspace"), logging.Error("err", ErrWorkspaceRequired))
+		return "", ErrWorkspaceRequired
+	}
+	return w, nil
+}
+
+// Actor is the principal performing the operation, anonymous if auth is disabled.
+func Actor(ctx context.Context) logging.Field {
+	p, ok := PrincipalFrom(ctx)
+	if !ok {
+		return logging.String("actor", "anonymous")
+	}
+	return logging.String("actor", p.ID)
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Actions are written as "<resource>:<verb>".
+// A permission is an action, optionally followed by ":own",
+// which allows the action only on resources owned by the principal.
+const (
+	ActionTasksRead   = "tasks:read"
+	ActionTasksCreate = "tasks:create"
+	ActionTasksUpdate = "tasks:update"
+	ActionTasksDelete = "tasks:delete"
+
+	// Comments are read along with tasks, so there is no action for it.
+	ActionCommentsCreate = "comments:create"
+	ActionCommentsUpdate = "comments:update"
+	ActionCommentsDelete = "comments:delete"
+
+	// Labels are read along with tasks as well.
+	ActionLabelsCreate = "labels:create"
+	ActionLabelsUpdate = "labels:update"
+	ActionLabelsDelete = "labels:delete"
+
+	// Projects are read along with tasks too.
+	ActionProjectsCreate = "projects:create"
+	ActionProjectsUpdate = "projects:update"
+	ActionProjectsDelete = "projects:delete"
+
+	// Attachments are listed and downloaded along with tasks, and never changed once uploaded.
+	ActionAttachmentsCreate = "attachments:create"
+	ActionAttachmentsDelete = "attachments:delete"
+
+	// Recurring task templates are read along with tasks, tasks made from them are created by the scheduler.
+	ActionRecurringCreate = "recurring:create"
+	ActionRecurringUpdate = "recurring:update"
+	ActionRecurringDelete = "recurring:delete"
+
+	// Users are read by everyone, activating and deactivating them is an update.
+	ActionUsersCreate = "users:create"
+	ActionUsersUpdate = "users:update"
+
+	ownSuffix = ":own"
+	wildcard  = "*"
+)
+
+const (
+	RoleViewer  = "viewer"
+	RoleMember  = "member"
+	RoleAdmin   = "admin"
+	RoleService = "service" // every api key has this role
+)
+
+type (
+	// Authorizer decides if the principal in ctx can perform action.
+	// owner is the ID of the principal owning the resource, empty if there is none.
+	Authorizer interface {
+		Authorize(ctx context.Context, action, owner string) error
+	}
+
+	Policy struct {
+		DefaultRole string              `json:"defaultRole"` // given to principals without roles
+		Roles       map[string][]string `json:"roles"`       // role -> permissions
+		allowAll    bool
+	}
+)
+
+var _ Authorizer = Policy{}
+
+// AllowAll authorizes everything, it is used when authentication is disabled.
+var AllowAll = Policy{allowAll: true}
+
+func DefaultPolicy() Policy {
+	return Policy{
+		DefaultRole: RoleViewer,
+		Roles: map[string][]string{
+			RoleViewer: {ActionTasksRead},
+			RoleMember: {
+				ActionTasksRead, ActionTasksCreate, ActionTasksUpdate + ownSuffix,
+				ActionCommentsCreate, ActionCommentsUpdate + ownSuffix, ActionCommentsDelete + ownSuffix,
+				ActionLabelsCreate,
+				ActionProjectsCreate, ActionProjectsUpdate + ownSuffix, ActionProjectsDelete + ownSuffix,
+				ActionAttachmentsCreate, ActionAttachmentsDelete + ownSuffix,
+				ActionRecurringCreate, ActionRecurringUpdate + ownSuffix, ActionRecurringDelete + ownSuffix,
+			},
+			RoleAdmin: {wildcard},
+			RoleService: {
+				ActionTasksRead, ActionTasksCreate, ActionTasksUpdate, ActionTasksDelete,
+				ActionCommentsCreate, ActionAttachmentsCreate,
+			},
+		},
+	}
+}
+
+// LoadPolicy reads a policy from a JSON file, or returns DefaultPolicy if filename is empty.
+func LoadPolicy(filename string) (Policy, error) {
+	if filename == "" {
+		return DefaultPolicy(), nil
+	}
+
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return Policy{}, fmt.Errorf("auth: could not read policy: %w", err)
+	}
+
+	var p Policy
+	if err := json.Unmarshal(raw, &p); err != nil {
+		return Policy{}, fmt.Errorf("auth: could not parse policy: %w", err)
+	}
+	if p.DefaultRole != "" {
+		if _, ok := p.Roles[p.DefaultRole]; !ok {
+			return Policy{}, fmt.Errorf("auth: default role %q is not defined", p.DefaultRole)
+		}
+	}
+
+	return p, nil
+}
+
+func (p Policy) Authorize(ctx context.Context, action, owner string) error {
+	if p.allowAll {
+		return nil
+	}
+
+	principal, ok := PrincipalFrom(ctx)
+	if !ok {
+		return ErrUnauthenticated
+	}
+
+	// Scopes narrow down what roles allow, they never grant anything on their own.
+	if !principal.Allows(scopeOf(action)) {
+		return fmt.Errorf("%w: scope does not allow %s", ErrForbidden, action)
+	}
+
+	roles := principal.Roles
+	if len(roles) == 0 && p.DefaultRole != "" {
+		roles = []string{p.DefaultRole}
+	}
+
+	for _, role := range roles {
+		for _, perm := range p.Roles[role] {
+			if perm == wildcard || perm == action {
+				return nil
+			}
+			if perm == action+ownSuffix && owner != "" && owner == principal.ID {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrForbidden, action)
+}
+
+// scopeOf maps an action to the api key scope that covers it.
+// Comments, labels, projects, attachments and recurring templates are a part of tasks and share their scopes.
+func scopeOf(action string) string {
+	resource, verb, _ := strings.Cut(action, ":")
+	if resource == "comments" || resource == "labels" || resource == "projects" ||
+		resource == "attachments" || resource == "recurring" {
+		resource = "tasks"
+	}
+	if verb == "read" {
+		return resource + ":read"
+	}
+	return resource + ":write"
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+)
+
+type (
+	// Principal is whoever performs an operation: a user or a machine client.
+	Principal struct {
+		ID    string   `json:"id"`
+		Roles []string `json:"roles,omitempty"`
+		// Scopes restrict what the principal can do.
+		// Nil scopes mean that the principal is not restricted by scopes at all.
+		Scopes []string `json:"scopes,omitempty"`
+		// Workspaces the principal is a member of.
+		Workspaces []string `json:"workspaces,omitempty"`
+	}
+
+	principalKey struct{}
+)
+
+func (p Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the scopes of the principal permit scope.
+func (p Principal) Allows(scope string) bool {
+	if p.Scopes == nil {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// WithPrincipal returns a copy of ctx that carries p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx by WithPrincipal.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
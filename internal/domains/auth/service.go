@@ -0,0 +1,289 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+
+// Api keys are handed out as "<id>.<secret>", so that we can find
+// the stored hash by id and compare only the secret against it.
+const apiKeySeparator = "."
+
+// apiKeyTouchInterval is how often the last use of a key is written at most,
+// so that a busy client doesn't cause a write on every request.
+const apiKeyTouchInterval = time.Minute
+
+var errSecretMismatch = errors.New("secret doesn't match")
+
+type (
+	Repository interface {
+		CreateAPIKey(ctx context.Context, key APIKey) (APIKey, error)
+		ReadAPIKey(ctx context.Context, id string) (APIKey, error)
+		// ReadAllAPIKeys returns the keys of the workspace.
+		ReadAllAPIKeys(ctx context.Context, workspace string) ([]APIKey, error)
+		UpdateAPIKey(ctx context.Context, key APIKey) (APIKey, error)
+		TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error
+	}
+
+	Service interface {
+		// IssueAPIKey returns the created key and its plaintext value.
+		// The plaintext is not stored anywhere, so it can't be shown again.
+		IssueAPIKey(ctx context.Context, name string, scopes []string) (APIKey, string, error)
+		// ReadAllAPIKeys, RevokeAPIKey and RotateAPIKey only see keys of the workspace of the request.
+		ReadAllAPIKeys(ctx context.Context) ([]APIKey, error)
+		RevokeAPIKey(ctx context.Context, id string) error
+		// RotateAPIKey replaces the secret of the key, the old value stops working immediately.
+		RotateAPIKey(ctx context.Context, id string) (APIKey, string, error)
+		AuthenticateAPIKey(ctx context.Context, raw string) (Principal, error)
+	}
+
+	service struct {
+		repo Repository
+		log  *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, log *logging.Logger) service {
+	return service{
+		repo: repo,
+		log:  log,
+	}
+}
+
+func (s service) IssueAPIKey(ctx context.Context, name string, scopes []string) (APIKey, string, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "auth.IssueAPIKey")
+	defer span.End()
+	defer s.log.Sync()
+
+	if len(scopes) == 0 {
+		return APIKey{}, "", fmt.Errorf("%w: at least one scope is required", ErrInvalidScope)
+	}
+	for _, scope := range scopes {
+		if !KnownScopes[scope] {
+			return APIKey{}, "", fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+		}
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		s.log.ErrorContext(ctx, "auth.IssueAPIKey", logging.String("stage", "secret"), logging.Error("err", err))
+		return APIKey{}, "", errors.New("failed to issue api key")
+	}
+
+	owner, _ := PrincipalFrom(ctx)
+	workspace, ok := WorkspaceFrom(ctx)
+	if !ok {
+		return APIKey{}, "", ErrWorkspaceRequired
+	}
+
+	key, err := s.repo.CreateAPIKey(ctx, APIKey{
+		Name:      name,
+		Owner:     owner.ID,
+		Workspace: workspace,
+		Scopes:    scopes,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		s.log.ErrorContext(ctx, "auth.IssueAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+		return APIKey{}, "", errors.New("failed to issue api key")
+	}
+	s.log.InfoContext(ctx, "auth.IssueAPIKey", logging.String("id", key.ID), logging.String("owner", key.Owner))
+	return key, key.ID + apiKeySeparator + secret, nil
+}
+
+// readInWorkspace returns the key if it belongs to the workspace of the request.
+// Keys of other workspaces are reported as not found, so that their ids can't be probed.
+func (s service) readInWorkspace(ctx context.Context, op, id string) (APIKey, error) {
+	workspace, ok := WorkspaceFrom(ctx)
+	if !ok {
+		return APIKey{}, ErrWorkspaceRequired
+	}
+
+	key, err := s.repo.ReadAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return APIKey{}, ErrAPIKeyNotFound
+		}
+		return APIKey{}, err
+	}
+	if key.Workspace != workspace {
+		s.log.DebugContext(ctx, op, logging.String("stage", "workspace"), logging.String("id", id), logging.String("workspace", key.Workspace))
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+func (s service) ReadAllAPIKeys(ctx context.Context) ([]APIKey, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "auth.ReadAllAPIKeys")
+	defer span.End()
+	defer s.log.Sync()
+
+	workspace, ok := WorkspaceFrom(ctx)
+	if !ok {
+		return nil, ErrWorkspaceRequired
+	}
+
+	keys, err := s.repo.ReadAllAPIKeys(ctx, workspace)
+	if err != nil {
+		s.log.ErrorContext(ctx, "auth.ReadAllAPIKeys", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read api keys")
+	}
+	s.log.InfoContext(ctx, "auth.ReadAllAPIKeys", logging.Int("count", len(keys)))
+	return keys, nil
+}
+
+func (s service) RevokeAPIKey(ctx context.Context, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "auth.RevokeAPIKey")
+	defer span.End()
+	defer s.log.Sync()
+
+	key, err := s.readInWorkspace(ctx, "auth.RevokeAPIKey", id)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) || errors.Is(err, ErrWorkspaceRequired) {
+			return err
+		}
+		s.log.ErrorContext(ctx, "auth.RevokeAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to revoke api key")
+	}
+	if key.Revoked() {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	if _, err := s.repo.UpdateAPIKey(ctx, key); err != nil {
+		s.log.ErrorContext(ctx, "auth.RevokeAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to revoke api key")
+	}
+	s.log.InfoContext(ctx, "auth.RevokeAPIKey", logging.String("id", id))
+	return nil
+}
+
+func (s service) RotateAPIKey(ctx context.Context, id string) (APIKey, string, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "auth.RotateAPIKey")
+	defer span.End()
+	defer s.log.Sync()
+
+	key, err := s.readInWorkspace(ctx, "auth.RotateAPIKey", id)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) || errors.Is(err, ErrWorkspaceRequired) {
+			return APIKey{}, "", err
+		}
+		s.log.ErrorContext(ctx, "auth.RotateAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+		return APIKey{}, "", errors.New("failed to rotate api key")
+	}
+	if key.Revoked() {
+		return APIKey{}, "", ErrAPIKeyRevoked
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		s.log.ErrorContext(ctx, "auth.RotateAPIKey", logging.String("stage", "secret"), logging.Error("err", err))
+		return APIKey{}, "", errors.New("failed to rotate api key")
+	}
+	key.Hash = hash
+
+	key, err = s.repo.UpdateAPIKey(ctx, key)
+	if err != nil {
+		s.log.ErrorContext(ctx, "auth.RotateAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+		return APIKey{}, "", errors.New("failed to rotate api key")
+	}
+	s.log.InfoContext(ctx, "auth.RotateAPIKey", logging.String("id", key.ID))
+	return key, key.ID + apiKeySeparator + secret, nil
+}
+
+func (s service) AuthenticateAPIKey(ctx context.Context, raw string) (Principal, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "auth.AuthenticateAPIKey")
+	defer span.End()
+	defer s.log.Sync()
+
+	id, secret, ok := strings.Cut(raw, apiKeySeparator)
+	if !ok || id == "" || secret == "" {
+		return Principal{}, ErrUnauthenticated
+	}
+
+	key, err := s.repo.ReadAPIKey(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyNotFound) {
+			s.log.DebugContext(ctx, "auth.AuthenticateAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+			return Principal{}, ErrUnauthenticated
+		}
+		s.log.ErrorContext(ctx, "auth.AuthenticateAPIKey", logging.String("stage", "db"), logging.Error("err", err))
+		return Principal{}, errors.New("failed to authenticate api key")
+	}
+
+	if key.Revoked() {
+		s.log.DebugContext(ctx, "auth.AuthenticateAPIKey", logging.String("id", id), logging.Error("err", ErrAPIKeyRevoked))
+		return Principal{}, ErrUnauthenticated
+	}
+	if err := compareSecret(key.Hash, secret); err != nil {
+		s.log.DebugContext(ctx, "auth.AuthenticateAPIKey", logging.String("id", id), logging.Error("err", err))
+		return Principal{}, ErrUnauthenticated
+	}
+
+	now := time.Now().UTC()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(ctx, id, now); err != nil {
+			// Not being able to track usage should not lock machine clients out.
+			s.log.ErrorContext(ctx, "auth.AuthenticateAPIKey", logging.String("stage", "touch"), logging.Error("err", err))
+		}
+	}
+
+	p := Principal{
+		ID:     "apikey:" + key.ID,
+		Roles:  []string{RoleService},
+		Scopes: key.Scopes,
+	}
+	if key.Workspace != "" {
+		p.Workspaces = []string{key.Workspace}
+	}
+	return p, nil
+}
+
+// Secrets are 32 random bytes, so a plain SHA-256 digest is enough to store them,
+// unlike passwords they can't be guessed and don't need a slow hash.
+func newSecret() (secret, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	secret = base64.RawURLEncoding.EncodeToString(b)
+
+	return secret, digest(secret), nil
+}
+
+func digest(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// compareSecret checks the secret against the stored hash. Keys issued before secrets were
+// stored as SHA-256 digests still have bcrypt hashes, until they are rotated.
+func compareSecret(hash, secret string) error {
+	if strings.HasPrefix(hash, "$2") {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(secret))
+	}
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(digest(secret))) != 1 {
+		return errSecretMismatch
+	}
+	return nil
+}
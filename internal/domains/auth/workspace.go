@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrWorkspaceRequired = errors.New("workspace required")
+	ErrInvalidWorkspace  = errors.New("invalid workspace")
+)
+
+// Workspace IDs end up in storage keys, so they are restricted to a safe alphabet.
+var workspaceIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type workspaceKey struct{}
+
+func ValidWorkspaceID(id string) bool {
+	return workspaceIDPattern.MatchString(id)
+}
+
+// MemberOf reports whether the principal belongs to the workspace.
+func (p Principal) MemberOf(workspace string) bool {
+	for _, w := range p.Workspaces {
+		if w == workspace {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveWorkspace picks the workspace a request operates on.
+// requested is what the caller asked for, it may be empty.
+// A principal can only ever act in workspaces it is a member of,
+// without a principal (auth disabled) any valid workspace can be used.
+func ResolveWorkspace(p *Principal, requested, fallback string) (string, error) {
+	if requested != "" && !ValidWorkspaceID(requested) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidWorkspace, requested)
+	}
+
+	if p == nil {
+		if requested == "" {
+			requested = fallback
+		}
+		if requested == "" {
+			return "", ErrWorkspaceRequired
+		}
+		return requested, nil
+	}
+
+	if requested != "" {
+		if !p.MemberOf(requested) {
+			return "", fmt.Errorf("%w: not a member of workspace %s", ErrForbidden, requested)
+		}
+		return requested, nil
+	}
+
+	if len(p.Workspaces) == 1 {
+		return p.Workspaces[0], nil
+	}
+	return "", ErrWorkspaceRequired
+}
+
+// WithWorkspace returns a copy of ctx that carries the workspace.
+func WithWorkspace(ctx context.Context, workspace string) context.Context {
+	return context.WithValue(ctx, workspaceKey{}, workspace)
+}
+
+// WorkspaceFrom returns the workspace stored in ctx by WithWorkspace.
+func WorkspaceFrom(ctx context.Context) (string, bool) {
+	w, ok := ctx.Value(workspaceKey{}).(string)
+	return w, ok && w != ""
+}
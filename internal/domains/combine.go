@@ -1,12 +1,39 @@
 package domains
 
-import "github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+import (
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/comments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
+)
 
 type DomainCombiner struct {
-	tasksService tasks.Service
+	tasksService       tasks.Service
+	authService        auth.Service
+	usersService       users.Service
+	commentsService    comments.Service
+	labelsService      labels.Service
+	projectsService    projects.Service
+	attachmentsService attachments.Service
+	recurringService   recurring.Service
+	scheduler          *recurring.Scheduler
 }
 
-func NewDomainCombiner(commonDep CommonDependencies, tasksDep TasksDependencies) (DomainCombiner, error) {
+func NewDomainCombiner(
+	commonDep CommonDependencies,
+	tasksDep TasksDependencies,
+	authDep AuthDependencies,
+	usersDep UsersDependencies,
+	commentsDep CommentsDependencies,
+	labelsDep LabelsDependencies,
+	projectsDep ProjectsDependencies,
+	attachmentsDep AttachmentsDependencies,
+	recurringDep RecurringDependencies,
+) (DomainCombiner, error) {
 	if err := commonDep.Validate(); err != nil {
 		return DomainCombiner{}, err
 	}
@@ -15,13 +42,91 @@ func NewDomainCombiner(commonDep CommonDependencies, tasksDep TasksDependencies)
 		return DomainCombiner{}, err
 	}
 
-	t := tasks.NewService(tasksDep.Repo, commonDep.Log)
+	if err := authDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	if err := usersDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	if err := commentsDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	if err := labelsDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	if err := projectsDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	if err := attachmentsDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	if err := recurringDep.Validate(); err != nil {
+		return DomainCombiner{}, err
+	}
+
+	u := users.NewService(usersDep.Repo, usersDep.Policy, commonDep.Log)
+	l := labels.NewService(labelsDep.Repo, labelsDep.Policy, commonDep.Log)
+	p := projects.NewService(projectsDep.Repo, projectsDep.Policy, commonDep.Log)
+	cleaner := attachments.NewCleaner(attachmentsDep.Repo, attachmentsDep.Blobs, commonDep.Log)
+	t := tasks.NewService(tasksDep.Repo, u, l, p, cleaner, tasksDep.Policy, tasksDep.MaxTasks, tasksDep.OnParentDelete, commonDep.Log)
+	a := auth.NewService(authDep.Repo, commonDep.Log)
+	c := comments.NewService(commentsDep.Repo, t, commentsDep.Policy, commonDep.Log)
+	att := attachments.NewService(attachmentsDep.Repo, attachmentsDep.Blobs, t, attachmentsDep.Policy, attachmentsDep.Limits, commonDep.Log)
+	r := recurring.NewService(recurringDep.Repo, recurringDep.Policy, commonDep.Log)
+	sched := recurring.NewScheduler(recurringDep.Repo, t, recurringDep.Locker, recurringDep.Scheduler, commonDep.Log)
 
 	return DomainCombiner{
-		tasksService: t,
+		tasksService:       t,
+		authService:        a,
+		usersService:       u,
+		commentsService:    c,
+		labelsService:      l,
+		projectsService:    p,
+		attachmentsService: att,
+		recurringService:   r,
+		scheduler:          sched,
 	}, nil
 }
 
 func (c DomainCombiner) TasksService() tasks.Service {
 	return c.tasksService
 }
+
+func (c DomainCombiner) AuthService() auth.Service {
+	return c.authService
+}
+
+func (c DomainCombiner) UsersService() users.Service {
+	return c.usersService
+}
+
+func (c DomainCombiner) CommentsService() comments.Service {
+	return c.commentsService
+}
+
+func (c DomainCombiner) LabelsService() labels.Service {
+	return c.labelsService
+}
+
+func (c DomainCombiner) ProjectsService() projects.Service {
+	return c.projectsService
+}
+
+func (c DomainCombiner) AttachmentsService() attachments.Service {
+	return c.attachmentsService
+}
+
+func (c DomainCombiner) RecurringService() recurring.Service {
+	return c.recurringService
+}
+
+// Scheduler creates tasks from recurring templates once it is started.
+func (c DomainCombiner) Scheduler() *recurring.Scheduler {
+	return c.scheduler
+}
@@ -0,0 +1,23 @@
+package comments
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrCommentNotFound = errors.New("comment not found")
+	// ErrNestedReply is returned when replying to a reply, threads are one level deep.
+	ErrNestedReply = errors.New("replies can't be replied to")
+)
+
+type Comment struct {
+	ID        string     `json:"id"`
+	TaskID    string     `json:"taskId"`
+	ParentID  string     `json:"parentId,omitempty"` // empty for top level comments
+	Author    string     `json:"author,omitempty"`   // ID of the principal who wrote the comment
+	Body      string     `json:"body"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+	Replies   []Comment  `json:"replies,omitempty"` // only set on top level comments
+}
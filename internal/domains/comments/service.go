@@ -0,0 +1,259 @@
+package comments
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/comments"
+
+type (
+	// Repository stores comments next to the tasks they belong to,
+	// deleting a task deletes its comments as well.
+	Repository interface {
+		// Create fails with tasks.ErrTaskNotFound if the task doesn't exist.
+		Create(ctx context.Context, workspace string, comment Comment) (Comment, error)
+		Read(ctx context.Context, workspace, taskID, id string) (Comment, error)
+		// ReadAll returns all comments of the task, replies included, in no particular order.
+		ReadAll(ctx context.Context, workspace, taskID string) ([]Comment, error)
+		Update(ctx context.Context, workspace string, comment Comment) (Comment, error)
+		// Delete removes the comment together with its replies.
+		Delete(ctx context.Context, workspace, taskID, id string) error
+	}
+
+	// TaskReader is used to check that the task being discussed is visible to the principal.
+	TaskReader interface {
+		Read(ctx context.Context, id string) (tasks.Task, error)
+	}
+
+	Service interface {
+		Create(ctx context.Context, comment Comment) (Comment, error)
+		// ReadAll returns top level comments of the task, oldest first, with their replies.
+		ReadAll(ctx context.Context, taskID string) ([]Comment, error)
+		// Update changes the body, only the author can do it.
+		Update(ctx context.Context, comment Comment) (Comment, error)
+		Delete(ctx context.Context, taskID, id string) error
+	}
+
+	service struct {
+		repo  Repository
+		tasks TaskReader
+		guard auth.Guard
+		log   *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, tasks TaskReader, policy auth.Authorizer, log *logging.Logger) service {
+	return service{
+		repo:  repo,
+		tasks: tasks,
+		guard: auth.NewGuard(policy, log),
+		log:   log,
+	}
+}
+
+// prepare checks that the task is readable and returns the workspace the operation is scoped to.
+// Errors of the tasks service are already logged and safe to return.
+func (s service) prepare(ctx context.Context, op, taskID string) (string, error) {
+	if _, err := s.tasks.Read(ctx, taskID); err != nil {
+		return "", err
+	}
+
+	ws, ok := auth.WorkspaceFrom(ctx)
+	if !ok {
+		s.log.DebugContext(ctx, op, logging.String("stage", "workspace"), logging.Error("err", auth.ErrWorkspaceRequired))
+		return "", auth.ErrWorkspaceRequired
+	}
+	return ws, nil
+}
+
+func (s service) read(ctx context.Context, op, ws, taskID, id string) (Comment, error) {
+	c, err := s.repo.Read(ctx, ws, taskID, id)
+	if err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Comment{}, ErrCommentNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Comment{}, errors.New("failed to read comment")
+	}
+	return c, nil
+}
+
+func (s service) Create(ctx context.Context, comment Comment) (Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "comments.Create")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "comments.Create", comment.TaskID)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "comments.Create", auth.ActionCommentsCreate, ""); err != nil {
+		return Comment{}, err
+	}
+
+	if comment.ParentID != "" {
+		parent, err := s.read(ctx, "comments.Create", ws, comment.TaskID, comment.ParentID)
+		if err != nil {
+			return Comment{}, err
+		}
+		if parent.ParentID != "" {
+			return Comment{}, ErrNestedReply
+		}
+	}
+
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		comment.Author = p.ID
+	}
+	comment.CreatedAt = time.Now().UTC()
+	comment.UpdatedAt = nil
+	comment.Replies = nil
+
+	c, err := s.repo.Create(ctx, ws, comment)
+	if err != nil {
+		if errors.Is(err, tasks.ErrTaskNotFound) {
+			// The task was deleted while we were writing.
+			s.log.DebugContext(ctx, "comments.Create", logging.String("stage", "db"), logging.Error("err", err))
+			return Comment{}, tasks.ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "comments.Create", logging.String("stage", "db"), logging.Error("err", err))
+		return Comment{}, errors.New("failed to create comment")
+	}
+	s.log.InfoContext(ctx, "comments.Create", logging.String("id", c.ID), logging.String("task", c.TaskID))
+	return c, nil
+}
+
+func (s service) ReadAll(ctx context.Context, taskID string) ([]Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "comments.ReadAll")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "comments.ReadAll", taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	all, err := s.repo.ReadAll(ctx, ws, taskID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "comments.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read comments")
+	}
+	s.log.InfoContext(ctx, "comments.ReadAll", logging.String("task", taskID), logging.Int("count", len(all)))
+	return thread(all), nil
+}
+
+// thread nests replies under their parents, both ordered from the oldest.
+func thread(all []Comment) []Comment {
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CreatedAt.Before(all[j].CreatedAt)
+	})
+
+	index := make(map[string]int, len(all))
+	roots := make([]Comment, 0, len(all))
+	for _, c := range all {
+		if c.ParentID == "" {
+			index[c.ID] = len(roots)
+			roots = append(roots, c)
+		}
+	}
+	for _, c := range all {
+		if c.ParentID == "" {
+			continue
+		}
+		if i, ok := index[c.ParentID]; ok {
+			roots[i].Replies = append(roots[i].Replies, c)
+		}
+	}
+	return roots
+}
+
+func (s service) Update(ctx context.Context, comment Comment) (Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "comments.Update")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "comments.Update", comment.TaskID)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "comments.Update", auth.ActionCommentsUpdate); err != nil {
+		return Comment{}, err
+	}
+
+	current, err := s.read(ctx, "comments.Update", ws, comment.TaskID, comment.ID)
+	if err != nil {
+		return Comment{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "comments.Update", auth.ActionCommentsUpdate, current.Author); err != nil {
+		return Comment{}, err
+	}
+	// Nobody, admins included, puts words in someone else's mouth.
+	if p, ok := auth.PrincipalFrom(ctx); ok && p.ID != current.Author {
+		s.log.DebugContext(ctx, "comments.Update", logging.String("stage", "author"), logging.String("author", current.Author))
+		return Comment{}, auth.ErrForbidden
+	}
+
+	now := time.Now().UTC()
+	current.Body = comment.Body
+	current.UpdatedAt = &now
+
+	c, err := s.repo.Update(ctx, ws, current)
+	if err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			s.log.DebugContext(ctx, "comments.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Comment{}, ErrCommentNotFound
+		}
+		s.log.ErrorContext(ctx, "comments.Update", logging.String("stage", "db"), logging.Error("err", err))
+		return Comment{}, errors.New("failed to update comment")
+	}
+	s.log.InfoContext(ctx, "comments.Update", logging.String("id", c.ID), logging.String("task", c.TaskID))
+	return c, nil
+}
+
+func (s service) Delete(ctx context.Context, taskID, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "comments.Delete")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.prepare(ctx, "comments.Delete", taskID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "comments.Delete", auth.ActionCommentsDelete); err != nil {
+		return err
+	}
+
+	current, err := s.read(ctx, "comments.Delete", ws, taskID, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Authorize(ctx, "comments.Delete", auth.ActionCommentsDelete, current.Author); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, ws, taskID, id); err != nil {
+		if errors.Is(err, ErrCommentNotFound) {
+			s.log.DebugContext(ctx, "comments.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrCommentNotFound
+		}
+		s.log.ErrorContext(ctx, "comments.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete comment")
+	}
+	s.log.InfoContext(ctx, "comments.Delete", logging.String("id", id), logging.String("task", taskID))
+	return nil
+}
@@ -4,7 +4,14 @@ import (
 	"fmt"
 	"reflect"
 
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/comments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
 	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
 )
 
@@ -24,7 +31,11 @@ func (deps CommonDependencies) Validate() error {
 }
 
 type TasksDependencies struct {
-	Repo tasks.Repository
+	Repo     tasks.Repository
+	Policy   auth.Authorizer
+	MaxTasks int // per workspace, 0 means unlimited
+	// OnParentDelete is one of the tasks.OnParentDelete constants, subtasks block deletion of their parent if empty.
+	OnParentDelete string
 }
 
 func (deps TasksDependencies) Validate() error {
@@ -35,6 +46,111 @@ func (deps TasksDependencies) Validate() error {
 		}
 	}
 
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "TasksDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if deps.MaxTasks < 0 {
+		return DependencyError{
+			Dependency:       "TasksDependencies.MaxTasks",
+			BrokenConstraint: "can't be negative",
+		}
+	}
+
+	if deps.OnParentDelete != "" && !tasks.ValidOnParentDelete(deps.OnParentDelete) {
+		return DependencyError{
+			Dependency:       "TasksDependencies.OnParentDelete",
+			BrokenConstraint: "must be reject, orphan or cascade",
+		}
+	}
+
+	return nil
+}
+
+type AuthDependencies struct {
+	Repo auth.Repository
+}
+
+func (deps AuthDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "AuthDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	return nil
+}
+
+type UsersDependencies struct {
+	Repo   users.Repository
+	Policy auth.Authorizer
+}
+
+func (deps UsersDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "UsersDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "UsersDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	return nil
+}
+
+type CommentsDependencies struct {
+	Repo   comments.Repository
+	Policy auth.Authorizer
+}
+
+func (deps CommentsDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "CommentsDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "CommentsDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	return nil
+}
+
+type LabelsDependencies struct {
+	Repo   labels.Repository
+	Policy auth.Authorizer
+}
+
+func (deps LabelsDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "LabelsDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "LabelsDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
 	return nil
 }
 
@@ -59,3 +175,118 @@ func isNil(i any) bool {
 
 	return false
 }
+
+type ProjectsDependencies struct {
+	Repo   projects.Repository
+	Policy auth.Authorizer
+}
+
+func (deps ProjectsDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "ProjectsDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "ProjectsDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	return nil
+}
+
+type AttachmentsDependencies struct {
+	Repo   attachments.Repository
+	Blobs  attachments.BlobStore
+	Policy auth.Authorizer
+	Limits attachments.Limits
+}
+
+func (deps AttachmentsDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "AttachmentsDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Blobs) {
+		return DependencyError{
+			Dependency:       "AttachmentsDependencies.Blobs",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "AttachmentsDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if deps.Limits.MaxSize < 0 {
+		return DependencyError{
+			Dependency:       "AttachmentsDependencies.Limits.MaxSize",
+			BrokenConstraint: "can't be negative",
+		}
+	}
+
+	return nil
+}
+
+type RecurringDependencies struct {
+	Repo      recurring.Repository
+	Locker    recurring.Locker
+	Policy    auth.Authorizer
+	Scheduler recurring.SchedulerOptions
+}
+
+func (deps RecurringDependencies) Validate() error {
+	if isNil(deps.Repo) {
+		return DependencyError{
+			Dependency:       "RecurringDependencies.Repo",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Locker) {
+		return DependencyError{
+			Dependency:       "RecurringDependencies.Locker",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if isNil(deps.Policy) {
+		return DependencyError{
+			Dependency:       "RecurringDependencies.Policy",
+			BrokenConstraint: "can't be nil",
+		}
+	}
+
+	if deps.Scheduler.Lookahead < 0 {
+		return DependencyError{
+			Dependency:       "RecurringDependencies.Scheduler.Lookahead",
+			BrokenConstraint: "can't be negative",
+		}
+	}
+
+	if deps.Scheduler.MaxCatchUp < 0 {
+		return DependencyError{
+			Dependency:       "RecurringDependencies.Scheduler.MaxCatchUp",
+			BrokenConstraint: "can't be negative",
+		}
+	}
+
+	if deps.Scheduler.LockTTL <= 0 {
+		return DependencyError{
+			Dependency:       "RecurringDependencies.Scheduler.LockTTL",
+			BrokenConstraint: "must be positive",
+		}
+	}
+
+	return nil
+}
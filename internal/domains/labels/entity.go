@@ -0,0 +1,26 @@
+package labels
+
+import (
+	"errors"
+	"regexp"
+)
+
+var (
+	ErrLabelNotFound = errors.New("label not found")
+	ErrLabelExists   = errors.New("label already exists")
+	ErrInvalidLabel  = errors.New("invalid label name")
+)
+
+// Names are stored comma separated and used in keys, so they are kept simple.
+var labelName = regexp.MustCompile(`^[A-Za-z0-9_.-]{1,32}$`)
+
+func ValidName(name string) bool {
+	return labelName.MatchString(name)
+}
+
+// Label is a part of the catalogue of a workspace, tasks refer to labels by name.
+type Label struct {
+	Name        string `json:"name"`
+	Color       string `json:"color"` // #rrggbb
+	Description string `json:"description,omitempty"`
+}
@@ -0,0 +1,192 @@
+package labels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+
+type (
+	// Repository keeps a catalogue of labels per workspace.
+	Repository interface {
+		// Create fails with ErrLabelExists if there is a label with the same name.
+		Create(ctx context.Context, workspace string, label Label) (Label, error)
+		Read(ctx context.Context, workspace, name string) (Label, error)
+		ReadAll(ctx context.Context, workspace string) ([]Label, error)
+		Update(ctx context.Context, workspace string, label Label) (Label, error)
+		// Delete removes the label from the catalogue and from every task that has it.
+		Delete(ctx context.Context, workspace, name string) error
+	}
+
+	Service interface {
+		Create(ctx context.Context, label Label) (Label, error)
+		Read(ctx context.Context, name string) (Label, error)
+		ReadAll(ctx context.Context) ([]Label, error)
+		// Update changes the color and description, empty fields keep their current values.
+		Update(ctx context.Context, label Label) (Label, error)
+		Delete(ctx context.Context, name string) error
+	}
+
+	service struct {
+		repo  Repository
+		guard auth.Guard
+		log   *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, policy auth.Authorizer, log *logging.Logger) service {
+	return service{
+		repo:  repo,
+		guard: auth.NewGuard(policy, log),
+		log:   log,
+	}
+}
+
+func (s service) Create(ctx context.Context, label Label) (Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "labels.Create")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "labels.Create")
+	if err != nil {
+		return Label{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "labels.Create", auth.ActionLabelsCreate, ""); err != nil {
+		return Label{}, err
+	}
+
+	if !ValidName(label.Name) {
+		return Label{}, fmt.Errorf("%w: %q", ErrInvalidLabel, label.Name)
+	}
+
+	l, err := s.repo.Create(ctx, ws, label)
+	if err != nil {
+		if errors.Is(err, ErrLabelExists) {
+			s.log.DebugContext(ctx, "labels.Create", logging.String("stage", "db"), logging.Error("err", err))
+			return Label{}, ErrLabelExists
+		}
+		s.log.ErrorContext(ctx, "labels.Create", logging.String("stage", "db"), logging.Error("err", err))
+		return Label{}, errors.New("failed to create label")
+	}
+	s.log.InfoContext(ctx, "labels.Create", logging.String("name", l.Name))
+	return l, nil
+}
+
+func (s service) Read(ctx context.Context, name string) (Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "labels.Read")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "labels.Read")
+	if err != nil {
+		return Label{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "labels.Read", auth.ActionTasksRead, ""); err != nil {
+		return Label{}, err
+	}
+
+	l, err := s.repo.Read(ctx, ws, name)
+	if err != nil {
+		if errors.Is(err, ErrLabelNotFound) {
+			s.log.DebugContext(ctx, "labels.Read", logging.String("stage", "db"), logging.Error("err", err))
+			return Label{}, ErrLabelNotFound
+		}
+		s.log.ErrorContext(ctx, "labels.Read", logging.String("stage", "db"), logging.Error("err", err))
+		return Label{}, errors.New("failed to read label")
+	}
+	return l, nil
+}
+
+func (s service) ReadAll(ctx context.Context) ([]Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "labels.ReadAll")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "labels.ReadAll")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "labels.ReadAll", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	labels, err := s.repo.ReadAll(ctx, ws)
+	if err != nil {
+		s.log.ErrorContext(ctx, "labels.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read labels")
+	}
+	s.log.InfoContext(ctx, "labels.ReadAll", logging.Int("count", len(labels)))
+	return labels, nil
+}
+
+func (s service) Update(ctx context.Context, label Label) (Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "labels.Update")
+	defer span.End()
+	defer s.log.Sync()
+
+	if err := s.guard.Authorize(ctx, "labels.Update", auth.ActionLabelsUpdate, ""); err != nil {
+		return Label{}, err
+	}
+
+	current, err := s.Read(ctx, label.Name)
+	if err != nil {
+		return Label{}, err
+	}
+	ws, _ := auth.WorkspaceFrom(ctx)
+
+	if label.Color != "" {
+		current.Color = label.Color
+	}
+	if label.Description != "" {
+		current.Description = label.Description
+	}
+
+	l, err := s.repo.Update(ctx, ws, current)
+	if err != nil {
+		if errors.Is(err, ErrLabelNotFound) {
+			s.log.DebugContext(ctx, "labels.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Label{}, ErrLabelNotFound
+		}
+		s.log.ErrorContext(ctx, "labels.Update", logging.String("stage", "db"), logging.Error("err", err))
+		return Label{}, errors.New("failed to update label")
+	}
+	s.log.InfoContext(ctx, "labels.Update", logging.String("name", l.Name))
+	return l, nil
+}
+
+func (s service) Delete(ctx context.Context, name string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "labels.Delete")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "labels.Delete")
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Authorize(ctx, "labels.Delete", auth.ActionLabelsDelete, ""); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, ws, name); err != nil {
+		if errors.Is(err, ErrLabelNotFound) {
+			s.log.DebugContext(ctx, "labels.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrLabelNotFound
+		}
+		s.log.ErrorContext(ctx, "labels.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete label")
+	}
+	s.log.InfoContext(ctx, "labels.Delete", logging.String("name", name))
+	return nil
+}
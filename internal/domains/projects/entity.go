@@ -0,0 +1,61 @@
+package projects
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrProjectNotFound = errors.New("project not found")
+	ErrColumnNotFound  = errors.New("column not found")
+	ErrInvalidColumns  = errors.New("board needs at least one column and column names have to be unique")
+	ErrProjectNotEmpty = errors.New("project still has tasks")
+	ErrColumnNotEmpty  = errors.New("column still has tasks")
+)
+
+// Project owns a board, tasks of the project are ordered within the columns of the board.
+type Project struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Columns     []Column  `json:"columns"` // in the order they are shown on the board
+	CreatedBy   string    `json:"createdBy,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// Column is mapped to a status of tasks, tasks moved to the column get that status.
+// Several columns can share a status, e.g. "review" and "in progress".
+type Column struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// Column returns the column with the id.
+func (p Project) Column(id string) (Column, bool) {
+	for _, c := range p.Columns {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// ColumnFor returns the first column mapped to the status.
+func (p Project) ColumnFor(status string) (Column, bool) {
+	for _, c := range p.Columns {
+		if c.Status == status {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// DefaultColumns are given to projects created without columns.
+func DefaultColumns() []Column {
+	return []Column{
+		{Name: "To do", Status: "todo"},
+		{Name: "In progress", Status: "in_progress"},
+		{Name: "Done", Status: "done"},
+	}
+}
@@ -0,0 +1,274 @@
+package projects
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+
+type (
+	// Repository keeps projects of every workspace apart, ids are given to projects and columns without them.
+	Repository interface {
+		Create(ctx context.Context, workspace string, project Project) (Project, error)
+		Read(ctx context.Context, workspace, id string) (Project, error)
+		ReadAll(ctx context.Context, workspace string) ([]Project, error)
+		// Update fails with ErrColumnNotEmpty if a column that still has tasks is left out.
+		Update(ctx context.Context, workspace string, project Project) (Project, error)
+		// Delete fails with ErrProjectNotEmpty if the project still has tasks.
+		Delete(ctx context.Context, workspace, id string) error
+	}
+
+	Service interface {
+		// Create gives the project DefaultColumns if it has none.
+		Create(ctx context.Context, project Project) (Project, error)
+		Read(ctx context.Context, id string) (Project, error)
+		ReadAll(ctx context.Context) ([]Project, error)
+		// Update changes the name and description, empty fields keep their current values.
+		// Columns are replaced if they are not nil, columns without ids are added.
+		Update(ctx context.Context, project Project) (Project, error)
+		Delete(ctx context.Context, id string) error
+	}
+
+	service struct {
+		repo  Repository
+		guard auth.Guard
+		log   *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, policy auth.Authorizer, log *logging.Logger) service {
+	return service{
+		repo:  repo,
+		guard: auth.NewGuard(policy, log),
+		log:   log,
+	}
+}
+
+// checkColumns makes sure there is at least one column and names are unique,
+// existing columns can only be referred to by ids that current has.
+func (s service) checkColumns(ctx context.Context, op string, columns []Column, current Project) error {
+	if len(columns) == 0 {
+		s.log.DebugContext(ctx, op, logging.String("stage", "columns"), logging.Error("err", ErrInvalidColumns))
+		return ErrInvalidColumns
+	}
+
+	names := make(map[string]bool, len(columns))
+	ids := make(map[string]bool, len(columns))
+	for _, c := range columns {
+		name := strings.ToLower(strings.TrimSpace(c.Name))
+		if name == "" || c.Status == "" || names[name] {
+			s.log.DebugContext(ctx, op, logging.String("stage", "columns"), logging.Error("err", ErrInvalidColumns))
+			return ErrInvalidColumns
+		}
+		names[name] = true
+
+		if c.ID == "" {
+			continue
+		}
+		if _, ok := current.Column(c.ID); !ok || ids[c.ID] {
+			s.log.DebugContext(ctx, op, logging.String("stage", "columns"), logging.Error("err", ErrColumnNotFound))
+			return ErrColumnNotFound
+		}
+		ids[c.ID] = true
+	}
+	return nil
+}
+
+func (s service) Create(ctx context.Context, project Project) (Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "projects.Create")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "projects.Create")
+	if err != nil {
+		return Project{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "projects.Create", auth.ActionProjectsCreate, ""); err != nil {
+		return Project{}, err
+	}
+
+	if len(project.Columns) == 0 {
+		project.Columns = DefaultColumns()
+	}
+	// Columns of a new project are all new.
+	for i := range project.Columns {
+		project.Columns[i].ID = ""
+	}
+	if err := s.checkColumns(ctx, "projects.Create", project.Columns, Project{}); err != nil {
+		return Project{}, err
+	}
+
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		project.CreatedBy = p.ID
+	}
+	project.CreatedAt = time.Now().UTC()
+
+	p, err := s.repo.Create(ctx, ws, project)
+	if err != nil {
+		s.log.ErrorContext(ctx, "projects.Create", logging.String("stage", "db"), logging.Error("err", err))
+		return Project{}, errors.New("failed to create project")
+	}
+	s.log.InfoContext(ctx, "projects.Create", logging.String("id", p.ID))
+	return p, nil
+}
+
+func (s service) Read(ctx context.Context, id string) (Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "projects.Read")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "projects.Read")
+	if err != nil {
+		return Project{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "projects.Read", auth.ActionTasksRead, ""); err != nil {
+		return Project{}, err
+	}
+
+	p, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			s.log.DebugContext(ctx, "projects.Read", logging.String("stage", "db"), logging.Error("err", err))
+			return Project{}, ErrProjectNotFound
+		}
+		s.log.ErrorContext(ctx, "projects.Read", logging.String("stage", "db"), logging.Error("err", err))
+		return Project{}, errors.New("failed to read project")
+	}
+	return p, nil
+}
+
+func (s service) ReadAll(ctx context.Context) ([]Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "projects.ReadAll")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "projects.ReadAll")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "projects.ReadAll", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	projects, err := s.repo.ReadAll(ctx, ws)
+	if err != nil {
+		s.log.ErrorContext(ctx, "projects.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read projects")
+	}
+	s.log.InfoContext(ctx, "projects.ReadAll", logging.Int("count", len(projects)))
+	return projects, nil
+}
+
+func (s service) Update(ctx context.Context, project Project) (Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "projects.Update")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "projects.Update")
+	if err != nil {
+		return Project{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "projects.Update", auth.ActionProjectsUpdate); err != nil {
+		return Project{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, project.ID)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			s.log.DebugContext(ctx, "projects.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Project{}, ErrProjectNotFound
+		}
+		s.log.ErrorContext(ctx, "projects.Update", logging.String("stage", "db"), logging.Error("err", err))
+		return Project{}, errors.New("failed to update project")
+	}
+
+	if err := s.guard.Authorize(ctx, "projects.Update", auth.ActionProjectsUpdate, current.CreatedBy); err != nil {
+		return Project{}, err
+	}
+
+	if project.Columns != nil {
+		if err := s.checkColumns(ctx, "projects.Update", project.Columns, current); err != nil {
+			return Project{}, err
+		}
+		current.Columns = project.Columns
+	}
+	if project.Name != "" {
+		current.Name = project.Name
+	}
+	if project.Description != "" {
+		current.Description = project.Description
+	}
+
+	p, err := s.repo.Update(ctx, ws, current)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			s.log.DebugContext(ctx, "projects.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Project{}, ErrProjectNotFound
+		}
+		if errors.Is(err, ErrColumnNotEmpty) {
+			s.log.DebugContext(ctx, "projects.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Project{}, ErrColumnNotEmpty
+		}
+		s.log.ErrorContext(ctx, "projects.Update", logging.String("stage", "db"), logging.Error("err", err))
+		return Project{}, errors.New("failed to update project")
+	}
+	s.log.InfoContext(ctx, "projects.Update", logging.String("id", p.ID))
+	return p, nil
+}
+
+func (s service) Delete(ctx context.Context, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "projects.Delete")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "projects.Delete")
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "projects.Delete", auth.ActionProjectsDelete); err != nil {
+		return err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			s.log.DebugContext(ctx, "projects.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrProjectNotFound
+		}
+		s.log.ErrorContext(ctx, "projects.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete project")
+	}
+
+	if err := s.guard.Authorize(ctx, "projects.Delete", auth.ActionProjectsDelete, current.CreatedBy); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, ws, id); err != nil {
+		if errors.Is(err, ErrProjectNotFound) {
+			s.log.DebugContext(ctx, "projects.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrProjectNotFound
+		}
+		if errors.Is(err, ErrProjectNotEmpty) {
+			s.log.DebugContext(ctx, "projects.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrProjectNotEmpty
+		}
+		s.log.ErrorContext(ctx, "projects.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete project")
+	}
+	s.log.InfoContext(ctx, "projects.Delete", logging.String("id", id))
+	return nil
+}
@@ -0,0 +1,83 @@
+package recurring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/rrule"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("recurring task template not found")
+	ErrInvalidRule      = errors.New("recurrence rule is invalid")
+	ErrInvalidTimezone  = errors.New("timezone is unknown")
+	// ErrCursorMoved is returned by Repository.Advance when another instance got to the template first.
+	ErrCursorMoved = errors.New("template cursor was moved")
+)
+
+// Template describes tasks that are created on a schedule, one for every occurrence of its rule.
+type Template struct {
+	ID          string   `json:"id"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Priority    string   `json:"priority,omitempty"`
+	Assignee    string   `json:"assignee,omitempty"`
+	Labels      []string `json:"labels,omitempty"`
+	ProjectID   string   `json:"projectId,omitempty"`
+	ColumnID    string   `json:"columnId,omitempty"`
+	// RRule is a recurrence rule like "FREQ=WEEKLY;BYDAY=MO,TH", see pkg/rrule for the supported subset.
+	RRule string `json:"rrule"`
+	// Start is the first moment the rule can occur at, occurrences keep its wall clock time in Timezone.
+	Start time.Time `json:"start"`
+	// Timezone is an IANA name like "Asia/Bishkek", the offset of Start is kept if it is empty.
+	Timezone string `json:"timezone,omitempty"`
+	// DueInMinutes is how long after its occurrence a task is due, tasks have no due date if it is 0.
+	DueInMinutes int       `json:"dueInMinutes,omitempty"`
+	CreatedBy    string    `json:"createdBy,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	// Cursor is the moment up to which occurrences are done with, it is maintained by the repository.
+	Cursor time.Time `json:"-"`
+	// Next is the next occurrence that has no task yet, nil once the rule has ended. It is ignored on writes.
+	Next *time.Time `json:"next,omitempty"`
+}
+
+// Schedule returns the parsed rule and the start in the location occurrences are computed in.
+func (t Template) Schedule() (rrule.Rule, time.Time, error) {
+	rule, err := rrule.Parse(t.RRule)
+	if err != nil {
+		detail := strings.TrimPrefix(err.Error(), rrule.ErrInvalidRule.Error()+": ")
+		return rrule.Rule{}, time.Time{}, fmt.Errorf("%w: %s", ErrInvalidRule, detail)
+	}
+	if t.Timezone == "" {
+		return rule, t.Start, nil
+	}
+	loc, err := time.LoadLocation(t.Timezone)
+	if err != nil {
+		return rrule.Rule{}, time.Time{}, fmt.Errorf("%w: %s", ErrInvalidTimezone, t.Timezone)
+	}
+	return rule, t.Start.In(loc), nil
+}
+
+// NextAfter returns the first occurrence after after, nil if there are no more.
+func (t Template) NextAfter(after time.Time) *time.Time {
+	rule, start, err := t.Schedule()
+	if err != nil {
+		return nil
+	}
+	next, ok := rule.Next(start, after)
+	if !ok {
+		return nil
+	}
+	return &next
+}
+
+// DueAt returns the due date of the task made for the occurrence.
+func (t Template) DueAt(occurrence time.Time) *time.Time {
+	if t.DueInMinutes <= 0 {
+		return nil
+	}
+	due := occurrence.Add(time.Duration(t.DueInMinutes) * time.Minute)
+	return &due
+}
@@ -0,0 +1,257 @@
+package recurring
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const (
+	// lockName is the lock a replica has to hold to run the scheduler.
+	lockName = "recurring"
+	// batchSize is how many templates are looked at in one run, the rest wait for the next one.
+	batchSize = 500
+)
+
+type (
+	// Locker hands a named lock to one holder at a time, a lock that isn't released expires after ttl.
+	Locker interface {
+		// Lock returns false if the lock is held by someone else.
+		Lock(ctx context.Context, name string, ttl time.Duration) (release func(context.Context) error, ok bool, err error)
+	}
+
+	// TaskCreator creates tasks made from templates, it is the tasks service.
+	TaskCreator interface {
+		Create(ctx context.Context, task tasks.Task) (tasks.Task, error)
+	}
+
+	SchedulerOptions struct {
+		// Lookahead is how long before their occurrence tasks are created.
+		Lookahead time.Duration
+		// MaxCatchUp is how many occurrences that are already past get tasks, e.g. after downtime.
+		// Only the latest ones do, older ones are skipped. With 0 every past occurrence is skipped,
+		// so Lookahead has to be longer than the interval between runs.
+		MaxCatchUp int
+		// LockTTL bounds how long a run holds the lock, it should be longer than any run takes.
+		LockTTL time.Duration
+	}
+
+	// Scheduler creates tasks for occurrences of templates of every workspace.
+	//
+	// Replicas take turns through the lock, and each occurrence is claimed by moving the cursor
+	// of its template before its task is created, so that no occurrence gets two tasks even if
+	// a run outlives the lock. A replica dying between the claim and the creation loses that occurrence.
+	Scheduler struct {
+		repo   Repository
+		tasks  TaskCreator
+		locker Locker
+		opts   SchedulerOptions
+		log    *logging.Logger
+	}
+)
+
+func NewScheduler(repo Repository, tasks TaskCreator, locker Locker, opts SchedulerOptions, log *logging.Logger) *Scheduler {
+	return &Scheduler{
+		repo:   repo,
+		tasks:  tasks,
+		locker: locker,
+		opts:   opts,
+		log:    log,
+	}
+}
+
+// Start runs the scheduler every interval until ctx is done.
+// The first run catches up with occurrences missed while no replica was running.
+func (s *Scheduler) Start(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		s.Run(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run creates tasks for occurrences up to Lookahead from now, unless another replica holds the lock.
+func (s *Scheduler) Run(ctx context.Context) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.Run")
+	defer span.End()
+	defer s.log.Sync()
+
+	release, ok, err := s.locker.Lock(ctx, lockName, s.opts.LockTTL)
+	if err != nil {
+		s.log.ErrorContext(ctx, "recurring.Run", logging.String("stage", "lock"), logging.Error("err", err))
+		return
+	}
+	if !ok {
+		s.log.DebugContext(ctx, "recurring.Run", logging.String("stage", "lock"), logging.String("holder", "another replica"))
+		return
+	}
+	defer func() {
+		// The lock is released even if ctx is done, it would block other replicas until it expires otherwise.
+		if err := release(context.Background()); err != nil {
+			s.log.ErrorContext(ctx, "recurring.Run", logging.String("stage", "unlock"), logging.Error("err", err))
+		}
+	}()
+
+	now := time.Now().UTC()
+	horizon := now.Add(s.opts.Lookahead)
+
+	refs, err := s.repo.Due(ctx, horizon, batchSize)
+	if err != nil {
+		s.log.ErrorContext(ctx, "recurring.Run", logging.String("stage", "db"), logging.Error("err", err))
+		return
+	}
+
+	created := 0
+	for _, ref := range refs {
+		if ctx.Err() != nil {
+			break
+		}
+		created += s.materialize(ctx, ref, now, horizon)
+	}
+	s.log.InfoContext(ctx, "recurring.Run", logging.Int("templates", len(refs)), logging.Int("tasks", created))
+}
+
+// materialize creates tasks for occurrences of the template up to horizon and returns how many it created.
+// It stops at the first task that can't be created for a reason that may go away, the occurrence is given back
+// and tried again on the next run.
+func (s *Scheduler) materialize(ctx context.Context, ref Ref, now, horizon time.Time) int {
+	t, err := s.repo.Read(ctx, ref.Workspace, ref.ID)
+	if err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			s.log.DebugContext(ctx, "recurring.materialize", logging.String("id", ref.ID), logging.Error("err", err))
+			return 0
+		}
+		s.log.ErrorContext(ctx, "recurring.materialize", logging.String("stage", "db"), logging.Error("err", err))
+		return 0
+	}
+
+	rule, start, err := t.Schedule()
+	if err != nil {
+		// Templates are checked when they are written, so this one can never be scheduled.
+		s.log.ErrorContext(ctx, "recurring.materialize", logging.String("id", t.ID), logging.Error("err", err))
+		s.advance(ctx, ref, t.Cursor, t.Cursor, nil)
+		return 0
+	}
+
+	occurrences := rule.Between(start, t.Cursor, horizon)
+	if len(occurrences) == 0 {
+		// The template was scheduled by an older version of it.
+		s.advance(ctx, ref, t.Cursor, t.Cursor, t.NextAfter(t.Cursor))
+		return 0
+	}
+
+	cursor := t.Cursor
+	missed := 0
+	for _, o := range occurrences {
+		if o.Before(now) {
+			missed++
+		}
+	}
+	if skip := missed - s.opts.MaxCatchUp; skip > 0 {
+		if !s.advance(ctx, ref, cursor, occurrences[skip-1], &occurrences[skip]) {
+			return 0
+		}
+		s.log.WarnContext(ctx, "recurring.materialize", logging.String("id", t.ID),
+			logging.String("stage", "catch up"), logging.Int("skipped", skip))
+		cursor = occurrences[skip-1]
+		occurrences = occurrences[skip:]
+	}
+
+	taskCtx := auth.WithWorkspace(auth.WithPrincipal(ctx, auth.Principal{
+		ID:         t.CreatedBy,
+		Roles:      []string{auth.RoleService},
+		Workspaces: []string{ref.Workspace},
+	}), ref.Workspace)
+
+	created := 0
+	for i, o := range occurrences {
+		next := t.NextAfter(o)
+		if i+1 < len(occurrences) {
+			next = &occurrences[i+1]
+		}
+		if !s.advance(ctx, ref, cursor, o, next) {
+			return created
+		}
+
+		task, err := s.tasks.Create(taskCtx, tasks.Task{
+			Title:       t.Title,
+			Description: t.Description,
+			Priority:    t.Priority,
+			Assignee:    t.Assignee,
+			Labels:      t.Labels,
+			ProjectID:   t.ProjectID,
+			ColumnID:    t.ColumnID,
+			DueAt:       t.DueAt(o),
+			TemplateID:  t.ID,
+		})
+		if err != nil && !permanent(err) {
+			if errors.Is(err, tasks.ErrQuotaExceeded) {
+				s.log.WarnContext(ctx, "recurring.materialize", logging.String("id", t.ID),
+					logging.String("stage", "quota"), logging.Error("err", err))
+			} else {
+				s.log.ErrorContext(ctx, "recurring.materialize", logging.String("id", t.ID),
+					logging.String("stage", "task"), logging.Error("err", err))
+			}
+			occurrence := o
+			s.advance(ctx, ref, o, cursor, &occurrence)
+			return created
+		}
+		cursor = o
+
+		if err != nil {
+			// The template refers to something that is gone, retrying won't help.
+			s.log.WarnContext(ctx, "recurring.materialize", logging.String("id", t.ID),
+				logging.String("stage", "task"), logging.String("occurrence", o.Format(time.RFC3339)), logging.Error("err", err))
+			continue
+		}
+		created++
+		s.log.InfoContext(ctx, "recurring.materialize", logging.String("id", t.ID), logging.String("task", task.ID))
+	}
+	return created
+}
+
+// advance moves the cursor and reports if it did,
+// it didn't if another replica got to the template first or the repository failed.
+func (s *Scheduler) advance(ctx context.Context, ref Ref, from, to time.Time, next *time.Time) bool {
+	err := s.repo.Advance(ctx, ref.Workspace, ref.ID, from, to, next)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, ErrCursorMoved), errors.Is(err, ErrTemplateNotFound):
+		s.log.DebugContext(ctx, "recurring.advance", logging.String("id", ref.ID), logging.Error("err", err))
+	default:
+		s.log.ErrorContext(ctx, "recurring.advance", logging.String("stage", "db"), logging.Error("err", err))
+	}
+	return false
+}
+
+// permanent reports if a task can't be created because of what the template says,
+// e.g. its assignee was deactivated or its project deleted.
+// A full task quota is not, tasks of the workspace may be deleted before the next run.
+func permanent(err error) bool {
+	for _, sentinel := range []error{
+		auth.ErrForbidden,
+		users.ErrUserNotFound, users.ErrUserInactive, labels.ErrLabelNotFound,
+		projects.ErrProjectNotFound, projects.ErrColumnNotFound,
+	} {
+		if errors.Is(err, sentinel) {
+			return true
+		}
+	}
+	return false
+}
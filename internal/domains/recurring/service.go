@@ -0,0 +1,301 @@
+package recurring
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
+
+// MaxOccurrences is how many upcoming occurrences can be previewed at once.
+const MaxOccurrences = 100
+
+type (
+	// Ref points to a template of a workspace.
+	Ref struct {
+		Workspace string
+		ID        string
+	}
+
+	// Repository keeps templates of every workspace apart, along with their cursors.
+	// Templates are scheduled across workspaces by their next occurrence, see Due.
+	Repository interface {
+		// Create gives the template an id and schedules it at next, it is not scheduled if next is nil.
+		Create(ctx context.Context, workspace string, template Template, next *time.Time) (Template, error)
+		Read(ctx context.Context, workspace, id string) (Template, error)
+		ReadAll(ctx context.Context, workspace string) ([]Template, error)
+		// Update replaces the template and schedules it at next, the cursor is only ever moved forward.
+		Update(ctx context.Context, workspace string, template Template, next *time.Time) (Template, error)
+		Delete(ctx context.Context, workspace, id string) error
+
+		// Due returns up to limit templates scheduled at or before before, the earliest first.
+		Due(ctx context.Context, before time.Time, limit int) ([]Ref, error)
+		// Advance moves the cursor of the template from from to to and schedules the template at next,
+		// it fails with ErrCursorMoved if the cursor is no longer at from.
+		Advance(ctx context.Context, workspace, id string, from, to time.Time, next *time.Time) error
+	}
+
+	Service interface {
+		// Create schedules the template from now on, occurrences before now never get tasks.
+		Create(ctx context.Context, template Template) (Template, error)
+		Read(ctx context.Context, id string) (Template, error)
+		ReadAll(ctx context.Context) ([]Template, error)
+		// Update replaces the template, occurrences of the new rule before now never get tasks either.
+		Update(ctx context.Context, template Template) (Template, error)
+		// Delete stops the schedule, tasks already made from the template are kept.
+		Delete(ctx context.Context, id string) error
+		// Occurrences returns up to n upcoming occurrences that have no tasks yet.
+		Occurrences(ctx context.Context, id string, n int) ([]time.Time, error)
+	}
+
+	service struct {
+		repo  Repository
+		guard auth.Guard
+		log   *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, policy auth.Authorizer, log *logging.Logger) service {
+	return service{
+		repo:  repo,
+		guard: auth.NewGuard(policy, log),
+		log:   log,
+	}
+}
+
+// checkSchedule returns the error of an invalid rule or timezone with its details,
+// they tell the client what to fix.
+func (s service) checkSchedule(ctx context.Context, op string, template Template) error {
+	if _, _, err := template.Schedule(); err != nil {
+		s.log.DebugContext(ctx, op, logging.String("stage", "schedule"), logging.Error("err", err))
+		return err
+	}
+	return nil
+}
+
+// read returns the template with its next occurrence.
+func (s service) read(ctx context.Context, op, ws, id string) (Template, error) {
+	t, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Template{}, ErrTemplateNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Template{}, errors.New("failed to read recurring task template")
+	}
+	t.Next = t.NextAfter(t.Cursor)
+	return t, nil
+}
+
+func (s service) Create(ctx context.Context, template Template) (Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.Create")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "recurring.Create")
+	if err != nil {
+		return Template{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "recurring.Create", auth.ActionRecurringCreate, ""); err != nil {
+		return Template{}, err
+	}
+
+	if err := s.checkSchedule(ctx, "recurring.Create", template); err != nil {
+		return Template{}, err
+	}
+	// Cursors are kept in milliseconds, occurrences must not fall between them.
+	template.Start = template.Start.Truncate(time.Second)
+
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		template.CreatedBy = p.ID
+	}
+	template.CreatedAt = time.Now().UTC()
+	template.Cursor = template.CreatedAt
+	template.Next = template.NextAfter(template.Cursor)
+
+	t, err := s.repo.Create(ctx, ws, template, template.Next)
+	if err != nil {
+		s.log.ErrorContext(ctx, "recurring.Create", logging.String("stage", "db"), logging.Error("err", err))
+		return Template{}, errors.New("failed to create recurring task template")
+	}
+	s.log.InfoContext(ctx, "recurring.Create", logging.String("id", t.ID))
+	return t, nil
+}
+
+func (s service) Read(ctx context.Context, id string) (Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.Read")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "recurring.Read")
+	if err != nil {
+		return Template{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "recurring.Read", auth.ActionTasksRead, ""); err != nil {
+		return Template{}, err
+	}
+
+	return s.read(ctx, "recurring.Read", ws, id)
+}
+
+func (s service) ReadAll(ctx context.Context) ([]Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.ReadAll")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "recurring.ReadAll")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "recurring.ReadAll", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	templates, err := s.repo.ReadAll(ctx, ws)
+	if err != nil {
+		s.log.ErrorContext(ctx, "recurring.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read recurring task templates")
+	}
+	for i := range templates {
+		templates[i].Next = templates[i].NextAfter(templates[i].Cursor)
+	}
+	s.log.InfoContext(ctx, "recurring.ReadAll", logging.Int("count", len(templates)))
+	return templates, nil
+}
+
+func (s service) Update(ctx context.Context, template Template) (Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.Update")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "recurring.Update")
+	if err != nil {
+		return Template{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "recurring.Update", auth.ActionRecurringUpdate); err != nil {
+		return Template{}, err
+	}
+
+	current, err := s.read(ctx, "recurring.Update", ws, template.ID)
+	if err != nil {
+		return Template{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "recurring.Update", auth.ActionRecurringUpdate, current.CreatedBy); err != nil {
+		return Template{}, err
+	}
+
+	if err := s.checkSchedule(ctx, "recurring.Update", template); err != nil {
+		return Template{}, err
+	}
+	// Cursors are kept in milliseconds, occurrences must not fall between them.
+	template.Start = template.Start.Truncate(time.Second)
+
+	template.CreatedBy = current.CreatedBy
+	template.CreatedAt = current.CreatedAt
+	// Occurrences that already have tasks are not made again, even if the new rule has them too.
+	template.Cursor = current.Cursor
+	if now := time.Now().UTC(); now.After(template.Cursor) {
+		template.Cursor = now
+	}
+	template.Next = template.NextAfter(template.Cursor)
+
+	t, err := s.repo.Update(ctx, ws, template, template.Next)
+	if err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			s.log.DebugContext(ctx, "recurring.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Template{}, ErrTemplateNotFound
+		}
+		s.log.ErrorContext(ctx, "recurring.Update", logging.String("stage", "db"), logging.Error("err", err))
+		return Template{}, errors.New("failed to update recurring task template")
+	}
+	s.log.InfoContext(ctx, "recurring.Update", logging.String("id", t.ID))
+	return t, nil
+}
+
+func (s service) Delete(ctx context.Context, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.Delete")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "recurring.Delete")
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "recurring.Delete", auth.ActionRecurringDelete); err != nil {
+		return err
+	}
+
+	current, err := s.read(ctx, "recurring.Delete", ws, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.Authorize(ctx, "recurring.Delete", auth.ActionRecurringDelete, current.CreatedBy); err != nil {
+		return err
+	}
+
+	if err := s.repo.Delete(ctx, ws, id); err != nil {
+		if errors.Is(err, ErrTemplateNotFound) {
+			s.log.DebugContext(ctx, "recurring.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrTemplateNotFound
+		}
+		s.log.ErrorContext(ctx, "recurring.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete recurring task template")
+	}
+	s.log.InfoContext(ctx, "recurring.Delete", logging.String("id", id))
+	return nil
+}
+
+func (s service) Occurrences(ctx context.Context, id string, n int) ([]time.Time, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "recurring.Occurrences")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "recurring.Occurrences")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "recurring.Occurrences", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	t, err := s.read(ctx, "recurring.Occurrences", ws, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule, start, err := t.Schedule()
+	if err != nil {
+		s.log.ErrorContext(ctx, "recurring.Occurrences", logging.String("stage", "schedule"), logging.Error("err", err))
+		return nil, errors.New("failed to compute occurrences")
+	}
+
+	if n <= 0 || n > MaxOccurrences {
+		n = MaxOccurrences
+	}
+	result := make([]time.Time, 0, n)
+	for after := t.Cursor; len(result) < n; {
+		next, ok := rule.Next(start, after)
+		if !ok {
+			break
+		}
+		result = append(result, next)
+		after = next
+	}
+	return result, nil
+}
@@ -0,0 +1,89 @@
+package tasks
+
+import (
+	"context"
+	"errors"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+func (s service) AddChecklistItem(ctx context.Context, id, text string, position int) (Task, error) {
+	return s.changeChecklist(ctx, "tasks.AddChecklistItem", id, func(ws string) error {
+		return s.repo.AddChecklistItem(ctx, ws, id, ChecklistItem{Text: text}, position)
+	})
+}
+
+func (s service) ChangeChecklistItem(ctx context.Context, id, itemID string, change ChecklistChange) (Task, error) {
+	return s.changeChecklist(ctx, "tasks.ChangeChecklistItem", id, func(ws string) error {
+		return s.repo.ChangeChecklistItem(ctx, ws, id, itemID, change)
+	})
+}
+
+func (s service) MoveChecklistItem(ctx context.Context, id, itemID string, position int) (Task, error) {
+	return s.changeChecklist(ctx, "tasks.MoveChecklistItem", id, func(ws string) error {
+		return s.repo.MoveChecklistItem(ctx, ws, id, itemID, position)
+	})
+}
+
+func (s service) DeleteChecklistItem(ctx context.Context, id, itemID string) (Task, error) {
+	return s.changeChecklist(ctx, "tasks.DeleteChecklistItem", id, func(ws string) error {
+		return s.repo.DeleteChecklistItem(ctx, ws, id, itemID)
+	})
+}
+
+// changeChecklist authorizes a change of the checklist like any other update of the task,
+// applies it and returns the task with its checklist as it is afterwards.
+// The change is never based on the checklist read here, the repository applies it atomically.
+func (s service) changeChecklist(ctx context.Context, op, id string, change func(ws string) error) (Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, op)
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, op)
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, op, auth.ActionTasksUpdate); err != nil {
+		return Task{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to change checklist")
+	}
+
+	if err := s.guard.Authorize(ctx, op, auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return Task{}, err
+	}
+
+	if err := change(ws); err != nil {
+		for _, sentinel := range []error{ErrTaskNotFound, ErrChecklistItemNotFound, ErrChecklistFull} {
+			if errors.Is(err, sentinel) {
+				s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+				return Task{}, sentinel
+			}
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to change checklist")
+	}
+
+	t, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to change checklist")
+	}
+	s.log.InfoContext(ctx, op, logging.String("id", id), auth.Actor(ctx))
+	return t, nil
+}
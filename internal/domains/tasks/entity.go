@@ -1,13 +1,214 @@
 package tasks
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+)
+
+const (
+	StatusTodo       = "todo"
+	StatusInProgress = "in_progress"
+	StatusDone       = "done"
+)
+
+// P0 is the most urgent.
+const (
+	PriorityP0 = "P0"
+	PriorityP1 = "P1"
+	PriorityP2 = "P2"
+	PriorityP3 = "P3"
+	PriorityP4 = "P4"
+
+	PriorityDefault = PriorityP2
+)
+
+// Relations of a user to a task.
+const (
+	RelationAssignee = "assignee"
+	RelationReporter = "reporter"
+)
+
+// What happens to subtasks when their parent is deleted.
+const (
+	OnParentDeleteReject  = "reject"  // the parent can't be deleted while it has subtasks
+	OnParentDeleteOrphan  = "orphan"  // subtasks become top level tasks
+	OnParentDeleteCascade = "cascade" // subtasks are deleted with their parent
+)
+
+// Types of links between tasks, a link goes from one task to another, e.g. From blocks To.
+// Only blocking links take part in dependency graphs.
+const (
+	LinkBlocks     = "blocks"
+	LinkRelatesTo  = "relates_to"
+	LinkDuplicates = "duplicates"
+)
+
+// MaxParentDepth is how many ancestors a task can have, it also bounds walks up from a parent.
+const MaxParentDepth = 50
+
+// Walks through blocking links stop after MaxGraphDepth links in a row or MaxGraphNodes tasks,
+// so that a huge dependency graph can't make a request read all of it.
+const (
+	MaxGraphDepth = 50
+	MaxGraphNodes = 500
+)
 
 var (
-	ErrTaskNotFound = errors.New("task not found")
+	ErrTaskNotFound   = errors.New("task not found")
+	ErrQuotaExceeded  = errors.New("workspace task quota exceeded")
+	ErrParentNotFound = errors.New("parent task not found")
+	ErrParentCycle    = errors.New("task can't be a subtask of itself or of its subtasks")
+	ErrParentTooDeep  = errors.New("subtasks can't be nested that deep")
+	ErrHasSubtasks    = errors.New("task has subtasks")
+
+	ErrLinkNotFound       = errors.New("link not found")
+	ErrLinkedTaskNotFound = errors.New("linked task not found")
+	ErrInvalidLink        = errors.New("link must be of a known type between two different tasks")
+	ErrLinkCycle          = errors.New("blocking links can't form a cycle")
+	ErrGraphTooLarge      = errors.New("dependency graph is too large to check for cycles")
+	ErrBlocked            = errors.New("task is blocked by tasks that are not done")
+
+	ErrChecklistItemNotFound = errors.New("checklist item not found")
+	ErrChecklistFull         = errors.New("checklist has too many items")
 )
 
+// MaxChecklistItems is how many items a checklist of a task can have.
+const MaxChecklistItems = 100
+
 type Task struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	ID          string     `json:"id"`
+	WorkspaceID string     `json:"workspaceId"`
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"`
+	Priority    string     `json:"priority"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`     // keeps the offset it was set with
+	CreatedBy   string     `json:"createdBy,omitempty"` // ID of the principal who created the task
+	Assignee    string     `json:"assignee,omitempty"`  // ID of the user working on the task
+	Reporter    string     `json:"reporter,omitempty"`  // ID of the user who asked for the task
+	Labels      []string   `json:"labels"`              // names of labels from the catalogue of the workspace
+	ParentID    string     `json:"parentId,omitempty"`
+	ProjectID   string     `json:"projectId,omitempty"`
+	ColumnID    string     `json:"columnId,omitempty"` // column of the board of the project
+	Rank        string     `json:"rank,omitempty"`     // orders tasks within the column, see pkg/rank
+	// TemplateID is the recurring template the task was made from, it is only set when the task is created.
+	TemplateID string `json:"templateId,omitempty"`
+	// CommentCount, AttachmentCount and Subtasks are maintained by the repository, they are ignored on writes.
+	CommentCount    int       `json:"commentCount"`
+	AttachmentCount int       `json:"attachmentCount"`
+	Subtasks        *Progress `json:"subtasks,omitempty"` // nil if the task has no subtasks
+	// Checklist is changed item by item, it is ignored on writes as well.
+	Checklist *Checklist `json:"checklist,omitempty"` // nil if the checklist is empty
+}
+
+// TaskChange changes fields of a task, nil fields are left as they are.
+// A due date is removed with ClearDueAt, since a nil DueAt leaves it as well.
+type TaskChange struct {
+	Title       *string
+	Description *string
+	Status      *string
+	Priority    *string
+	DueAt       *time.Time
+	ClearDueAt  bool
+}
+
+// Checklist is an ordered list of small steps of a task that don't deserve subtasks.
+type Checklist struct {
+	Items      []ChecklistItem `json:"items"`
+	Completion int             `json:"completion"` // percentage of done items, rounded down
+}
+
+type ChecklistItem struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+	Done bool   `json:"done"`
+}
+
+// NewChecklist returns nil for no items, as tasks without a checklist have none.
+func NewChecklist(items []ChecklistItem) *Checklist {
+	if len(items) == 0 {
+		return nil
+	}
+	done := 0
+	for _, item := range items {
+		if item.Done {
+			done++
+		}
+	}
+	return &Checklist{Items: items, Completion: done * 100 / len(items)}
+}
+
+// ChecklistChange changes an item, nil fields are left as they are.
+type ChecklistChange struct {
+	Text *string
+	Done *bool
+}
+
+// Progress rolls up statuses of the direct subtasks of a task.
+type Progress struct {
+	Done  int `json:"done"`
+	Total int `json:"total"`
+}
+
+// Overdue reports if the task is due before now and not done yet.
+func (t Task) Overdue(now time.Time) bool {
+	return t.DueAt != nil && t.DueAt.Before(now) && t.Status != StatusDone
+}
+
+// Board is a project with its columns filled with tasks, ordered by rank.
+type Board struct {
+	Project projects.Project `json:"project"`
+	Columns []BoardColumn    `json:"columns"`
+}
+
+type BoardColumn struct {
+	projects.Column
+	Tasks []Task `json:"tasks"`
+}
+
+// Position of a task in a column of a board.
+type Position struct {
+	TaskID string
+	Rank   string
+}
+
+type Link struct {
+	Type string `json:"type"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Graph is the part of the dependency graph reachable from a task through blocking links,
+// both the tasks it waits for and the tasks waiting for it.
+// Truncated is set if the graph is larger than MaxGraphDepth or MaxGraphNodes allow and only a part of it is returned.
+type Graph struct {
+	Nodes     []Task `json:"nodes"`
+	Edges     []Link `json:"edges"`
+	Truncated bool   `json:"truncated"`
+}
+
+// ValidLinkType reports if v is one of the Link constants.
+func ValidLinkType(v string) bool {
+	switch v {
+	case LinkBlocks, LinkRelatesTo, LinkDuplicates:
+		return true
+	}
+	return false
+}
+
+// ValidOnParentDelete reports if v is one of the OnParentDelete constants.
+func ValidOnParentDelete(v string) bool {
+	switch v {
+	case OnParentDeleteReject, OnParentDeleteOrphan, OnParentDeleteCascade:
+		return true
+	}
+	return false
+}
+
+// Filter narrows down ReadAll, the zero value matches every task.
+type Filter struct {
+	Labels   []string
+	MatchAll bool // tasks need to have all of Labels instead of any of them
 }
@@ -3,43 +3,307 @@ package tasks
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
 	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/rank"
 	"go.opentelemetry.io/otel"
 )
 
 const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
 
 type (
+	// Repository keeps tasks of every workspace apart,
+	// a task can never be reached through a workspace it doesn't belong to.
 	Repository interface {
-		Create(ctx context.Context, task Task) (Task, error)
-		Read(ctx context.Context, id string) (Task, error)
-		ReadAll(ctx context.Context) ([]Task, error)
-		Update(ctx context.Context, task Task) (Task, error)
-		Delete(ctx context.Context, id string) error
+		// Create fails with ErrQuotaExceeded if the workspace has limit tasks already, 0 means no limit.
+		Create(ctx context.Context, workspace string, task Task, limit int) (Task, error)
+		Read(ctx context.Context, workspace, id string) (Task, error)
+		ReadAll(ctx context.Context, workspace string, filter Filter) ([]Task, error)
+		// Update reads the task, changes it with change and writes it back in one transaction,
+		// so that concurrent changes of other fields are not lost. change may run more than once
+		// if the task is changed meanwhile, errors it returns are returned as is.
+		Update(ctx context.Context, workspace, id string, change func(task *Task) error) (Task, error)
+		// Delete fails with ErrHasSubtasks if the task has subtasks, unless orphan is true,
+		// in which case they become top level tasks in the same transaction.
+		Delete(ctx context.Context, workspace, id string, orphan bool) error
+		// ReadAllByUser returns tasks the user is related to as relation, one of the Relation constants.
+		ReadAllByUser(ctx context.Context, workspace, relation, userID string) ([]Task, error)
+		// ReadDueBefore returns tasks that are not done and due before t, the earliest due first.
+		ReadDueBefore(ctx context.Context, workspace string, t time.Time) ([]Task, error)
+		// ReadSubtasks returns the direct subtasks of the task.
+		ReadSubtasks(ctx context.Context, workspace, id string) ([]Task, error)
+		// AddLink fails with ErrTaskNotFound or ErrLinkedTaskNotFound if either task doesn't exist,
+		// and with ErrLinkCycle if a blocking link would make a cycle.
+		AddLink(ctx context.Context, workspace string, link Link) error
+		RemoveLink(ctx context.Context, workspace string, link Link) error
+		// ReadLinks returns links from and to the task.
+		ReadLinks(ctx context.Context, workspace, id string) ([]Link, error)
+		// ReadLinksOf is ReadLinks for many tasks at once, links are keyed by the task they were read for.
+		ReadLinksOf(ctx context.Context, workspace string, ids []string) (map[string][]Link, error)
+		// ReadMany returns the tasks that exist out of ids, in the same order.
+		ReadMany(ctx context.Context, workspace string, ids []string) ([]Task, error)
+		// ReadColumn returns tasks in the column of the board of the project, ordered by rank.
+		ReadColumn(ctx context.Context, workspace, projectID, columnID string) ([]Task, error)
+		// ReadPositions is a cheaper ReadColumn for when only the order is needed.
+		ReadPositions(ctx context.Context, workspace, projectID, columnID string) ([]Position, error)
+		// Checklist items are changed one at a time and atomically, so that concurrent changes of
+		// different items never overwrite each other. Each of these fails with ErrTaskNotFound,
+		// and all but AddChecklistItem with ErrChecklistItemNotFound. Positions count from 0,
+		// a negative or too large position means the end of the checklist.
+		AddChecklistItem(ctx context.Context, workspace, id string, item ChecklistItem, position int) error
+		ChangeChecklistItem(ctx context.Context, workspace, id, itemID string, change ChecklistChange) error
+		MoveChecklistItem(ctx context.Context, workspace, id, itemID string, position int) error
+		DeleteChecklistItem(ctx context.Context, workspace, id, itemID string) error
+	}
+
+	// UserReader is used to check that assignees and reporters exist in the workspace of the task.
+	UserReader interface {
+		Read(ctx context.Context, id string) (users.User, error)
+	}
+
+	// LabelReader is used to check that labels are in the catalogue of the workspace.
+	LabelReader interface {
+		Read(ctx context.Context, name string) (labels.Label, error)
+	}
+
+	// ProjectReader is used to find columns of boards.
+	ProjectReader interface {
+		Read(ctx context.Context, id string) (projects.Project, error)
+	}
+
+	// AttachmentRemover deletes files attached to a task before the task itself is deleted,
+	// the repository only knows about their metadata.
+	AttachmentRemover interface {
+		DeleteAll(ctx context.Context, workspace, taskID string) error
 	}
 
 	Service interface {
 		Create(ctx context.Context, task Task) (Task, error)
 		Read(ctx context.Context, id string) (Task, error)
-		ReadAll(ctx context.Context) ([]Task, error)
-		Update(ctx context.Context, task Task) (Task, error)
+		ReadAll(ctx context.Context, filter Filter) ([]Task, error)
+		// Update changes title, description, status, priority and due date of the task,
+		// the rest is changed by the operations below.
+		Update(ctx context.Context, id string, change TaskChange) (Task, error)
 		Delete(ctx context.Context, id string) error
+		// Assign hands the task over to the user, an empty userID unassigns it.
+		Assign(ctx context.Context, id, userID string) (Task, error)
+		ReadAllByUser(ctx context.Context, userID, relation string) ([]Task, error)
+		AddLabel(ctx context.Context, id, label string) (Task, error)
+		RemoveLabel(ctx context.Context, id, label string) (Task, error)
+		// ReadOverdue returns tasks that are past their due date and not done, the most overdue first.
+		ReadOverdue(ctx context.Context) ([]Task, error)
+		ReadSubtasks(ctx context.Context, id string) ([]Task, error)
+		// SetParent makes the task a subtask of the parent, an empty parentID makes it a top level task.
+		SetParent(ctx context.Context, id, parentID string) (Task, error)
+		// AddLink refuses blocking links that would make a cycle.
+		AddLink(ctx context.Context, link Link) error
+		RemoveLink(ctx context.Context, link Link) error
+		// ReadLinks returns links from and to the task.
+		ReadLinks(ctx context.Context, id string) ([]Link, error)
+		ReadGraph(ctx context.Context, id string) (Graph, error)
+		// Move puts the task to the column of the board of the project at position, counting from 0,
+		// or to the end of the column if position is negative. The task gets the status of the column.
+		// An empty projectID keeps the task in its current project.
+		Move(ctx context.Context, id, projectID, columnID string, position int) (Task, error)
+		ReadBoard(ctx context.Context, projectID string) (Board, error)
+		// AddChecklistItem puts a new item at position, counting from 0, or at the end if position is negative.
+		AddChecklistItem(ctx context.Context, id, text string, position int) (Task, error)
+		ChangeChecklistItem(ctx context.Context, id, itemID string, change ChecklistChange) (Task, error)
+		// MoveChecklistItem puts the item at position, counting from 0, or at the end if position is negative.
+		MoveChecklistItem(ctx context.Context, id, itemID string, position int) (Task, error)
+		DeleteChecklistItem(ctx context.Context, id, itemID string) (Task, error)
 	}
 
 	service struct {
-		repo Repository
-		log  *logging.Logger
+		repo           Repository
+		users          UserReader
+		labels         LabelReader
+		projects       ProjectReader
+		attachments    AttachmentRemover
+		guard          auth.Guard
+		maxTasks       int    // per workspace, 0 means unlimited
+		onParentDelete string // one of the OnParentDelete constants
+		log            *logging.Logger
 	}
 )
 
 var _ Service = (*service)(nil)
 
-func NewService(repo Repository, log *logging.Logger) service {
+func NewService(
+	repo Repository,
+	users UserReader,
+	labels LabelReader,
+	projects ProjectReader,
+	attachments AttachmentRemover,
+	policy auth.Authorizer,
+	maxTasks int,
+	onParentDelete string,
+	log *logging.Logger,
+) service {
 	return service{
-		repo: repo,
-		log:  log,
+		repo:           repo,
+		users:          users,
+		labels:         labels,
+		projects:       projects,
+		attachments:    attachments,
+		guard:          auth.NewGuard(policy, log),
+		maxTasks:       maxTasks,
+		onParentDelete: onParentDelete,
+		log:            log,
+	}
+}
+
+// checkUser makes sure a user can be assigned to or report a task.
+// Users are read in the workspace of ctx, so members of other workspaces are not found.
+func (s service) checkUser(ctx context.Context, op, id string) error {
+	u, err := s.users.Read(ctx, id)
+	if err != nil {
+		s.log.DebugContext(ctx, op, logging.String("stage", "user"), logging.Error("err", err))
+		return err
+	}
+	if !u.Active {
+		s.log.DebugContext(ctx, op, logging.String("stage", "user"), logging.Error("err", users.ErrUserInactive))
+		return users.ErrUserInactive
+	}
+	return nil
+}
+
+// checkLabels makes sure every label is in the catalogue and returns them without duplicates.
+func (s service) checkLabels(ctx context.Context, op string, names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		if _, err := s.labels.Read(ctx, name); err != nil {
+			s.log.DebugContext(ctx, op, logging.String("stage", "label"), logging.Error("err", err))
+			return nil, err
+		}
+		result = append(result, name)
+	}
+	return result, nil
+}
+
+// checkParent makes sure the parent exists and that the task is not among its ancestors.
+// The repository checks it again when the task is written, this only fails early with a clear error.
+func (s service) checkParent(ctx context.Context, op, ws, id, parentID string) error {
+	seen := make(map[string]bool)
+	for current := parentID; current != ""; {
+		// A task seen twice means the ancestors already loop, which must not be made worse.
+		if current == id || seen[current] {
+			s.log.DebugContext(ctx, op, logging.String("stage", "parent"), logging.Error("err", ErrParentCycle))
+			return ErrParentCycle
+		}
+		if len(seen) == MaxParentDepth {
+			s.log.DebugContext(ctx, op, logging.String("stage", "parent"), logging.Error("err", ErrParentTooDeep))
+			return ErrParentTooDeep
+		}
+		seen[current] = true
+
+		t, err := s.repo.Read(ctx, ws, current)
+		if err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				s.log.DebugContext(ctx, op, logging.String("stage", "parent"), logging.Error("err", err))
+				return ErrParentNotFound
+			}
+			s.log.ErrorContext(ctx, op, logging.String("stage", "parent"), logging.Error("err", err))
+			return errors.New("failed to read parent task")
+		}
+		current = t.ParentID
+	}
+	return nil
+}
+
+// putOnBoard puts a new task to the end of its column, or of the first column for its status if it has none,
+// and gives it the status of the column.
+func (s service) putOnBoard(ctx context.Context, op, ws string, task *Task) error {
+	project, err := s.projects.Read(ctx, task.ProjectID)
+	if err != nil {
+		s.log.DebugContext(ctx, op, logging.String("stage", "project"), logging.Error("err", err))
+		return err
+	}
+
+	column, ok := project.Column(task.ColumnID)
+	if task.ColumnID == "" {
+		if column, ok = project.ColumnFor(task.Status); !ok {
+			column, ok = project.Columns[0], true
+		}
+	}
+	if !ok {
+		s.log.DebugContext(ctx, op, logging.String("stage", "project"), logging.Error("err", projects.ErrColumnNotFound))
+		return projects.ErrColumnNotFound
+	}
+
+	task.ColumnID = column.ID
+	task.Status = column.Status
+	task.Rank, err = s.rank(ctx, op, ws, *task, -1)
+	return err
+}
+
+// followStatus moves a task, which status has changed, to the end of the first column for the new status.
+// The task stays where it is if there is no such column.
+func (s service) followStatus(ctx context.Context, op, ws string, task *Task) error {
+	project, err := s.projects.Read(ctx, task.ProjectID)
+	if err != nil {
+		s.log.ErrorContext(ctx, op, logging.String("stage", "project"), logging.Error("err", err))
+		return errors.New("failed to read project")
+	}
+
+	if current, ok := project.Column(task.ColumnID); ok && current.Status == task.Status {
+		return nil
+	}
+	column, ok := project.ColumnFor(task.Status)
+	if !ok {
+		return nil
+	}
+
+	task.ColumnID = column.ID
+	task.Rank, err = s.rank(ctx, op, ws, *task, -1)
+	return err
+}
+
+// rank returns the rank that puts the task at position in its column, the task itself is not counted.
+// Negative positions and positions past the end put the task at the end.
+func (s service) rank(ctx context.Context, op, ws string, task Task, position int) (string, error) {
+	positions, err := s.repo.ReadPositions(ctx, ws, task.ProjectID, task.ColumnID)
+	if err != nil {
+		s.log.ErrorContext(ctx, op, logging.String("stage", "rank"), logging.Error("err", err))
+		return "", errors.New("failed to rank task")
+	}
+
+	others := make([]Position, 0, len(positions))
+	for _, p := range positions {
+		if p.TaskID != task.ID {
+			others = append(others, p)
+		}
+	}
+	if position < 0 || position > len(others) {
+		position = len(others)
+	}
+
+	var prev, next string
+	if position > 0 {
+		prev = others[position-1].Rank
+	}
+	if position < len(others) {
+		next = others[position].Rank
+	}
+
+	r, err := rank.Between(prev, next)
+	if err != nil {
+		s.log.ErrorContext(ctx, op, logging.String("stage", "rank"), logging.Error("err", err),
+			logging.String("prev", prev), logging.String("next", next))
+		return "", errors.New("failed to rank task")
 	}
+	return r, nil
 }
 
 func (s service) Create(ctx context.Context, task Task) (Task, error) {
@@ -47,12 +311,67 @@ func (s service) Create(ctx context.Context, task Task) (Task, error) {
 	defer span.End()
 	defer s.log.Sync()
 
-	t, err := s.repo.Create(ctx, task)
+	ws, err := s.guard.Workspace(ctx, "tasks.Create")
 	if err != nil {
-		s.log.Error("tasks.Create", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.Create", auth.ActionTasksCreate, ""); err != nil {
+		return Task{}, err
+	}
+
+	for _, id := range []string{task.Assignee, task.Reporter} {
+		if id == "" {
+			continue
+		}
+		if err := s.checkUser(ctx, "tasks.Create", id); err != nil {
+			return Task{}, err
+		}
+	}
+
+	if task.Labels, err = s.checkLabels(ctx, "tasks.Create", task.Labels); err != nil {
+		return Task{}, err
+	}
+
+	if err := s.checkParent(ctx, "tasks.Create", ws, "", task.ParentID); err != nil {
+		return Task{}, err
+	}
+	task.Subtasks = nil
+
+	if p, ok := auth.PrincipalFrom(ctx); ok {
+		task.CreatedBy = p.ID
+	}
+	if task.Status == "" {
+		task.Status = StatusTodo
+	}
+	if task.Priority == "" {
+		task.Priority = PriorityDefault
+	}
+
+	task.Rank = ""
+	if task.ProjectID == "" {
+		task.ColumnID = ""
+	} else if err := s.putOnBoard(ctx, "tasks.Create", ws, &task); err != nil {
+		return Task{}, err
+	}
+
+	// The quota is checked when the task is written, so that tasks created at once can't exceed it.
+	t, err := s.repo.Create(ctx, ws, task, s.maxTasks)
+	if err != nil {
+		// The label, the parent or the project changed since they were checked.
+		for _, sentinel := range []error{
+			ErrQuotaExceeded, labels.ErrLabelNotFound, ErrParentNotFound, ErrParentTooDeep,
+			projects.ErrProjectNotFound, projects.ErrColumnNotFound,
+		} {
+			if errors.Is(err, sentinel) {
+				s.log.DebugContext(ctx, "tasks.Create", logging.String("stage", "db"), logging.Error("err", err))
+				return Task{}, sentinel
+			}
+		}
+		s.log.ErrorContext(ctx, "tasks.Create", logging.String("stage", "db"), logging.Error("err", err))
 		return Task{}, errors.New("failed to create task")
 	}
-	s.log.Info("tasks.Create", logging.String("id", t.ID))
+	s.log.InfoContext(ctx, "tasks.Create", logging.String("id", t.ID), auth.Actor(ctx))
 	return t, nil
 }
 
@@ -61,52 +380,129 @@ func (s service) Read(ctx context.Context, id string) (Task, error) {
 	defer span.End()
 	defer s.log.Sync()
 
-	t, err := s.repo.Read(ctx, id)
+	ws, err := s.guard.Workspace(ctx, "tasks.Read")
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.Read", auth.ActionTasksRead, ""); err != nil {
+		return Task{}, err
+	}
+
+	t, err := s.repo.Read(ctx, ws, id)
 	if err != nil {
 		if errors.Is(err, ErrTaskNotFound) {
-			s.log.Debug("tasks.Read", logging.String("stage", "db"), logging.Error("err", err))
+			s.log.DebugContext(ctx, "tasks.Read", logging.String("stage", "db"), logging.Error("err", err))
 			return Task{}, ErrTaskNotFound
 		}
-		s.log.Error("tasks.Read", logging.String("stage", "db"), logging.Error("err", err))
+		s.log.ErrorContext(ctx, "tasks.Read", logging.String("stage", "db"), logging.Error("err", err))
 		return Task{}, errors.New("failed to read task")
 	}
-	s.log.Info("tasks.Read", logging.String("id", t.ID))
+	s.log.InfoContext(ctx, "tasks.Read", logging.String("id", t.ID), auth.Actor(ctx))
 	return t, nil
 }
 
-func (s service) ReadAll(ctx context.Context) ([]Task, error) {
+func (s service) ReadAll(ctx context.Context, filter Filter) ([]Task, error) {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadAll")
 	defer span.End()
 	defer s.log.Sync()
 
-	tasks, err := s.repo.ReadAll(ctx)
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadAll")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadAll", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.repo.ReadAll(ctx, ws, filter)
 	if err != nil {
 		if errors.Is(err, ErrTaskNotFound) {
-			s.log.Debug("tasks.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+			s.log.DebugContext(ctx, "tasks.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
 			return nil, ErrTaskNotFound
 		}
-		s.log.Error("tasks.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		s.log.ErrorContext(ctx, "tasks.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
 		return nil, errors.New("failed to read tasks")
 	}
-	s.log.Info("tasks.ReadAll", logging.Int("count", len(tasks)))
+	s.log.InfoContext(ctx, "tasks.ReadAll", logging.Int("count", len(tasks)), auth.Actor(ctx))
 	return tasks, nil
 }
 
-func (s service) Update(ctx context.Context, task Task) (Task, error) {
+func (s service) Update(ctx context.Context, id string, change TaskChange) (Task, error) {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.Update")
 	defer span.End()
 	defer s.log.Sync()
 
-	t, err := s.repo.Update(ctx, task)
+	ws, err := s.guard.Workspace(ctx, "tasks.Update")
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.Update", auth.ActionTasksUpdate); err != nil {
+		return Task{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
 	if err != nil {
 		if errors.Is(err, ErrTaskNotFound) {
-			s.log.Debug("tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
+			s.log.DebugContext(ctx, "tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
 			return Task{}, ErrTaskNotFound
 		}
-		s.log.Error("tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
+		s.log.ErrorContext(ctx, "tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
 		return Task{}, errors.New("failed to update task")
 	}
-	s.log.Info("tasks.Update", logging.String("id", t.ID))
+
+	if err := s.guard.Authorize(ctx, "tasks.Update", auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return Task{}, err
+	}
+
+	if change.Status != nil && *change.Status == StatusDone && current.Status != StatusDone {
+		if err := s.checkBlockers(ctx, "tasks.Update", ws, id); err != nil {
+			return Task{}, err
+		}
+	}
+
+	// People are changed with Assign, labels with AddLabel and RemoveLabel, parent with SetParent
+	// and position on the board with Move, though a new status can take the task to another column.
+	t, err := s.repo.Update(ctx, ws, id, func(t *Task) error {
+		if change.Title != nil {
+			t.Title = *change.Title
+		}
+		if change.Description != nil {
+			t.Description = *change.Description
+		}
+		if change.Priority != nil {
+			t.Priority = *change.Priority
+		}
+		if change.DueAt != nil || change.ClearDueAt {
+			t.DueAt = change.DueAt
+		}
+		if change.Status == nil || *change.Status == t.Status {
+			return nil
+		}
+		t.Status = *change.Status
+		if t.ProjectID != "" {
+			return s.followStatus(ctx, "tasks.Update", ws, t)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		// The project changed since the column for the new status was found.
+		for _, sentinel := range []error{labels.ErrLabelNotFound, projects.ErrProjectNotFound, projects.ErrColumnNotFound} {
+			if errors.Is(err, sentinel) {
+				s.log.DebugContext(ctx, "tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
+				return Task{}, sentinel
+			}
+		}
+		s.log.ErrorContext(ctx, "tasks.Update", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to update task")
+	}
+	s.log.InfoContext(ctx, "tasks.Update", logging.String("id", t.ID), auth.Actor(ctx))
 	return t, nil
 }
 
@@ -115,15 +511,803 @@ func (s service) Delete(ctx context.Context, id string) error {
 	defer span.End()
 	defer s.log.Sync()
 
-	err := s.repo.Delete(ctx, id)
+	ws, err := s.guard.Workspace(ctx, "tasks.Delete")
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.Delete", auth.ActionTasksDelete); err != nil {
+		return err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
 	if err != nil {
 		if errors.Is(err, ErrTaskNotFound) {
-			s.log.Debug("tasks.Delete", logging.String("stage", "db"), logging.Error("err", err))
+			s.log.DebugContext(ctx, "tasks.Delete", logging.String("stage", "db"), logging.Error("err", err))
 			return ErrTaskNotFound
 		}
-		s.log.Error("tasks.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		s.log.ErrorContext(ctx, "tasks.Delete", logging.String("stage", "db"), logging.Error("err", err))
 		return errors.New("failed to delete task")
 	}
-	s.log.Info("tasks.Delete", logging.String("id", id))
+
+	if err := s.guard.Authorize(ctx, "tasks.Delete", auth.ActionTasksDelete, current.CreatedBy); err != nil {
+		return err
+	}
+
+	// Subtasks are orphaned by the repository, so that ones added meanwhile are orphaned too.
+	orphan := s.onParentDelete == OnParentDeleteOrphan
+	if current.Subtasks != nil && !orphan {
+		if err := s.deleteSubtasks(ctx, ws, id); err != nil {
+			return err
+		}
+	}
+
+	err = s.remove(ctx, ws, id, orphan)
+	if err != nil {
+		for _, sentinel := range []error{ErrTaskNotFound, ErrHasSubtasks} {
+			if errors.Is(err, sentinel) {
+				s.log.DebugContext(ctx, "tasks.Delete", logging.String("stage", "db"), logging.Error("err", err))
+				return sentinel
+			}
+		}
+		s.log.ErrorContext(ctx, "tasks.Delete", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to delete task")
+	}
+	s.log.InfoContext(ctx, "tasks.Delete", logging.String("id", id), auth.Actor(ctx))
 	return nil
 }
+
+// remove deletes the task with its attachments, the attachments go first,
+// so that a failure leaves the task to be deleted again instead of leaking files.
+func (s service) remove(ctx context.Context, ws, id string, orphan bool) error {
+	if err := s.attachments.DeleteAll(ctx, ws, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, ws, id, orphan)
+}
+
+// deleteSubtasks handles subtasks of a task that is about to be deleted when they are not orphaned.
+// Subtasks added after this are caught by the repository, which refuses to delete a task that has any.
+func (s service) deleteSubtasks(ctx context.Context, ws, id string) error {
+	subtasks, err := s.repo.ReadSubtasks(ctx, ws, id)
+	if err != nil {
+		s.log.ErrorContext(ctx, "tasks.Delete", logging.String("stage", "subtasks"), logging.Error("err", err))
+		return errors.New("failed to delete task")
+	}
+	if len(subtasks) == 0 {
+		return nil
+	}
+
+	switch s.onParentDelete {
+	case OnParentDeleteCascade:
+		// Every descendant has to be deletable before any of them is deleted.
+		descendants := subtasks
+		for i := 0; i < len(descendants); i++ {
+			t := descendants[i]
+			if err := s.guard.Authorize(ctx, "tasks.Delete", auth.ActionTasksDelete, t.CreatedBy); err != nil {
+				return err
+			}
+			if t.Subtasks == nil {
+				continue
+			}
+			more, err := s.repo.ReadSubtasks(ctx, ws, t.ID)
+			if err != nil {
+				s.log.ErrorContext(ctx, "tasks.Delete", logging.String("stage", "subtasks"), logging.Error("err", err))
+				return errors.New("failed to delete task")
+			}
+			descendants = append(descendants, more...)
+		}
+
+		// The deepest go first, so that parents are still there to update their progress.
+		for i := len(descendants) - 1; i >= 0; i-- {
+			err := s.remove(ctx, ws, descendants[i].ID, false)
+			if errors.Is(err, ErrHasSubtasks) {
+				s.log.DebugContext(ctx, "tasks.Delete", logging.String("stage", "cascade"), logging.Error("err", err))
+				return ErrHasSubtasks
+			}
+			if err != nil && !errors.Is(err, ErrTaskNotFound) {
+				s.log.ErrorContext(ctx, "tasks.Delete", logging.String("stage", "cascade"), logging.Error("err", err))
+				return errors.New("failed to delete task")
+			}
+		}
+		s.log.InfoContext(ctx, "tasks.Delete", logging.String("id", id), logging.Int("subtasks", len(descendants)), auth.Actor(ctx))
+		return nil
+
+	default:
+		s.log.DebugContext(ctx, "tasks.Delete", logging.String("stage", "subtasks"), logging.Error("err", ErrHasSubtasks))
+		return ErrHasSubtasks
+	}
+}
+
+func (s service) Assign(ctx context.Context, id, userID string) (Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.Assign")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.Assign")
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.Assign", auth.ActionTasksUpdate); err != nil {
+		return Task{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.Assign", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.Assign", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to assign task")
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.Assign", auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return Task{}, err
+	}
+
+	if userID != "" {
+		if err := s.checkUser(ctx, "tasks.Assign", userID); err != nil {
+			return Task{}, err
+		}
+	}
+
+	t, err := s.repo.Update(ctx, ws, id, func(t *Task) error {
+		t.Assignee = userID
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.Assign", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.Assign", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to assign task")
+	}
+	s.log.InfoContext(ctx, "tasks.Assign", logging.String("id", t.ID), logging.String("assignee", userID), auth.Actor(ctx))
+	return t, nil
+}
+
+func (s service) ReadAllByUser(ctx context.Context, userID, relation string) ([]Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadAllByUser")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadAllByUser")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadAllByUser", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	// Inactive users still have the tasks they had, so only existence is checked.
+	if _, err := s.users.Read(ctx, userID); err != nil {
+		s.log.DebugContext(ctx, "tasks.ReadAllByUser", logging.String("stage", "user"), logging.Error("err", err))
+		return nil, err
+	}
+
+	tasks, err := s.repo.ReadAllByUser(ctx, ws, relation, userID)
+	if err != nil {
+		s.log.ErrorContext(ctx, "tasks.ReadAllByUser", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read tasks")
+	}
+	s.log.InfoContext(ctx, "tasks.ReadAllByUser", logging.Int("count", len(tasks)), auth.Actor(ctx))
+	return tasks, nil
+}
+
+func (s service) AddLabel(ctx context.Context, id, label string) (Task, error) {
+	return s.changeLabels(ctx, "tasks.AddLabel", id, label, true)
+}
+
+func (s service) RemoveLabel(ctx context.Context, id, label string) (Task, error) {
+	return s.changeLabels(ctx, "tasks.RemoveLabel", id, label, false)
+}
+
+func (s service) changeLabels(ctx context.Context, op, id, label string, add bool) (Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, op)
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, op)
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, op, auth.ActionTasksUpdate); err != nil {
+		return Task{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to change labels")
+	}
+
+	if err := s.guard.Authorize(ctx, op, auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return Task{}, err
+	}
+
+	if contains(current.Labels, label) == add {
+		return current, nil
+	}
+	if add {
+		if _, err := s.checkLabels(ctx, op, []string{label}); err != nil {
+			return Task{}, err
+		}
+	}
+
+	t, err := s.repo.Update(ctx, ws, id, func(t *Task) error {
+		kept := make([]string, 0, len(t.Labels)+1)
+		for _, l := range t.Labels {
+			if l != label {
+				kept = append(kept, l)
+			}
+		}
+		if add {
+			kept = append(kept, label)
+		}
+		t.Labels = kept
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		if errors.Is(err, labels.ErrLabelNotFound) {
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, labels.ErrLabelNotFound
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to change labels")
+	}
+	s.log.InfoContext(ctx, op, logging.String("id", t.ID), logging.String("label", label), auth.Actor(ctx))
+	return t, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s service) ReadOverdue(ctx context.Context) ([]Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadOverdue")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadOverdue")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadOverdue", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.repo.ReadDueBefore(ctx, ws, time.Now())
+	if err != nil {
+		s.log.ErrorContext(ctx, "tasks.ReadOverdue", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read overdue tasks")
+	}
+	s.log.InfoContext(ctx, "tasks.ReadOverdue", logging.Int("count", len(tasks)), auth.Actor(ctx))
+	return tasks, nil
+}
+
+func (s service) ReadSubtasks(ctx context.Context, id string) ([]Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadSubtasks")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadSubtasks")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadSubtasks", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.Read(ctx, ws, id); err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.ReadSubtasks", logging.String("stage", "db"), logging.Error("err", err))
+			return nil, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.ReadSubtasks", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read subtasks")
+	}
+
+	tasks, err := s.repo.ReadSubtasks(ctx, ws, id)
+	if err != nil {
+		s.log.ErrorContext(ctx, "tasks.ReadSubtasks", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read subtasks")
+	}
+	s.log.InfoContext(ctx, "tasks.ReadSubtasks", logging.String("id", id), logging.Int("count", len(tasks)), auth.Actor(ctx))
+	return tasks, nil
+}
+
+func (s service) SetParent(ctx context.Context, id, parentID string) (Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.SetParent")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.SetParent")
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.SetParent", auth.ActionTasksUpdate); err != nil {
+		return Task{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.SetParent", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.SetParent", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to set parent task")
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.SetParent", auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return Task{}, err
+	}
+
+	if current.ParentID == parentID {
+		return current, nil
+	}
+	if err := s.checkParent(ctx, "tasks.SetParent", ws, id, parentID); err != nil {
+		return Task{}, err
+	}
+
+	t, err := s.repo.Update(ctx, ws, id, func(t *Task) error {
+		t.ParentID = parentID
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.SetParent", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		// Tasks were moved or deleted since the parent was checked.
+		for _, sentinel := range []error{ErrParentNotFound, ErrParentCycle, ErrParentTooDeep} {
+			if errors.Is(err, sentinel) {
+				s.log.DebugContext(ctx, "tasks.SetParent", logging.String("stage", "db"), logging.Error("err", err))
+				return Task{}, sentinel
+			}
+		}
+		s.log.ErrorContext(ctx, "tasks.SetParent", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to set parent task")
+	}
+	s.log.InfoContext(ctx, "tasks.SetParent", logging.String("id", t.ID), logging.String("parent", parentID), auth.Actor(ctx))
+	return t, nil
+}
+
+// checkBlockers makes sure every task blocking the task is done.
+func (s service) checkBlockers(ctx context.Context, op, ws, id string) error {
+	links, err := s.repo.ReadLinks(ctx, ws, id)
+	if err != nil {
+		s.log.ErrorContext(ctx, op, logging.String("stage", "blockers"), logging.Error("err", err))
+		return errors.New("failed to read blockers")
+	}
+
+	for _, l := range links {
+		if l.Type != LinkBlocks || l.To != id {
+			continue
+		}
+		blocker, err := s.repo.Read(ctx, ws, l.From)
+		if err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				continue
+			}
+			s.log.ErrorContext(ctx, op, logging.String("stage", "blockers"), logging.Error("err", err))
+			return errors.New("failed to read blockers")
+		}
+		if blocker.Status != StatusDone {
+			s.log.DebugContext(ctx, op, logging.String("stage", "blockers"), logging.String("blocker", blocker.ID))
+			return ErrBlocked
+		}
+	}
+	return nil
+}
+
+// walkBlocking visits blocking links reachable from the task, following them forward to the tasks
+// waiting for it if forward is true and backward to the tasks it waits for otherwise.
+// Links are read a layer at a time, and the walk stops early, returning true, once it is MaxGraphDepth
+// layers deep or has reached MaxGraphNodes tasks; links to tasks past the limit are not visited.
+// Every link is visited once, walking stops at the first error returned by visit.
+func (s service) walkBlocking(ctx context.Context, ws, id string, forward bool, visit func(Link) error) (bool, error) {
+	seen := map[string]bool{id: true}
+	layer := []string{id}
+	for depth := 0; len(layer) > 0; depth++ {
+		if depth == MaxGraphDepth {
+			return true, nil
+		}
+		links, err := s.repo.ReadLinksOf(ctx, ws, layer)
+		if err != nil {
+			return false, err
+		}
+
+		var next []string
+		for _, current := range layer {
+			for _, l := range links[current] {
+				if l.Type != LinkBlocks {
+					continue
+				}
+				other := l.To
+				if !forward {
+					other = l.From
+				}
+				if other == current {
+					continue
+				}
+				if !seen[other] && len(seen) == MaxGraphNodes {
+					return true, nil
+				}
+				if err := visit(l); err != nil {
+					return false, err
+				}
+				if !seen[other] {
+					seen[other] = true
+					next = append(next, other)
+				}
+			}
+		}
+		layer = next
+	}
+	return false, nil
+}
+
+func (s service) AddLink(ctx context.Context, link Link) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.AddLink")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.AddLink")
+	if err != nil {
+		return err
+	}
+
+	if !ValidLinkType(link.Type) || link.From == link.To {
+		s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "validation"), logging.Error("err", ErrInvalidLink))
+		return ErrInvalidLink
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.AddLink", auth.ActionTasksUpdate); err != nil {
+		return err
+	}
+
+	current, err := s.repo.Read(ctx, ws, link.From)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to add link")
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.AddLink", auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return err
+	}
+
+	if link.Type == LinkBlocks {
+		// From blocking To makes a cycle if To already blocks From, directly or not.
+		truncated, err := s.walkBlocking(ctx, ws, link.To, true, func(l Link) error {
+			if l.To == link.From {
+				return ErrLinkCycle
+			}
+			return nil
+		})
+		if err == nil && truncated {
+			// A cycle could still be closed somewhere past the limits.
+			s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "cycle"), logging.Error("err", ErrGraphTooLarge))
+			return ErrGraphTooLarge
+		}
+		if err != nil {
+			if errors.Is(err, ErrLinkCycle) {
+				s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "cycle"), logging.Error("err", err))
+				return ErrLinkCycle
+			}
+			s.log.ErrorContext(ctx, "tasks.AddLink", logging.String("stage", "cycle"), logging.Error("err", err))
+			return errors.New("failed to add link")
+		}
+	}
+
+	if err := s.repo.AddLink(ctx, ws, link); err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrTaskNotFound
+		}
+		if errors.Is(err, ErrLinkedTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrLinkedTaskNotFound
+		}
+		if errors.Is(err, ErrLinkCycle) {
+			// Another link was added since the cycle was checked.
+			s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrLinkCycle
+		}
+		if errors.Is(err, ErrGraphTooLarge) {
+			s.log.DebugContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrGraphTooLarge
+		}
+		s.log.ErrorContext(ctx, "tasks.AddLink", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to add link")
+	}
+	s.log.InfoContext(ctx, "tasks.AddLink", logging.String("id", link.From), logging.String("type", link.Type), logging.String("to", link.To), auth.Actor(ctx))
+	return nil
+}
+
+func (s service) RemoveLink(ctx context.Context, link Link) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.RemoveLink")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.RemoveLink")
+	if err != nil {
+		return err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.RemoveLink", auth.ActionTasksUpdate); err != nil {
+		return err
+	}
+
+	current, err := s.repo.Read(ctx, ws, link.From)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.RemoveLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.RemoveLink", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to remove link")
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.RemoveLink", auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return err
+	}
+
+	err = s.repo.RemoveLink(ctx, ws, link)
+	if errors.Is(err, ErrLinkNotFound) && link.Type == LinkRelatesTo {
+		// Relations have no direction, so they can be removed from either side.
+		err = s.repo.RemoveLink(ctx, ws, Link{Type: link.Type, From: link.To, To: link.From})
+	}
+	if err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			s.log.DebugContext(ctx, "tasks.RemoveLink", logging.String("stage", "db"), logging.Error("err", err))
+			return ErrLinkNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.RemoveLink", logging.String("stage", "db"), logging.Error("err", err))
+		return errors.New("failed to remove link")
+	}
+	s.log.InfoContext(ctx, "tasks.RemoveLink", logging.String("id", link.From), logging.String("type", link.Type), logging.String("to", link.To), auth.Actor(ctx))
+	return nil
+}
+
+func (s service) ReadLinks(ctx context.Context, id string) ([]Link, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadLinks")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadLinks")
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadLinks", auth.ActionTasksRead, ""); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.Read(ctx, ws, id); err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.ReadLinks", logging.String("stage", "db"), logging.Error("err", err))
+			return nil, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.ReadLinks", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read links")
+	}
+
+	links, err := s.repo.ReadLinks(ctx, ws, id)
+	if err != nil {
+		s.log.ErrorContext(ctx, "tasks.ReadLinks", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read links")
+	}
+	s.log.InfoContext(ctx, "tasks.ReadLinks", logging.String("id", id), logging.Int("count", len(links)), auth.Actor(ctx))
+	return links, nil
+}
+
+func (s service) ReadGraph(ctx context.Context, id string) (Graph, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadGraph")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadGraph")
+	if err != nil {
+		return Graph{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadGraph", auth.ActionTasksRead, ""); err != nil {
+		return Graph{}, err
+	}
+
+	root, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.ReadGraph", logging.String("stage", "db"), logging.Error("err", err))
+			return Graph{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.ReadGraph", logging.String("stage", "db"), logging.Error("err", err))
+		return Graph{}, errors.New("failed to read graph")
+	}
+
+	graph := Graph{Nodes: []Task{root}, Edges: []Link{}}
+	nodes := map[string]bool{id: true}
+	var ids []string
+	edges := map[Link]bool{}
+	collect := func(l Link) error {
+		if edges[l] {
+			return nil
+		}
+		edges[l] = true
+		graph.Edges = append(graph.Edges, l)
+
+		for _, n := range []string{l.From, l.To} {
+			if !nodes[n] {
+				nodes[n] = true
+				ids = append(ids, n)
+			}
+		}
+		return nil
+	}
+	for _, forward := range []bool{false, true} {
+		truncated, err := s.walkBlocking(ctx, ws, id, forward, collect)
+		if err != nil {
+			s.log.ErrorContext(ctx, "tasks.ReadGraph", logging.String("stage", "db"), logging.Error("err", err))
+			return Graph{}, errors.New("failed to read graph")
+		}
+		graph.Truncated = graph.Truncated || truncated
+	}
+
+	// Tasks deleted since their links were read are left out.
+	found, err := s.repo.ReadMany(ctx, ws, ids)
+	if err != nil {
+		s.log.ErrorContext(ctx, "tasks.ReadGraph", logging.String("stage", "db"), logging.Error("err", err))
+		return Graph{}, errors.New("failed to read graph")
+	}
+	graph.Nodes = append(graph.Nodes, found...)
+	s.log.InfoContext(ctx, "tasks.ReadGraph", logging.String("id", id), logging.Int("nodes", len(graph.Nodes)), logging.Bool("truncated", graph.Truncated), auth.Actor(ctx))
+	return graph, nil
+}
+
+func (s service) Move(ctx context.Context, id, projectID, columnID string, position int) (Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.Move")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.Move")
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := s.guard.AuthorizeAny(ctx, "tasks.Move", auth.ActionTasksUpdate); err != nil {
+		return Task{}, err
+	}
+
+	current, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.Move", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		s.log.ErrorContext(ctx, "tasks.Move", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to move task")
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.Move", auth.ActionTasksUpdate, current.CreatedBy); err != nil {
+		return Task{}, err
+	}
+
+	if projectID == "" {
+		projectID = current.ProjectID
+	}
+	if projectID == "" {
+		s.log.DebugContext(ctx, "tasks.Move", logging.String("stage", "project"), logging.Error("err", projects.ErrProjectNotFound))
+		return Task{}, projects.ErrProjectNotFound
+	}
+	project, err := s.projects.Read(ctx, projectID)
+	if err != nil {
+		s.log.DebugContext(ctx, "tasks.Move", logging.String("stage", "project"), logging.Error("err", err))
+		return Task{}, err
+	}
+	column, ok := project.Column(columnID)
+	if !ok {
+		s.log.DebugContext(ctx, "tasks.Move", logging.String("stage", "project"), logging.Error("err", projects.ErrColumnNotFound))
+		return Task{}, projects.ErrColumnNotFound
+	}
+
+	if column.Status == StatusDone && current.Status != StatusDone {
+		if err := s.checkBlockers(ctx, "tasks.Move", ws, id); err != nil {
+			return Task{}, err
+		}
+	}
+
+	current.ProjectID = projectID
+	current.ColumnID = column.ID
+	r, err := s.rank(ctx, "tasks.Move", ws, current, position)
+	if err != nil {
+		return Task{}, err
+	}
+
+	// Column, rank and status are written at once, so the task is never seen half moved.
+	t, err := s.repo.Update(ctx, ws, id, func(t *Task) error {
+		t.ProjectID = projectID
+		t.ColumnID = column.ID
+		t.Status = column.Status
+		t.Rank = r
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrTaskNotFound) {
+			s.log.DebugContext(ctx, "tasks.Move", logging.String("stage", "db"), logging.Error("err", err))
+			return Task{}, ErrTaskNotFound
+		}
+		// The project was deleted or lost the column since it was read.
+		for _, sentinel := range []error{projects.ErrProjectNotFound, projects.ErrColumnNotFound} {
+			if errors.Is(err, sentinel) {
+				s.log.DebugContext(ctx, "tasks.Move", logging.String("stage", "db"), logging.Error("err", err))
+				return Task{}, sentinel
+			}
+		}
+		s.log.ErrorContext(ctx, "tasks.Move", logging.String("stage", "db"), logging.Error("err", err))
+		return Task{}, errors.New("failed to move task")
+	}
+	s.log.InfoContext(ctx, "tasks.Move", logging.String("id", t.ID), logging.String("column", column.ID), auth.Actor(ctx))
+	return t, nil
+}
+
+func (s service) ReadBoard(ctx context.Context, projectID string) (Board, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "tasks.ReadBoard")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "tasks.ReadBoard")
+	if err != nil {
+		return Board{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "tasks.ReadBoard", auth.ActionTasksRead, ""); err != nil {
+		return Board{}, err
+	}
+
+	project, err := s.projects.Read(ctx, projectID)
+	if err != nil {
+		s.log.DebugContext(ctx, "tasks.ReadBoard", logging.String("stage", "project"), logging.Error("err", err))
+		return Board{}, err
+	}
+
+	board := Board{Project: project, Columns: make([]BoardColumn, 0, len(project.Columns))}
+	for _, c := range project.Columns {
+		tasks, err := s.repo.ReadColumn(ctx, ws, projectID, c.ID)
+		if err != nil {
+			s.log.ErrorContext(ctx, "tasks.ReadBoard", logging.String("stage", "db"), logging.Error("err", err))
+			return Board{}, errors.New("failed to read board")
+		}
+		if tasks == nil {
+			tasks = []Task{}
+		}
+		board.Columns = append(board.Columns, BoardColumn{Column: c, Tasks: tasks})
+	}
+	s.log.InfoContext(ctx, "tasks.ReadBoard", logging.String("project", projectID), auth.Actor(ctx))
+	return board, nil
+}
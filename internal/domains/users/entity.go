@@ -0,0 +1,21 @@
+package users
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserInactive = errors.New("user is not active")
+	ErrEmailTaken   = errors.New("email is already taken")
+)
+
+type User struct {
+	ID          string    `json:"id"`
+	WorkspaceID string    `json:"workspaceId"`
+	DisplayName string    `json:"displayName"`
+	Email       string    `json:"email"`
+	Active      bool      `json:"active"` // inactive users keep their tasks, but can't be assigned new ones
+	CreatedAt   time.Time `json:"createdAt"`
+}
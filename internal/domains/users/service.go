@@ -0,0 +1,195 @@
+package users
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
+
+type (
+	// Repository keeps users of every workspace apart, emails are unique within a workspace.
+	Repository interface {
+		Create(ctx context.Context, workspace string, user User) (User, error)
+		Read(ctx context.Context, workspace, id string) (User, error)
+		ReadAll(ctx context.Context, workspace string) ([]User, error)
+		Update(ctx context.Context, workspace string, user User) (User, error)
+	}
+
+	// Service works with users of the workspace of the request, users of other workspaces are not found.
+	Service interface {
+		Create(ctx context.Context, user User) (User, error)
+		Read(ctx context.Context, id string) (User, error)
+		ReadAll(ctx context.Context) ([]User, error)
+		// Update changes the profile, empty fields keep their current values.
+		Update(ctx context.Context, user User) (User, error)
+		SetActive(ctx context.Context, id string, active bool) (User, error)
+	}
+
+	service struct {
+		repo  Repository
+		guard auth.Guard
+		log   *logging.Logger
+	}
+)
+
+var _ Service = (*service)(nil)
+
+func NewService(repo Repository, policy auth.Authorizer, log *logging.Logger) service {
+	return service{
+		repo:  repo,
+		guard: auth.NewGuard(policy, log),
+		log:   log,
+	}
+}
+
+func (s service) Create(ctx context.Context, user User) (User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "users.Create")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "users.Create")
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := s.guard.Authorize(ctx, "users.Create", auth.ActionUsersCreate, ""); err != nil {
+		return User{}, err
+	}
+
+	user.Email = normalizeEmail(user.Email)
+	user.Active = true
+	user.CreatedAt = time.Now().UTC()
+
+	u, err := s.repo.Create(ctx, ws, user)
+	if err != nil {
+		if errors.Is(err, ErrEmailTaken) {
+			s.log.DebugContext(ctx, "users.Create", logging.String("stage", "db"), logging.Error("err", err))
+			return User{}, ErrEmailTaken
+		}
+		s.log.ErrorContext(ctx, "users.Create", logging.String("stage", "db"), logging.Error("err", err))
+		return User{}, errors.New("failed to create user")
+	}
+	s.log.InfoContext(ctx, "users.Create", logging.String("id", u.ID))
+	return u, nil
+}
+
+func (s service) Read(ctx context.Context, id string) (User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "users.Read")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "users.Read")
+	if err != nil {
+		return User{}, err
+	}
+
+	u, err := s.repo.Read(ctx, ws, id)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			s.log.DebugContext(ctx, "users.Read", logging.String("stage", "db"), logging.Error("err", err))
+			return User{}, ErrUserNotFound
+		}
+		s.log.ErrorContext(ctx, "users.Read", logging.String("stage", "db"), logging.Error("err", err))
+		return User{}, errors.New("failed to read user")
+	}
+	s.log.DebugContext(ctx, "users.Read", logging.String("id", u.ID))
+	return u, nil
+}
+
+func (s service) ReadAll(ctx context.Context) ([]User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "users.ReadAll")
+	defer span.End()
+	defer s.log.Sync()
+
+	ws, err := s.guard.Workspace(ctx, "users.ReadAll")
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.repo.ReadAll(ctx, ws)
+	if err != nil {
+		s.log.ErrorContext(ctx, "users.ReadAll", logging.String("stage", "db"), logging.Error("err", err))
+		return nil, errors.New("failed to read users")
+	}
+	s.log.InfoContext(ctx, "users.ReadAll", logging.Int("count", len(users)))
+	return users, nil
+}
+
+func (s service) Update(ctx context.Context, user User) (User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "users.Update")
+	defer span.End()
+	defer s.log.Sync()
+
+	if err := s.guard.Authorize(ctx, "users.Update", auth.ActionUsersUpdate, ""); err != nil {
+		return User{}, err
+	}
+
+	current, err := s.Read(ctx, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+
+	if user.DisplayName != "" {
+		current.DisplayName = user.DisplayName
+	}
+	if user.Email != "" {
+		current.Email = normalizeEmail(user.Email)
+	}
+
+	return s.update(ctx, "users.Update", current)
+}
+
+func (s service) SetActive(ctx context.Context, id string, active bool) (User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "users.SetActive")
+	defer span.End()
+	defer s.log.Sync()
+
+	if err := s.guard.Authorize(ctx, "users.SetActive", auth.ActionUsersUpdate, ""); err != nil {
+		return User{}, err
+	}
+
+	current, err := s.Read(ctx, id)
+	if err != nil {
+		return User{}, err
+	}
+	if current.Active == active {
+		return current, nil
+	}
+	current.Active = active
+
+	return s.update(ctx, "users.SetActive", current)
+}
+
+func (s service) update(ctx context.Context, op string, user User) (User, error) {
+	ws, err := s.guard.Workspace(ctx, op)
+	if err != nil {
+		return User{}, err
+	}
+
+	u, err := s.repo.Update(ctx, ws, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return User{}, ErrUserNotFound
+		case errors.Is(err, ErrEmailTaken):
+			s.log.DebugContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+			return User{}, ErrEmailTaken
+		}
+		s.log.ErrorContext(ctx, op, logging.String("stage", "db"), logging.Error("err", err))
+		return User{}, errors.New("failed to update user")
+	}
+	s.log.InfoContext(ctx, op, logging.String("id", u.ID))
+	return u, nil
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,117 @@
+// Package blob keeps contents of attachments, in a local directory or in an S3 compatible bucket.
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+	"go.opentelemetry.io/otel"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/storage/blob"
+
+// FSStore keeps blobs in files at "<dir>/<first two characters of the key>/<key>",
+// so that no directory ends up with too many files.
+// It is meant for a single instance, or for a directory shared by all of them.
+type FSStore struct {
+	dir string
+}
+
+var _ attachments.BlobStore = (*FSStore)(nil)
+
+func NewFSStore(dir string) (FSStore, error) {
+	if dir == "" {
+		return FSStore{}, errors.New("blob: directory can't be empty")
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return FSStore{}, fmt.Errorf("blob: could not create directory: %w", err)
+	}
+	return FSStore{dir: dir}, nil
+}
+
+// path checks that the key is one the store could have generated,
+// so that it never points outside of the directory.
+func (s FSStore) path(key string) (string, error) {
+	if _, err := uuid.Parse(key); err != nil || len(key) != 36 {
+		return "", fmt.Errorf("blob: invalid key %q", key)
+	}
+	return filepath.Join(s.dir, key[:2], key), nil
+}
+
+func (s FSStore) Put(ctx context.Context, r io.Reader, contentType string) (string, error) {
+	_, span := otel.Tracer(otelName).Start(ctx, "FSStore.Put")
+	defer span.End()
+
+	key := uuid.New().String()
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	// Content is written to a temporary file first, so that a failed upload never shows up under a key.
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s FSStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	_, span := otel.Tracer(otelName).Start(ctx, "FSStore.Get")
+	defer span.End()
+
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: %s", attachments.ErrBlobNotFound, key)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s FSStore) Delete(ctx context.Context, key string) error {
+	_, span := otel.Tracer(otelName).Start(ctx, "FSStore.Delete")
+	defer span.End()
+
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+)
+
+func TestFSStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := store.Put(ctx, strings.NewReader("content"), "text/plain")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	r, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "content" {
+		t.Errorf("Get = %q, want %q", got, "content")
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, attachments.ErrBlobNotFound) {
+		t.Errorf("Get after Delete = %v, want %v", err, attachments.ErrBlobNotFound)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing blob = %v, want no error", err)
+	}
+}
+
+func TestFSStoreRejectsForeignKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	dir := filepath.Join(root, "blobs")
+	store, err := NewFSStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A file next to the store, which no key may reach.
+	secret := filepath.Join(root, "secret")
+	if err := os.WriteFile(secret, []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys := []string{
+		"",
+		"..",
+		"../secret",
+		"../../etc/passwd",
+		"/etc/passwd",
+		"6f/../../secret",
+		"6f1c8a2e-1111-4222-8333-444455556666/../../../secret",
+		"{6f1c8a2e-1111-4222-8333-444455556666}",
+		"urn:uuid:6f1c8a2e-1111-4222-8333-444455556666",
+		"6f1c8a2e11114222833344445555666",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			if r, err := store.Get(ctx, key); err == nil {
+				r.Close()
+				t.Errorf("Get(%q) succeeded, want an invalid key", key)
+			} else if errors.Is(err, attachments.ErrBlobNotFound) {
+				t.Errorf("Get(%q) = %v, want an invalid key", key, err)
+			}
+			if err := store.Delete(ctx, key); err == nil {
+				t.Errorf("Delete(%q) succeeded, want an invalid key", key)
+			}
+		})
+	}
+
+	if _, err := os.Stat(secret); err != nil {
+		t.Errorf("file outside of the store is gone: %v", err)
+	}
+}
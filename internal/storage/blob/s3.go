@@ -0,0 +1,282 @@
+package blob
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+	"go.opentelemetry.io/otel"
+)
+
+// S3Options describe an S3 compatible bucket, e.g. on AWS, MinIO or Ceph.
+type S3Options struct {
+	Endpoint  string // e.g. "https://s3.eu-central-1.amazonaws.com" or "http://minio:9000"
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses the bucket as "<endpoint>/<bucket>" instead of "<bucket>.<endpoint host>",
+	// most self hosted stores only support this style.
+	PathStyle bool
+	Client    *http.Client // http.DefaultClient is used if nil
+}
+
+// S3Store keeps blobs as objects named by their keys.
+// Requests are signed with AWS Signature Version 4.
+type S3Store struct {
+	opts     S3Options
+	endpoint *url.URL
+}
+
+var _ attachments.BlobStore = (*S3Store)(nil)
+
+func NewS3Store(opts S3Options) (S3Store, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return S3Store{}, fmt.Errorf("blob: invalid s3 endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" || opts.Region == "" {
+		return S3Store{}, errors.New("blob: s3 bucket and region can't be empty")
+	}
+	if opts.AccessKey == "" || opts.SecretKey == "" {
+		return S3Store{}, errors.New("blob: s3 access key and secret key can't be empty")
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+	return S3Store{opts: opts, endpoint: endpoint}, nil
+}
+
+func (s S3Store) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.opts.PathStyle {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.opts.Bucket + "/" + key
+	} else {
+		u.Host = s.opts.Bucket + "." + u.Host
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + key
+	}
+	return &u
+}
+
+func (s S3Store) Put(ctx context.Context, r io.Reader, contentType string) (string, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "S3Store.Put")
+	defer span.End()
+
+	// The payload is spooled to disk while it is hashed, since a signed PUT needs
+	// both its length and its checksum before the first byte is sent.
+	tmp, err := os.CreateTemp("", "blob-upload-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	key := uuid.New().String()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), io.NopCloser(tmp))
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	res, err := s.do(req, hex.EncodeToString(hash.Sum(nil)))
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", s3Error(res)
+	}
+	return key, nil
+}
+
+func (s S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "S3Store.Get")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(key).String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return nil, err
+	}
+
+	switch res.StatusCode {
+	case http.StatusOK:
+		return res.Body, nil
+	case http.StatusNotFound:
+		res.Body.Close()
+		return nil, fmt.Errorf("%w: %s", attachments.ErrBlobNotFound, key)
+	default:
+		defer res.Body.Close()
+		return nil, s3Error(res)
+	}
+}
+
+func (s S3Store) Delete(ctx context.Context, key string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "S3Store.Delete")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.do(req, emptyPayloadHash)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	// Deleting a missing object succeeds on S3, some compatible stores answer 404 instead.
+	if res.StatusCode != http.StatusNoContent && res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNotFound {
+		return s3Error(res)
+	}
+	return nil
+}
+
+func (s S3Store) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signV4(req, s.opts.Region, s.opts.AccessKey, s.opts.SecretKey, payloadHash, time.Now())
+	return s.opts.Client.Do(req)
+}
+
+// s3Error keeps the beginning of the error document, it names what went wrong.
+func s3Error(res *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	return fmt.Errorf("blob: s3 responded with %s: %s", res.Status, strings.TrimSpace(string(body)))
+}
+
+// emptyPayloadHash is the hex encoded sha256 of an empty payload.
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// signV4 adds the Authorization header of AWS Signature Version 4 to req,
+// the host and every header already set on req are signed.
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html.
+func signV4(req *http.Request, region, accessKey, secretKey, payloadHash string, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	scope := day + "/" + region + "/s3/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalPath(req.URL.Path),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), day)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature,
+	))
+}
+
+// canonicalPath encodes every segment of the path once, S3 doesn't normalize paths.
+func canonicalPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(name)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode escapes everything but the unreserved characters of RFC 3986, as SigV4 requires.
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
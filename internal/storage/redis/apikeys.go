@@ -0,0 +1,174 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Api keys are stored in hashes at "apikeys:<id>", so that they can be authenticated before
+// the workspace of the request is known. Ids of keys of a workspace are in the set at "apikeys:{<workspace>}:index".
+
+const apiKeysPrefix = "apikeys"
+
+type APIKeysRepo struct {
+	rdb *redis.Client
+}
+
+var _ auth.Repository = (*APIKeysRepo)(nil)
+
+func apiKeyKey(id string) string {
+	return fmt.Sprintf("%s:%s", apiKeysPrefix, id)
+}
+
+func apiKeysIndexKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:index", apiKeysPrefix, workspace)
+}
+
+func apiKeyFields(key auth.APIKey) []any {
+	fields := []any{
+		"name", key.Name,
+		"owner", key.Owner,
+		"workspace", key.Workspace,
+		"scopes", strings.Join(key.Scopes, ","),
+		"hash", key.Hash,
+		"created_at", key.CreatedAt.Format(time.RFC3339Nano),
+	}
+	if key.RevokedAt != nil {
+		fields = append(fields, "revoked_at", key.RevokedAt.Format(time.RFC3339Nano))
+	}
+	return fields
+}
+
+func parseAPIKey(id string, res map[string]string) (auth.APIKey, error) {
+	key := auth.APIKey{
+		ID:        id,
+		Name:      res["name"],
+		Owner:     res["owner"],
+		Workspace: res["workspace"],
+		Scopes:    []string{},
+		Hash:      res["hash"],
+	}
+	if res["scopes"] != "" {
+		key.Scopes = strings.Split(res["scopes"], ",")
+	}
+
+	var err error
+	if key.CreatedAt, err = time.Parse(time.RFC3339Nano, res["created_at"]); err != nil {
+		return auth.APIKey{}, err
+	}
+	if v, ok := res["last_used_at"]; ok {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return auth.APIKey{}, err
+		}
+		key.LastUsedAt = &t
+	}
+	if v, ok := res["revoked_at"]; ok {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return auth.APIKey{}, err
+		}
+		key.RevokedAt = &t
+	}
+
+	return key, nil
+}
+
+func (r APIKeysRepo) CreateAPIKey(ctx context.Context, key auth.APIKey) (auth.APIKey, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "APIKeysRepo.CreateAPIKey")
+	defer span.End()
+
+	if err := checkWorkspace(key.Workspace); err != nil {
+		return auth.APIKey{}, err
+	}
+
+	key.ID = uuid.New().String()
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, apiKeyKey(key.ID), apiKeyFields(key)...)
+		pipe.SAdd(ctx, apiKeysIndexKey(key.Workspace), key.ID)
+		return nil
+	})
+	return key, err
+}
+
+func (r APIKeysRepo) ReadAPIKey(ctx context.Context, id string) (auth.APIKey, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "APIKeysRepo.ReadAPIKey")
+	defer span.End()
+
+	res, err := r.rdb.HGetAll(ctx, apiKeyKey(id)).Result()
+	if err != nil {
+		return auth.APIKey{}, err
+	}
+	if len(res) == 0 {
+		return auth.APIKey{}, fmt.Errorf("%w: %s", auth.ErrAPIKeyNotFound, id)
+	}
+
+	return parseAPIKey(id, res)
+}
+
+func (r APIKeysRepo) ReadAllAPIKeys(ctx context.Context, workspace string) ([]auth.APIKey, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "APIKeysRepo.ReadAllAPIKeys")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	ids, err := r.rdb.SMembers(ctx, apiKeysIndexKey(workspace)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err = r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, apiKeyKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]auth.APIKey, 0, len(ids))
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+		key, err := parseAPIKey(ids[i], cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, key)
+	}
+	return result, nil
+}
+
+func (r APIKeysRepo) UpdateAPIKey(ctx context.Context, key auth.APIKey) (auth.APIKey, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "APIKeysRepo.UpdateAPIKey")
+	defer span.End()
+
+	n, err := r.rdb.Exists(ctx, apiKeyKey(key.ID)).Result()
+	if err != nil {
+		return auth.APIKey{}, err
+	}
+	if n == 0 {
+		return auth.APIKey{}, fmt.Errorf("%w: %s", auth.ErrAPIKeyNotFound, key.ID)
+	}
+
+	return key, r.rdb.HSet(ctx, apiKeyKey(key.ID), apiKeyFields(key)...).Err()
+}
+
+func (r APIKeysRepo) TouchAPIKey(ctx context.Context, id string, usedAt time.Time) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "APIKeysRepo.TouchAPIKey")
+	defer span.End()
+
+	return r.rdb.HSet(ctx, apiKeyKey(id), "last_used_at", usedAt.Format(time.RFC3339Nano)).Err()
+}
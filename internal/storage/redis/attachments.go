@@ -0,0 +1,162 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Metadata of attachments of a task are JSON values in the hash at "tasks:{<workspace>}:<task id>:attachments",
+// keyed by attachment id, the content is kept by a blob store.
+// The task hash keeps the number of its attachments in the "attachment_count" field.
+
+// createAttachment adds an attachment, unless the task is gone.
+//
+// KEYS[1] - task, KEYS[2] - attachments.
+// ARGV[1] - id, ARGV[2] - attachment.
+// Returns 1 on success, 0 if the task doesn't exist.
+var createAttachment = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('HINCRBY', KEYS[1], 'attachment_count', 1)
+return 1
+`)
+
+// deleteAttachment removes an attachment.
+//
+// KEYS[1] - task, KEYS[2] - attachments.
+// ARGV[1] - id.
+// Returns 1 on success, 0 if the attachment doesn't exist.
+var deleteAttachment = redis.NewScript(`
+if redis.call('HDEL', KEYS[2], ARGV[1]) == 0 then
+	return 0
+end
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HINCRBY', KEYS[1], 'attachment_count', -1)
+end
+return 1
+`)
+
+type AttachmentsRepo struct {
+	rdb *redis.Client
+}
+
+var _ attachments.Repository = (*AttachmentsRepo)(nil)
+
+func attachmentsKey(workspace, taskID string) string {
+	return taskKey(workspace, taskID) + ":attachments"
+}
+
+// attachmentRecord is what is stored, the blob key is hidden from clients but not from the repository.
+type attachmentRecord struct {
+	attachments.Attachment
+	BlobKey string `json:"blobKey"`
+}
+
+func (r AttachmentsRepo) Create(ctx context.Context, workspace string, attachment attachments.Attachment) (attachments.Attachment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "AttachmentsRepo.Create")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return attachments.Attachment{}, err
+	}
+
+	attachment.ID = uuid.New().String()
+	raw, err := json.Marshal(attachmentRecord{Attachment: attachment, BlobKey: attachment.BlobKey})
+	if err != nil {
+		return attachments.Attachment{}, err
+	}
+
+	res, err := createAttachment.Run(ctx, r.rdb,
+		[]string{taskKey(workspace, attachment.TaskID), attachmentsKey(workspace, attachment.TaskID)},
+		attachment.ID, raw,
+	).Int()
+	if err != nil {
+		return attachments.Attachment{}, err
+	}
+	if res == 0 {
+		return attachments.Attachment{}, fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, attachment.TaskID)
+	}
+	return attachment, nil
+}
+
+func (r AttachmentsRepo) Read(ctx context.Context, workspace, taskID, id string) (attachments.Attachment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "AttachmentsRepo.Read")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return attachments.Attachment{}, err
+	}
+
+	raw, err := r.rdb.HGet(ctx, attachmentsKey(workspace, taskID), id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return attachments.Attachment{}, fmt.Errorf("%w: %s", attachments.ErrAttachmentNotFound, id)
+		}
+		return attachments.Attachment{}, err
+	}
+	return decodeAttachment(raw)
+}
+
+func (r AttachmentsRepo) ReadAll(ctx context.Context, workspace, taskID string) ([]attachments.Attachment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "AttachmentsRepo.ReadAll")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	res, err := r.rdb.HVals(ctx, attachmentsKey(workspace, taskID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]attachments.Attachment, 0, len(res))
+	for _, raw := range res {
+		a, err := decodeAttachment(raw)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, nil
+}
+
+func (r AttachmentsRepo) Delete(ctx context.Context, workspace, taskID, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "AttachmentsRepo.Delete")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	res, err := deleteAttachment.Run(ctx, r.rdb,
+		[]string{taskKey(workspace, taskID), attachmentsKey(workspace, taskID)},
+		id,
+	).Int()
+	if err != nil {
+		return err
+	}
+	if res == 0 {
+		return fmt.Errorf("%w: %s", attachments.ErrAttachmentNotFound, id)
+	}
+	return nil
+}
+
+func decodeAttachment(raw string) (attachments.Attachment, error) {
+	var rec attachmentRecord
+	if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+		return attachments.Attachment{}, err
+	}
+	a := rec.Attachment
+	a.BlobKey = rec.BlobKey
+	return a, nil
+}
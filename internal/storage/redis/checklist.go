@@ -0,0 +1,218 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// The checklist of a task is a JSON array of its items in the "checklist" field of the task hash,
+// the field is removed when the last item is. Items are changed only by the scripts below,
+// each of which reads, changes and writes the checklist at once.
+
+// checklistPrelude starts every checklist script.
+// It returns 0 if the task doesn't exist, otherwise it leaves the items in items,
+// the index of the item with the id in ARGV[1] in index, if there is one,
+// and a save function that writes the items back and returns 1.
+//
+// KEYS[1] - task.
+const checklistPrelude = `
+local raw = redis.call('HGET', KEYS[1], 'checklist')
+if not raw and redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+local items = {}
+if raw then
+	items = cjson.decode(raw)
+end
+local index
+for i, item in ipairs(items) do
+	if item.id == ARGV[1] then
+		index = i
+	end
+end
+local function save()
+	if #items == 0 then
+		redis.call('HDEL', KEYS[1], 'checklist')
+	else
+		redis.call('HSET', KEYS[1], 'checklist', cjson.encode(items))
+	end
+	return 1
+end
+-- position turns a position counting from 0 into an index to insert at, the end if out of range.
+local function position(arg)
+	local p = tonumber(arg)
+	if p < 0 or p > #items then
+		return #items + 1
+	end
+	return p + 1
+end
+`
+
+// addChecklistItem inserts an item.
+//
+// ARGV[1] - id, ARGV[2] - item, ARGV[3] - position, ARGV[4] - max number of items.
+// Returns 1 on success, 0 if the task doesn't exist, -2 if the checklist is full.
+var addChecklistItem = redis.NewScript(checklistPrelude + `
+if #items >= tonumber(ARGV[4]) then
+	return -2
+end
+table.insert(items, position(ARGV[3]), cjson.decode(ARGV[2]))
+return save()
+`)
+
+// changeChecklistItem changes the text or the state of an item, leaving the rest of it as is.
+//
+// ARGV[1] - id, ARGV[2] - text or "" to keep it, ARGV[3] - "1" or "0" for done or not, "" to keep it.
+// Returns 1 on success, 0 if the task doesn't exist, -1 if the item doesn't.
+var changeChecklistItem = redis.NewScript(checklistPrelude + `
+if not index then
+	return -1
+end
+if ARGV[2] ~= '' then
+	items[index].text = ARGV[2]
+end
+if ARGV[3] ~= '' then
+	items[index].done = ARGV[3] == '1'
+end
+return save()
+`)
+
+// moveChecklistItem moves an item to another position.
+//
+// ARGV[1] - id, ARGV[2] - position.
+// Returns 1 on success, 0 if the task doesn't exist, -1 if the item doesn't.
+var moveChecklistItem = redis.NewScript(checklistPrelude + `
+if not index then
+	return -1
+end
+local item = table.remove(items, index)
+table.insert(items, position(ARGV[2]), item)
+return save()
+`)
+
+// deleteChecklistItem removes an item.
+//
+// ARGV[1] - id.
+// Returns 1 on success, 0 if the task doesn't exist, -1 if the item doesn't.
+var deleteChecklistItem = redis.NewScript(checklistPrelude + `
+if not index then
+	return -1
+end
+table.remove(items, index)
+return save()
+`)
+
+// checklistField reads the checklist field of a task hash.
+func checklistField(raw string) *tasks.Checklist {
+	if raw == "" {
+		return nil
+	}
+	var items []tasks.ChecklistItem
+	if err := json.Unmarshal([]byte(raw), &items); err != nil {
+		return nil
+	}
+	return tasks.NewChecklist(items)
+}
+
+// checklistResult turns what a checklist script returned into an error.
+func checklistResult(res int, id, itemID string) error {
+	switch res {
+	case 0:
+		return fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, id)
+	case -1:
+		return fmt.Errorf("%w: %s", tasks.ErrChecklistItemNotFound, itemID)
+	case -2:
+		return fmt.Errorf("%w: %s", tasks.ErrChecklistFull, id)
+	}
+	return nil
+}
+
+func (r TasksRepo) AddChecklistItem(ctx context.Context, workspace, id string, item tasks.ChecklistItem, position int) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.AddChecklistItem")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "AddChecklistItem", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	item.ID = uuid.New().String()
+	raw, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	res, err := addChecklistItem.Run(ctx, r.rdb, []string{taskKey(workspace, id)},
+		item.ID, raw, position, tasks.MaxChecklistItems,
+	).Int()
+	if err != nil {
+		return err
+	}
+	return checklistResult(res, id, item.ID)
+}
+
+func (r TasksRepo) ChangeChecklistItem(ctx context.Context, workspace, id, itemID string, change tasks.ChecklistChange) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ChangeChecklistItem")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ChangeChecklistItem", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	text, done := "", ""
+	if change.Text != nil {
+		text = *change.Text
+	}
+	if change.Done != nil {
+		done = "0"
+		if *change.Done {
+			done = "1"
+		}
+	}
+
+	res, err := changeChecklistItem.Run(ctx, r.rdb, []string{taskKey(workspace, id)}, itemID, text, done).Int()
+	if err != nil {
+		return err
+	}
+	return checklistResult(res, id, itemID)
+}
+
+func (r TasksRepo) MoveChecklistItem(ctx context.Context, workspace, id, itemID string, position int) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.MoveChecklistItem")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "MoveChecklistItem", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	res, err := moveChecklistItem.Run(ctx, r.rdb, []string{taskKey(workspace, id)}, itemID, position).Int()
+	if err != nil {
+		return err
+	}
+	return checklistResult(res, id, itemID)
+}
+
+func (r TasksRepo) DeleteChecklistItem(ctx context.Context, workspace, id, itemID string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.DeleteChecklistItem")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "DeleteChecklistItem", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	res, err := deleteChecklistItem.Run(ctx, r.rdb, []string{taskKey(workspace, id)}, itemID).Int()
+	if err != nil {
+		return err
+	}
+	return checklistResult(res, id, itemID)
+}
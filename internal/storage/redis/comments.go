@@ -0,0 +1,232 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/comments"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Comments of a task are JSON values in the hash at "tasks:{<workspace>}:<task id>:comments", keyed by comment id.
+// Ids of replies to a comment are in the set at "tasks:{<workspace>}:<task id>:comments:<comment id>:replies".
+// The task hash keeps the number of its comments in the "comment_count" field.
+
+// createComment adds a comment, unless the task or the parent comment is gone.
+//
+// KEYS[1] - task, KEYS[2] - comments, KEYS[3] - replies of the parent or "" for top level comments.
+// ARGV[1] - id, ARGV[2] - comment, ARGV[3] - parent id.
+// Returns 1 on success, 0 if the task doesn't exist, -1 if the parent doesn't.
+var createComment = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if ARGV[3] ~= '' then
+	if redis.call('HEXISTS', KEYS[2], ARGV[3]) == 0 then
+		return -1
+	end
+	redis.call('SADD', KEYS[3], ARGV[1])
+end
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[2])
+redis.call('HINCRBY', KEYS[1], 'comment_count', 1)
+return 1
+`)
+
+// replaceHashField sets a field of a hash only if it already exists, e.g. a comment or a label.
+//
+// KEYS[1] - hash, ARGV[1] - field, ARGV[2] - value.
+// Returns 1 on success, 0 if the field doesn't exist.
+var replaceHashField = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// deleteComment removes a comment with its replies.
+//
+// KEYS[1] - task, KEYS[2] - comments, KEYS[3] - replies of the comment, KEYS[4] - replies of the parent or "".
+// ARGV[1] - id.
+// Returns the number of removed comments.
+var deleteComment = redis.NewScript(`
+local ids = redis.call('SMEMBERS', KEYS[3])
+table.insert(ids, ARGV[1])
+local removed = redis.call('HDEL', KEYS[2], unpack(ids))
+redis.call('DEL', KEYS[3])
+if KEYS[4] ~= '' then
+	redis.call('SREM', KEYS[4], ARGV[1])
+end
+if removed > 0 and redis.call('EXISTS', KEYS[1]) == 1 then
+	redis.call('HINCRBY', KEYS[1], 'comment_count', -removed)
+end
+return removed
+`)
+
+type CommentsRepo struct {
+	rdb *redis.Client
+}
+
+var _ comments.Repository = (*CommentsRepo)(nil)
+
+func commentsKey(workspace, taskID string) string {
+	return taskKey(workspace, taskID) + ":comments"
+}
+
+func commentRepliesKey(workspace, taskID, id string) string {
+	return fmt.Sprintf("%s:%s:replies", commentsKey(workspace, taskID), id)
+}
+
+func (r CommentsRepo) Create(ctx context.Context, workspace string, comment comments.Comment) (comments.Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "CommentsRepo.Create")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return comments.Comment{}, err
+	}
+
+	comment.ID = uuid.New().String()
+	raw, err := json.Marshal(comment)
+	if err != nil {
+		return comments.Comment{}, err
+	}
+
+	parentReplies := ""
+	if comment.ParentID != "" {
+		parentReplies = commentRepliesKey(workspace, comment.TaskID, comment.ParentID)
+	}
+
+	res, err := createComment.Run(ctx, r.rdb,
+		[]string{taskKey(workspace, comment.TaskID), commentsKey(workspace, comment.TaskID), parentReplies},
+		comment.ID, raw, comment.ParentID,
+	).Int()
+	if err != nil {
+		return comments.Comment{}, err
+	}
+
+	switch res {
+	case 0:
+		return comments.Comment{}, fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, comment.TaskID)
+	case -1:
+		return comments.Comment{}, fmt.Errorf("%w: %s", comments.ErrCommentNotFound, comment.ParentID)
+	}
+	return comment, nil
+}
+
+func (r CommentsRepo) Read(ctx context.Context, workspace, taskID, id string) (comments.Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "CommentsRepo.Read")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return comments.Comment{}, err
+	}
+
+	raw, err := r.rdb.HGet(ctx, commentsKey(workspace, taskID), id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return comments.Comment{}, fmt.Errorf("%w: %s", comments.ErrCommentNotFound, id)
+		}
+		return comments.Comment{}, err
+	}
+
+	var c comments.Comment
+	if err := json.Unmarshal([]byte(raw), &c); err != nil {
+		return comments.Comment{}, err
+	}
+	return c, nil
+}
+
+func (r CommentsRepo) ReadAll(ctx context.Context, workspace, taskID string) ([]comments.Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "CommentsRepo.ReadAll")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	res, err := r.rdb.HVals(ctx, commentsKey(workspace, taskID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]comments.Comment, 0, len(res))
+	for _, raw := range res {
+		var c comments.Comment
+		if err := json.Unmarshal([]byte(raw), &c); err != nil {
+			return nil, err
+		}
+		result = append(result, c)
+	}
+	return result, nil
+}
+
+func (r CommentsRepo) Update(ctx context.Context, workspace string, comment comments.Comment) (comments.Comment, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "CommentsRepo.Update")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return comments.Comment{}, err
+	}
+
+	comment.Replies = nil
+	raw, err := json.Marshal(comment)
+	if err != nil {
+		return comments.Comment{}, err
+	}
+
+	ok, err := replaceHashField.Run(ctx, r.rdb,
+		[]string{commentsKey(workspace, comment.TaskID)},
+		comment.ID, raw,
+	).Int()
+	if err != nil {
+		return comments.Comment{}, err
+	}
+	if ok == 0 {
+		return comments.Comment{}, fmt.Errorf("%w: %s", comments.ErrCommentNotFound, comment.ID)
+	}
+	return comment, nil
+}
+
+func (r CommentsRepo) Delete(ctx context.Context, workspace, taskID, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "CommentsRepo.Delete")
+	defer span.End()
+
+	current, err := r.Read(ctx, workspace, taskID, id)
+	if err != nil {
+		return err
+	}
+
+	parentReplies := ""
+	if current.ParentID != "" {
+		parentReplies = commentRepliesKey(workspace, taskID, current.ParentID)
+	}
+
+	removed, err := deleteComment.Run(ctx, r.rdb,
+		[]string{
+			taskKey(workspace, taskID),
+			commentsKey(workspace, taskID),
+			commentRepliesKey(workspace, taskID, id),
+			parentReplies,
+		},
+		id,
+	).Int()
+	if err != nil {
+		return err
+	}
+	if removed == 0 {
+		return fmt.Errorf("%w: %s", comments.ErrCommentNotFound, id)
+	}
+	return nil
+}
+
+// deleteTaskComments queues removal of all comments of the task on pipe, ids are the ids of the comments.
+func deleteTaskComments(ctx context.Context, pipe redis.Pipeliner, workspace, taskID string, ids []string) {
+	pipe.Del(ctx, commentsKey(workspace, taskID))
+	for _, id := range ids {
+		pipe.Del(ctx, commentRepliesKey(workspace, taskID, id))
+	}
+}
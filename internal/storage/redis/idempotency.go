@@ -0,0 +1,78 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/idempotency"
+)
+
+const idempotencyPrefix = "idempotency"
+
+// IdempotencyStore keeps records as JSON strings that expire after their TTL.
+type IdempotencyStore struct {
+	rdb *redis.Client
+}
+
+var _ idempotency.Store = (*IdempotencyStore)(nil)
+
+func idempotencyKey(key string) string {
+	return fmt.Sprintf("%s:%s", idempotencyPrefix, key)
+}
+
+func (s IdempotencyStore) Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (idempotency.Record, bool, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "IdempotencyStore.Begin")
+	defer span.End()
+
+	record := idempotency.Record{Fingerprint: fingerprint}
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return idempotency.Record{}, false, err
+	}
+
+	ok, err := s.rdb.SetNX(ctx, idempotencyKey(key), raw, ttl).Result()
+	if err != nil {
+		return idempotency.Record{}, false, err
+	}
+	if ok {
+		return record, true, nil
+	}
+
+	existing, err := s.rdb.Get(ctx, idempotencyKey(key)).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			// The record expired in between, treat it as if we were first.
+			return s.Begin(ctx, key, fingerprint, ttl)
+		}
+		return idempotency.Record{}, false, err
+	}
+
+	if err := json.Unmarshal(existing, &record); err != nil {
+		return idempotency.Record{}, false, err
+	}
+	return record, false, nil
+}
+
+func (s IdempotencyStore) Complete(ctx context.Context, key string, record idempotency.Record, ttl time.Duration) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "IdempotencyStore.Complete")
+	defer span.End()
+
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.rdb.Set(ctx, idempotencyKey(key), raw, ttl).Err()
+}
+
+func (s IdempotencyStore) Abort(ctx context.Context, key string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "IdempotencyStore.Abort")
+	defer span.End()
+
+	return s.rdb.Del(ctx, idempotencyKey(key)).Err()
+}
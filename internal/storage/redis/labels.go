@@ -0,0 +1,200 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// The catalogue of a workspace is the hash at "tasks:{<workspace>}:labels",
+// its fields are label names and values are JSON encoded labels.
+
+// deleteLabel removes a label from the catalogue and from every task that has it.
+// The caller reads the index first and passes the tasks it found, if a task was labelled in between
+// nothing is changed and the caller has to read the index again.
+//
+// KEYS[1] - catalogue, KEYS[2] - index of tasks with the label, KEYS[3..] - tasks in the index.
+// ARGV[1] - label, ARGV[2..] - ids of the tasks, in the order of their keys.
+// Returns 0 if there is no such label, -1 if the index doesn't match the tasks.
+var deleteLabel = redis.NewScript(`
+local members = redis.call('SMEMBERS', KEYS[2])
+if #members ~= #KEYS - 2 then
+	return -1
+end
+local passed = {}
+for i = 2, #ARGV do
+	passed[ARGV[i]] = true
+end
+for _, id in ipairs(members) do
+	if not passed[id] then
+		return -1
+	end
+end
+if redis.call('HDEL', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+for i = 3, #KEYS do
+	local current = redis.call('HGET', KEYS[i], 'labels')
+	if current then
+		local kept = {}
+		for l in string.gmatch(current, '[^,]+') do
+			if l ~= ARGV[1] then
+				table.insert(kept, l)
+			end
+		end
+		redis.call('HSET', KEYS[i], 'labels', table.concat(kept, ','))
+	end
+end
+redis.call('DEL', KEYS[2])
+return 1
+`)
+
+type LabelsRepo struct {
+	rdb *redis.Client
+}
+
+var _ labels.Repository = (*LabelsRepo)(nil)
+
+func labelsKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:labels", servicePrefix, workspace)
+}
+
+func (r LabelsRepo) Create(ctx context.Context, workspace string, label labels.Label) (labels.Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "LabelsRepo.Create")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return labels.Label{}, err
+	}
+
+	raw, err := json.Marshal(label)
+	if err != nil {
+		return labels.Label{}, err
+	}
+
+	ok, err := r.rdb.HSetNX(ctx, labelsKey(workspace), label.Name, raw).Result()
+	if err != nil {
+		return labels.Label{}, err
+	}
+	if !ok {
+		return labels.Label{}, fmt.Errorf("%w: %s", labels.ErrLabelExists, label.Name)
+	}
+	return label, nil
+}
+
+func (r LabelsRepo) Read(ctx context.Context, workspace, name string) (labels.Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "LabelsRepo.Read")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return labels.Label{}, err
+	}
+
+	raw, err := r.rdb.HGet(ctx, labelsKey(workspace), name).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return labels.Label{}, fmt.Errorf("%w: %s", labels.ErrLabelNotFound, name)
+		}
+		return labels.Label{}, err
+	}
+
+	var l labels.Label
+	if err := json.Unmarshal([]byte(raw), &l); err != nil {
+		return labels.Label{}, err
+	}
+	return l, nil
+}
+
+func (r LabelsRepo) ReadAll(ctx context.Context, workspace string) ([]labels.Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "LabelsRepo.ReadAll")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	res, err := r.rdb.HVals(ctx, labelsKey(workspace)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]labels.Label, 0, len(res))
+	for _, raw := range res {
+		var l labels.Label
+		if err := json.Unmarshal([]byte(raw), &l); err != nil {
+			return nil, err
+		}
+		result = append(result, l)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+func (r LabelsRepo) Update(ctx context.Context, workspace string, label labels.Label) (labels.Label, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "LabelsRepo.Update")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return labels.Label{}, err
+	}
+
+	raw, err := json.Marshal(label)
+	if err != nil {
+		return labels.Label{}, err
+	}
+
+	ok, err := replaceHashField.Run(ctx, r.rdb, []string{labelsKey(workspace)}, label.Name, raw).Int()
+	if err != nil {
+		return labels.Label{}, err
+	}
+	if ok == 0 {
+		return labels.Label{}, fmt.Errorf("%w: %s", labels.ErrLabelNotFound, label.Name)
+	}
+	return label, nil
+}
+
+func (r LabelsRepo) Delete(ctx context.Context, workspace, name string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "LabelsRepo.Delete")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	index := tasksByLabelKey(workspace, name)
+	for i := 0; i < maxWatchRetries; i++ {
+		ids, err := r.rdb.SMembers(ctx, index).Result()
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(ids)+2)
+		keys = append(keys, labelsKey(workspace), index)
+		args := make([]interface{}, 0, len(ids)+1)
+		args = append(args, name)
+		for _, id := range ids {
+			keys = append(keys, taskKey(workspace, id))
+			args = append(args, id)
+		}
+
+		ok, err := deleteLabel.Run(ctx, r.rdb, keys, args...).Int()
+		if err != nil {
+			return err
+		}
+		switch ok {
+		case -1:
+			// A task was labelled since the index was read.
+			continue
+		case 0:
+			return fmt.Errorf("%w: %s", labels.ErrLabelNotFound, name)
+		}
+		return nil
+	}
+	return errContention
+}
@@ -0,0 +1,255 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Links from a task are in the set at "tasks:{<workspace>}:<id>:links:out" as "<type>:<other id>",
+// and links to it are in the set at "tasks:{<workspace>}:<id>:links:in" in the same form,
+// so that a link is written to both of its tasks.
+
+// addLink links two tasks, unless either of them is gone.
+//
+// KEYS[1] - task the link is from, KEYS[2] - task the link is to,
+// KEYS[3] - links from the first task, KEYS[4] - links to the second task.
+// ARGV[1] - "<type>:<to>", ARGV[2] - "<type>:<from>".
+// Returns 1 on success, 0 if the first task doesn't exist, -1 if the second one doesn't.
+var addLink = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+if redis.call('EXISTS', KEYS[2]) == 0 then
+	return -1
+end
+redis.call('SADD', KEYS[3], ARGV[1])
+redis.call('SADD', KEYS[4], ARGV[2])
+return 1
+`)
+
+func linksOutKey(workspace, id string) string {
+	return taskKey(workspace, id) + ":links:out"
+}
+
+func linksInKey(workspace, id string) string {
+	return taskKey(workspace, id) + ":links:in"
+}
+
+func linkMember(linkType, id string) string {
+	return linkType + ":" + id
+}
+
+func (r TasksRepo) AddLink(ctx context.Context, workspace string, link tasks.Link) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.AddLink")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "AddLink", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	keys := []string{
+		taskKey(workspace, link.From), taskKey(workspace, link.To),
+		linksOutKey(workspace, link.From), linksInKey(workspace, link.To),
+	}
+	args := []interface{}{linkMember(link.Type, link.To), linkMember(link.Type, link.From)}
+
+	var res int
+	if link.Type != tasks.LinkBlocks {
+		var err error
+		if res, err = addLink.Run(ctx, r.rdb, keys, args...).Int(); err != nil {
+			return err
+		}
+	} else {
+		// Two blocking links added at once could close a cycle that neither of them makes alone,
+		// so the link is only added if links it was checked against didn't change.
+		err := watch(ctx, r.rdb, func(tx *redis.Tx) error {
+			if err := checkBlocking(ctx, tx, workspace, link); err != nil {
+				return err
+			}
+			var cmd *redis.Cmd
+			_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				cmd = addLink.Eval(ctx, pipe, keys, args...)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			res, err = cmd.Int()
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	switch res {
+	case 0:
+		return fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, link.From)
+	case -1:
+		return fmt.Errorf("%w: %s", tasks.ErrLinkedTaskNotFound, link.To)
+	}
+	return nil
+}
+
+// checkBlocking watches links from every task the link's target blocks, directly or not,
+// and makes sure the link's source is not among them, since the link would make a cycle.
+// Links are read a layer at a time, it fails with ErrGraphTooLarge past MaxGraphDepth or MaxGraphNodes.
+func checkBlocking(ctx context.Context, tx *redis.Tx, workspace string, link tasks.Link) error {
+	seen := map[string]bool{link.To: true}
+	layer := []string{link.To}
+	for depth := 0; len(layer) > 0; depth++ {
+		if depth == tasks.MaxGraphDepth {
+			return fmt.Errorf("%w: %s", tasks.ErrGraphTooLarge, link.To)
+		}
+		keys := make([]string, len(layer))
+		for i, id := range layer {
+			keys[i] = linksOutKey(workspace, id)
+		}
+		if err := tx.Watch(ctx, keys...).Err(); err != nil {
+			return err
+		}
+		cmds := make([]*redis.StringSliceCmd, len(keys))
+		_, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = pipe.SMembers(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		var next []string
+		for _, cmd := range cmds {
+			for _, m := range cmd.Val() {
+				linkType, id, ok := strings.Cut(m, ":")
+				if !ok || linkType != tasks.LinkBlocks || seen[id] {
+					continue
+				}
+				if id == link.From {
+					return fmt.Errorf("%w: %s %s %s", tasks.ErrLinkCycle, link.From, link.Type, link.To)
+				}
+				if len(seen) == tasks.MaxGraphNodes {
+					return fmt.Errorf("%w: %s", tasks.ErrGraphTooLarge, link.To)
+				}
+				seen[id] = true
+				next = append(next, id)
+			}
+		}
+		layer = next
+	}
+	return nil
+}
+
+func (r TasksRepo) RemoveLink(ctx context.Context, workspace string, link tasks.Link) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.RemoveLink")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "RemoveLink", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	var removed *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		removed = pipe.SRem(ctx, linksOutKey(workspace, link.From), linkMember(link.Type, link.To))
+		pipe.SRem(ctx, linksInKey(workspace, link.To), linkMember(link.Type, link.From))
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if removed.Val() == 0 {
+		return fmt.Errorf("%w: %s %s %s", tasks.ErrLinkNotFound, link.From, link.Type, link.To)
+	}
+	return nil
+}
+
+func (r TasksRepo) ReadLinks(ctx context.Context, workspace, id string) ([]tasks.Link, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadLinks")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadLinks", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	return readLinks(ctx, r.rdb, workspace, id)
+}
+
+func (r TasksRepo) ReadLinksOf(ctx context.Context, workspace string, ids []string) (map[string][]tasks.Link, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadLinksOf")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadLinksOf", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	return readLinksOf(ctx, r.rdb, workspace, ids)
+}
+
+// readLinks returns links from and to the task, rdb can be a transaction that watches them.
+func readLinks(ctx context.Context, rdb redis.Cmdable, workspace, id string) ([]tasks.Link, error) {
+	links, err := readLinksOf(ctx, rdb, workspace, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	return links[id], nil
+}
+
+// readLinksOf reads links of every task in one round trip.
+func readLinksOf(ctx context.Context, rdb redis.Cmdable, workspace string, ids []string) (map[string][]tasks.Link, error) {
+	links := make(map[string][]tasks.Link, len(ids))
+	if len(ids) == 0 {
+		return links, nil
+	}
+
+	out := make([]*redis.StringSliceCmd, len(ids))
+	in := make([]*redis.StringSliceCmd, len(ids))
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			out[i] = pipe.SMembers(ctx, linksOutKey(workspace, id))
+			in[i] = pipe.SMembers(ctx, linksInKey(workspace, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, id := range ids {
+		l := make([]tasks.Link, 0, len(out[i].Val())+len(in[i].Val()))
+		for _, m := range out[i].Val() {
+			if linkType, other, ok := strings.Cut(m, ":"); ok {
+				l = append(l, tasks.Link{Type: linkType, From: id, To: other})
+			}
+		}
+		for _, m := range in[i].Val() {
+			if linkType, other, ok := strings.Cut(m, ":"); ok {
+				l = append(l, tasks.Link{Type: linkType, From: other, To: id})
+			}
+		}
+		links[id] = l
+	}
+	return links, nil
+}
+
+// deleteTaskLinks removes links from and to a task that is being deleted, links are read beforehand.
+func deleteTaskLinks(ctx context.Context, pipe redis.Pipeliner, workspace, id string, links []tasks.Link) {
+	for _, l := range links {
+		if l.From == id {
+			pipe.SRem(ctx, linksInKey(workspace, l.To), linkMember(l.Type, id))
+		} else {
+			pipe.SRem(ctx, linksOutKey(workspace, l.From), linkMember(l.Type, id))
+		}
+	}
+	pipe.Del(ctx, linksOutKey(workspace, id), linksInKey(workspace, id))
+}
@@ -0,0 +1,53 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
+)
+
+// Locks are strings at "tasks:lock:<name>" holding a random token of their holder, they expire after their TTL.
+
+// releaseLock removes a lock, unless it expired and was taken by someone else.
+//
+// KEYS[1] - lock.
+// ARGV[1] - token of the holder.
+// Returns 1 if the lock was released, 0 if it wasn't held with the token.
+var releaseLock = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+type Locker struct {
+	rdb *redis.Client
+}
+
+var _ recurring.Locker = (*Locker)(nil)
+
+func lockKey(name string) string {
+	return fmt.Sprintf("%s:lock:%s", servicePrefix, name)
+}
+
+func (l Locker) Lock(ctx context.Context, name string, ttl time.Duration) (func(context.Context) error, bool, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "Locker.Lock")
+	defer span.End()
+
+	token := uuid.New().String()
+	ok, err := l.rdb.SetNX(ctx, lockKey(name), token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	release := func(ctx context.Context) error {
+		return releaseLock.Run(ctx, l.rdb, []string{lockKey(name)}, token).Err()
+	}
+	return release, true, nil
+}
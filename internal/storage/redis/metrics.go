@@ -0,0 +1,123 @@
+package redis
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+type repoMetrics struct {
+	duration metric.Float64Histogram
+}
+
+// newRepoMetrics registers latency of repository operations,
+// stats of the connection pool, counts of tasks by status and of overdue tasks.
+func newRepoMetrics(rdb *redis.Client) (*repoMetrics, error) {
+	meter := otel.Meter(otelName)
+
+	duration, err := meter.Float64Histogram(
+		"repository.operation.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of repository operations"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := registerPoolStats(meter, rdb); err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"tasks.count",
+		metric.WithDescription("Number of tasks by status across all workspaces"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			counts, err := rdb.HGetAll(ctx, tasksStatsKey).Result()
+			if err != nil {
+				return err
+			}
+			for status, raw := range counts {
+				n, err := strconv.ParseInt(raw, 10, 64)
+				if err != nil {
+					return err
+				}
+				o.Observe(n, metric.WithAttributes(attribute.String("status", status)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.Int64ObservableGauge(
+		"tasks.overdue",
+		metric.WithDescription("Number of tasks past their due date and not done across all workspaces"),
+		metric.WithInt64Callback(func(ctx context.Context, o metric.Int64Observer) error {
+			now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			n, err := rdb.ZCount(ctx, tasksDueKey, "-inf", "("+now).Result()
+			if err != nil {
+				return err
+			}
+			o.Observe(n)
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repoMetrics{duration: duration}, nil
+}
+
+func registerPoolStats(meter metric.Meter, rdb *redis.Client) error {
+	hits, err := meter.Int64ObservableCounter("redis.pool.hits", metric.WithDescription("Number of times a free connection was found in the pool"))
+	if err != nil {
+		return err
+	}
+	misses, err := meter.Int64ObservableCounter("redis.pool.misses", metric.WithDescription("Number of times a free connection was not found in the pool"))
+	if err != nil {
+		return err
+	}
+	timeouts, err := meter.Int64ObservableCounter("redis.pool.timeouts", metric.WithDescription("Number of times a wait timeout occurred"))
+	if err != nil {
+		return err
+	}
+	total, err := meter.Int64ObservableGauge("redis.pool.connections.total", metric.WithDescription("Number of connections in the pool"))
+	if err != nil {
+		return err
+	}
+	idle, err := meter.Int64ObservableGauge("redis.pool.connections.idle", metric.WithDescription("Number of idle connections in the pool"))
+	if err != nil {
+		return err
+	}
+	stale, err := meter.Int64ObservableCounter("redis.pool.connections.stale", metric.WithDescription("Number of stale connections removed from the pool"))
+	if err != nil {
+		return err
+	}
+
+	_, err = meter.RegisterCallback(func(ctx context.Context, o metric.Observer) error {
+		s := rdb.PoolStats()
+		o.ObserveInt64(hits, int64(s.Hits))
+		o.ObserveInt64(misses, int64(s.Misses))
+		o.ObserveInt64(timeouts, int64(s.Timeouts))
+		o.ObserveInt64(total, int64(s.TotalConns))
+		o.ObserveInt64(idle, int64(s.IdleConns))
+		o.ObserveInt64(stale, int64(s.StaleConns))
+		return nil
+	}, hits, misses, timeouts, total, idle, stale)
+	return err
+}
+
+// observe records how long the operation of the repository took since begin.
+func (m *repoMetrics) observe(ctx context.Context, repository, operation string, begin time.Time) {
+	m.duration.Record(ctx, time.Since(begin).Seconds(), metric.WithAttributes(
+		attribute.String("repository", repository),
+		attribute.String("operation", operation),
+	))
+}
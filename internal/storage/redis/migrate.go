@@ -0,0 +1,151 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+)
+
+// Before tasks were partitioned by workspace they were stored in hashes at "tasks:<id>",
+// users were in hashes at "users:<id>" with the "users:index" set and "users:email:<email>" keys,
+// and api keys had no index of their workspace. Migrate moves all of them into the default workspace,
+// it is safe to run on every start and from several replicas at once.
+
+// legacyIDPattern matches "<prefix>:<uuid>" and nothing with a workspace in it.
+const legacyIDPattern = "????????-????-????-????-????????????"
+
+// migrateTask moves a legacy task into a workspace, unless it was moved already.
+//
+// KEYS[1] - legacy task, KEYS[2] - task, KEYS[3] - tasks index.
+// ARGV[1] - id, ARGV[2] - status of tasks that have none.
+// Returns the status of the moved task, or an empty string if there was nothing to move.
+var migrateTask = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 or redis.call('EXISTS', KEYS[2]) == 1 then
+	return ''
+end
+redis.call('HSET', KEYS[2], unpack(redis.call('HGETALL', KEYS[1])))
+redis.call('HSETNX', KEYS[2], 'status', ARGV[2])
+redis.call('SADD', KEYS[3], ARGV[1])
+redis.call('DEL', KEYS[1])
+return redis.call('HGET', KEYS[2], 'status')
+`)
+
+// migrateUser moves a legacy user into a workspace, unless it was moved already.
+//
+// KEYS[1] - legacy user, KEYS[2] - user, KEYS[3] - legacy users index, KEYS[4] - users index,
+// KEYS[5] - legacy email, KEYS[6] - email.
+// ARGV[1] - id.
+// Returns 1 if the user was moved, 0 if there was nothing to move.
+var migrateUser = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 or redis.call('EXISTS', KEYS[2]) == 1 then
+	return 0
+end
+redis.call('HSET', KEYS[2], unpack(redis.call('HGETALL', KEYS[1])))
+redis.call('SADD', KEYS[4], ARGV[1])
+redis.call('SETNX', KEYS[6], ARGV[1])
+redis.call('DEL', KEYS[1], KEYS[5])
+redis.call('SREM', KEYS[3], ARGV[1])
+return 1
+`)
+
+// Migrated tells how much Migrate moved.
+type Migrated struct {
+	Tasks   int
+	Users   int
+	APIKeys int
+}
+
+// Migrate moves tasks, users and api keys created before workspaces into workspace.
+func (r RepoCombiner) Migrate(ctx context.Context, workspace string) (Migrated, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RepoCombiner.Migrate")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return Migrated{}, err
+	}
+
+	var res Migrated
+	rdb := r.tasks.rdb
+
+	err := scanLegacy(ctx, rdb, servicePrefix, func(id string) error {
+		status, err := migrateTask.Run(ctx, rdb,
+			[]string{fmt.Sprintf("%s:%s", servicePrefix, id), taskKey(workspace, id), tasksIndexKey(workspace)},
+			id, tasks.StatusTodo,
+		).Text()
+		if err != nil || status == "" {
+			return err
+		}
+		res.Tasks++
+		// Legacy tasks were never counted.
+		return rdb.HIncrBy(ctx, tasksStatsKey, status, 1).Err()
+	})
+	if err != nil {
+		return res, err
+	}
+
+	err = scanLegacy(ctx, rdb, usersPrefix, func(id string) error {
+		email, err := rdb.HGet(ctx, fmt.Sprintf("%s:%s", usersPrefix, id), "email").Result()
+		if err != nil {
+			if err == redis.Nil {
+				// Moved by another replica in the meantime.
+				return nil
+			}
+			return err
+		}
+		moved, err := migrateUser.Run(ctx, rdb,
+			[]string{
+				fmt.Sprintf("%s:%s", usersPrefix, id), userKey(workspace, id),
+				usersPrefix + ":index", usersIndexKey(workspace),
+				fmt.Sprintf("%s:email:%s", usersPrefix, email), userEmailKey(workspace, email),
+			},
+			id,
+		).Int()
+		res.Users += moved
+		return err
+	})
+	if err != nil {
+		return res, err
+	}
+
+	err = scanLegacy(ctx, rdb, apiKeysPrefix, func(id string) error {
+		owner, err := rdb.HGet(ctx, apiKeyKey(id), "workspace").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		if owner != "" {
+			// Keys issued in a workspace are indexed when they are created.
+			return nil
+		}
+		_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, apiKeyKey(id), "workspace", workspace)
+			pipe.SAdd(ctx, apiKeysIndexKey(workspace), id)
+			return nil
+		})
+		if err == nil {
+			res.APIKeys++
+		}
+		return err
+	})
+	return res, err
+}
+
+// scanLegacy calls fn with the id of every "<prefix>:<uuid>" key.
+func scanLegacy(ctx context.Context, rdb *redis.Client, prefix string, fn func(id string) error) error {
+	iter := rdb.Scan(ctx, 0, prefix+":"+legacyIDPattern, 1000).Iterator()
+	for iter.Next(ctx) {
+		id := strings.TrimPrefix(iter.Val(), prefix+":")
+		if _, err := uuid.Parse(id); err != nil {
+			continue
+		}
+		if err := fn(id); err != nil {
+			return err
+		}
+	}
+	return iter.Err()
+}
@@ -0,0 +1,209 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Projects of a workspace are JSON values in the hash at "tasks:{<workspace>}:projects", keyed by project id.
+// Tasks on their boards are kept by TasksRepo, see boardColumnKey.
+
+// replaceProject replaces a project, unless it is gone or a column it no longer has still has tasks.
+//
+// KEYS[1] - projects, KEYS[2...] - columns that are removed.
+// ARGV[1] - id, ARGV[2] - project.
+// Returns 1 on success, 0 if the project doesn't exist, -1 if a removed column isn't empty.
+var replaceProject = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+for i = 2, #KEYS do
+	if redis.call('ZCARD', KEYS[i]) > 0 then
+		return -1
+	end
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+return 1
+`)
+
+// deleteProject removes a project, unless any of its columns still has tasks.
+//
+// KEYS[1] - projects, KEYS[2...] - columns of the project.
+// ARGV[1] - id.
+// Returns 1 on success, 0 if the project doesn't exist, -1 if a column isn't empty.
+var deleteProject = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+for i = 2, #KEYS do
+	if redis.call('ZCARD', KEYS[i]) > 0 then
+		return -1
+	end
+end
+redis.call('HDEL', KEYS[1], ARGV[1])
+return 1
+`)
+
+type ProjectsRepo struct {
+	rdb *redis.Client
+}
+
+var _ projects.Repository = (*ProjectsRepo)(nil)
+
+func projectsKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:projects", servicePrefix, workspace)
+}
+
+// withColumnIDs gives ids to the columns that have none.
+func withColumnIDs(columns []projects.Column) []projects.Column {
+	result := make([]projects.Column, len(columns))
+	for i, c := range columns {
+		if c.ID == "" {
+			c.ID = uuid.New().String()
+		}
+		result[i] = c
+	}
+	return result
+}
+
+func (r ProjectsRepo) Create(ctx context.Context, workspace string, project projects.Project) (projects.Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "ProjectsRepo.Create")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return projects.Project{}, err
+	}
+
+	project.ID = uuid.New().String()
+	project.Columns = withColumnIDs(project.Columns)
+	raw, err := json.Marshal(project)
+	if err != nil {
+		return projects.Project{}, err
+	}
+
+	if err := r.rdb.HSet(ctx, projectsKey(workspace), project.ID, raw).Err(); err != nil {
+		return projects.Project{}, err
+	}
+	return project, nil
+}
+
+func (r ProjectsRepo) Read(ctx context.Context, workspace, id string) (projects.Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "ProjectsRepo.Read")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return projects.Project{}, err
+	}
+
+	raw, err := r.rdb.HGet(ctx, projectsKey(workspace), id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return projects.Project{}, fmt.Errorf("%w: %s", projects.ErrProjectNotFound, id)
+		}
+		return projects.Project{}, err
+	}
+
+	var p projects.Project
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return projects.Project{}, err
+	}
+	return p, nil
+}
+
+func (r ProjectsRepo) ReadAll(ctx context.Context, workspace string) ([]projects.Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "ProjectsRepo.ReadAll")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	res, err := r.rdb.HVals(ctx, projectsKey(workspace)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]projects.Project, 0, len(res))
+	for _, raw := range res {
+		var p projects.Project
+		if err := json.Unmarshal([]byte(raw), &p); err != nil {
+			return nil, err
+		}
+		result = append(result, p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (r ProjectsRepo) Update(ctx context.Context, workspace string, project projects.Project) (projects.Project, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "ProjectsRepo.Update")
+	defer span.End()
+
+	current, err := r.Read(ctx, workspace, project.ID)
+	if err != nil {
+		return projects.Project{}, err
+	}
+
+	project.Columns = withColumnIDs(project.Columns)
+	raw, err := json.Marshal(project)
+	if err != nil {
+		return projects.Project{}, err
+	}
+
+	keys := []string{projectsKey(workspace)}
+	for _, c := range current.Columns {
+		if _, ok := project.Column(c.ID); !ok {
+			keys = append(keys, boardColumnKey(workspace, project.ID, c.ID))
+		}
+	}
+
+	res, err := replaceProject.Run(ctx, r.rdb, keys, project.ID, raw).Int()
+	if err != nil {
+		return projects.Project{}, err
+	}
+
+	switch res {
+	case 0:
+		return projects.Project{}, fmt.Errorf("%w: %s", projects.ErrProjectNotFound, project.ID)
+	case -1:
+		return projects.Project{}, fmt.Errorf("%w: project %s", projects.ErrColumnNotEmpty, project.ID)
+	}
+	return project, nil
+}
+
+func (r ProjectsRepo) Delete(ctx context.Context, workspace, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "ProjectsRepo.Delete")
+	defer span.End()
+
+	current, err := r.Read(ctx, workspace, id)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{projectsKey(workspace)}
+	for _, c := range current.Columns {
+		keys = append(keys, boardColumnKey(workspace, id, c.ID))
+	}
+
+	res, err := deleteProject.Run(ctx, r.rdb, keys, id).Int()
+	if err != nil {
+		return err
+	}
+
+	switch res {
+	case 0:
+		return fmt.Errorf("%w: %s", projects.ErrProjectNotFound, id)
+	case -1:
+		return fmt.Errorf("%w: %s", projects.ErrProjectNotEmpty, id)
+	}
+	return nil
+}
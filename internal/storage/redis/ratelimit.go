@@ -0,0 +1,77 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/ratelimit"
+)
+
+const rateLimitPrefix = "ratelimit"
+
+// tokenBucket refills the bucket for the time passed since the last request and takes a token if there is one.
+// Time is taken from redis, so that clock skew between replicas doesn't matter.
+//
+// KEYS[1] - bucket, ARGV[1] - rate per second, ARGV[2] - burst.
+// Returns {allowed, tokens left}.
+var tokenBucket = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+
+local t = redis.call('TIME')
+local now = tonumber(t[1]) + tonumber(t[2]) / 1000000
+
+local bucket = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(bucket[1]) or burst
+local ts = tonumber(bucket[2]) or now
+
+tokens = math.min(burst, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+
+return {allowed, tostring(tokens)}
+`)
+
+// RateLimiter shares token buckets between all replicas through redis.
+type RateLimiter struct {
+	rdb *redis.Client
+}
+
+var _ ratelimit.Limiter = (*RateLimiter)(nil)
+
+func (r RateLimiter) Allow(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RateLimiter.Allow")
+	defer span.End()
+
+	res, err := tokenBucket.Run(
+		ctx, r.rdb,
+		[]string{fmt.Sprintf("%s:%s", rateLimitPrefix, key)},
+		limit.Rate, limit.Burst,
+	).Slice()
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	if len(res) != 2 {
+		return ratelimit.Result{}, fmt.Errorf("ratelimit: unexpected script result %v", res)
+	}
+
+	allowed, _ := res[0].(int64)
+	raw, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return ratelimit.Result{}, fmt.Errorf("ratelimit: unexpected tokens %q: %w", raw, err)
+	}
+
+	return ratelimit.NewResult(limit, allowed == 1, tokens), nil
+}
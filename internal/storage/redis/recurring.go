@@ -0,0 +1,290 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Recurring task templates of a workspace are JSON values in the hash at "tasks:{<workspace>}:recurring",
+// keyed by template id, and their cursors are in the hash at "tasks:{<workspace>}:recurring:cursors"
+// as unix milliseconds. "<workspace>/<id>" of every scheduled template is in the sorted set at
+// "tasks:recurring", scored by its next occurrence in unix milliseconds.
+
+const recurringScheduleKey = servicePrefix + ":recurring"
+
+// replaceTemplate replaces a template, unless it is gone, and moves its cursor if it is behind.
+//
+// KEYS[1] - templates, KEYS[2] - cursors.
+// ARGV[1] - id, ARGV[2] - template, ARGV[3] - cursor.
+// Returns the cursor on success, -1 if the template doesn't exist.
+var replaceTemplate = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return -1
+end
+redis.call('HSET', KEYS[1], ARGV[1], ARGV[2])
+local cursor = tonumber(redis.call('HGET', KEYS[2], ARGV[1]) or '0')
+if cursor < tonumber(ARGV[3]) then
+	cursor = tonumber(ARGV[3])
+	redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+end
+return cursor
+`)
+
+// advanceCursor moves the cursor of a template if it is where the caller expects it to be.
+//
+// KEYS[1] - templates, KEYS[2] - cursors.
+// ARGV[1] - id, ARGV[2] - expected cursor, ARGV[3] - new cursor.
+// Returns 1 on success, 0 if the template doesn't exist, -1 if the cursor is elsewhere.
+var advanceCursor = redis.NewScript(`
+if redis.call('HEXISTS', KEYS[1], ARGV[1]) == 0 then
+	return 0
+end
+if redis.call('HGET', KEYS[2], ARGV[1]) ~= ARGV[2] then
+	return -1
+end
+redis.call('HSET', KEYS[2], ARGV[1], ARGV[3])
+return 1
+`)
+
+type RecurringRepo struct {
+	rdb *redis.Client
+}
+
+var _ recurring.Repository = (*RecurringRepo)(nil)
+
+func recurringKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:recurring", servicePrefix, workspace)
+}
+
+func recurringCursorsKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:recurring:cursors", servicePrefix, workspace)
+}
+
+// schedule keeps the template in the schedule while it has a next occurrence.
+// The schedule is shared by all workspaces, so it is not written by the scripts.
+func schedule(ctx context.Context, rdb redis.Cmdable, workspace, id string, next *time.Time) error {
+	member := workspace + "/" + id
+	if next == nil {
+		return rdb.ZRem(ctx, recurringScheduleKey, member).Err()
+	}
+	return rdb.ZAdd(ctx, recurringScheduleKey, redis.Z{Score: float64(next.UnixMilli()), Member: member}).Err()
+}
+
+func parseTemplate(raw, cursor string) (recurring.Template, error) {
+	var t recurring.Template
+	if err := json.Unmarshal([]byte(raw), &t); err != nil {
+		return recurring.Template{}, err
+	}
+	if ms, err := strconv.ParseInt(cursor, 10, 64); err == nil {
+		t.Cursor = time.UnixMilli(ms).UTC()
+	}
+	return t, nil
+}
+
+func (r RecurringRepo) Create(ctx context.Context, workspace string, template recurring.Template, next *time.Time) (recurring.Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.Create")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return recurring.Template{}, err
+	}
+
+	template.ID = uuid.New().String()
+	template.Next = nil
+	raw, err := json.Marshal(template)
+	if err != nil {
+		return recurring.Template{}, err
+	}
+
+	_, err = r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, recurringKey(workspace), template.ID, raw)
+		pipe.HSet(ctx, recurringCursorsKey(workspace), template.ID, template.Cursor.UnixMilli())
+		return nil
+	})
+	if err != nil {
+		return recurring.Template{}, err
+	}
+	if err := schedule(ctx, r.rdb, workspace, template.ID, next); err != nil {
+		return recurring.Template{}, err
+	}
+
+	template.Cursor = time.UnixMilli(template.Cursor.UnixMilli()).UTC()
+	template.Next = next
+	return template, nil
+}
+
+func (r RecurringRepo) Read(ctx context.Context, workspace, id string) (recurring.Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.Read")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return recurring.Template{}, err
+	}
+
+	var raw, cursor *redis.StringCmd
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		raw = pipe.HGet(ctx, recurringKey(workspace), id)
+		cursor = pipe.HGet(ctx, recurringCursorsKey(workspace), id)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return recurring.Template{}, err
+	}
+	if raw.Err() == redis.Nil {
+		return recurring.Template{}, fmt.Errorf("%w: %s", recurring.ErrTemplateNotFound, id)
+	}
+
+	return parseTemplate(raw.Val(), cursor.Val())
+}
+
+func (r RecurringRepo) ReadAll(ctx context.Context, workspace string) ([]recurring.Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.ReadAll")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	var raws, cursors *redis.MapStringStringCmd
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		raws = pipe.HGetAll(ctx, recurringKey(workspace))
+		cursors = pipe.HGetAll(ctx, recurringCursorsKey(workspace))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]recurring.Template, 0, len(raws.Val()))
+	for id, raw := range raws.Val() {
+		t, err := parseTemplate(raw, cursors.Val()[id])
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result, nil
+}
+
+func (r RecurringRepo) Update(ctx context.Context, workspace string, template recurring.Template, next *time.Time) (recurring.Template, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.Update")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return recurring.Template{}, err
+	}
+
+	template.Next = nil
+	raw, err := json.Marshal(template)
+	if err != nil {
+		return recurring.Template{}, err
+	}
+
+	res, err := replaceTemplate.Run(ctx, r.rdb,
+		[]string{recurringKey(workspace), recurringCursorsKey(workspace)},
+		template.ID, raw, template.Cursor.UnixMilli(),
+	).Int64()
+	if err != nil {
+		return recurring.Template{}, err
+	}
+	if res == -1 {
+		return recurring.Template{}, fmt.Errorf("%w: %s", recurring.ErrTemplateNotFound, template.ID)
+	}
+	if err := schedule(ctx, r.rdb, workspace, template.ID, next); err != nil {
+		return recurring.Template{}, err
+	}
+
+	template.Cursor = time.UnixMilli(res).UTC()
+	template.Next = next
+	return template, nil
+}
+
+func (r RecurringRepo) Delete(ctx context.Context, workspace, id string) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.Delete")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	var deleted *redis.IntCmd
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		deleted = pipe.HDel(ctx, recurringKey(workspace), id)
+		pipe.HDel(ctx, recurringCursorsKey(workspace), id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if deleted.Val() == 0 {
+		return fmt.Errorf("%w: %s", recurring.ErrTemplateNotFound, id)
+	}
+	return schedule(ctx, r.rdb, workspace, id, nil)
+}
+
+func (r RecurringRepo) Due(ctx context.Context, before time.Time, limit int) ([]recurring.Ref, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.Due")
+	defer span.End()
+
+	res, err := r.rdb.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:     recurringScheduleKey,
+		Start:   "-inf",
+		Stop:    strconv.FormatInt(before.UnixMilli(), 10),
+		ByScore: true,
+		Count:   int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]recurring.Ref, 0, len(res))
+	for _, member := range res {
+		workspace, id, ok := strings.Cut(member, "/")
+		if !ok || checkWorkspace(workspace) != nil {
+			continue
+		}
+		result = append(result, recurring.Ref{Workspace: workspace, ID: id})
+	}
+	return result, nil
+}
+
+func (r RecurringRepo) Advance(ctx context.Context, workspace, id string, from, to time.Time, next *time.Time) error {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "RecurringRepo.Advance")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	res, err := advanceCursor.Run(ctx, r.rdb,
+		[]string{recurringKey(workspace), recurringCursorsKey(workspace)},
+		id, from.UnixMilli(), to.UnixMilli(),
+	).Int()
+	if err != nil {
+		return err
+	}
+	switch res {
+	case 0:
+		// A template deleted while it was scheduled is taken off the schedule here.
+		if err := schedule(ctx, r.rdb, workspace, id, nil); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", recurring.ErrTemplateNotFound, id)
+	case -1:
+		return fmt.Errorf("%w: %s", recurring.ErrCursorMoved, id)
+	}
+	return schedule(ctx, r.rdb, workspace, id, next)
+}
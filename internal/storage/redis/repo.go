@@ -2,6 +2,9 @@ package redis
 
 import (
 	"context"
+	"errors"
+	"math/rand"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 
@@ -11,8 +14,44 @@ import (
 
 const servicePrefix = "tasks"
 
+// maxWatchRetries bounds how many times a transaction is retried because keys it watches changed.
+const maxWatchRetries = 20
+
+// errContention is returned when a transaction kept losing to concurrent writes.
+var errContention = errors.New("redis: too many concurrent writes, transaction was not applied")
+
+// watch runs fn in a transaction that only applies if none of keys changed since they were watched,
+// and retries it from scratch if any did, so that fn can write what it read.
+// Retries are spread out a little, so that writers of the same keys don't keep colliding.
+func watch(ctx context.Context, rdb *redis.Client, fn func(tx *redis.Tx) error, keys ...string) error {
+	for i := 0; i < maxWatchRetries; i++ {
+		err := rdb.Watch(ctx, fn, keys...)
+		if err != redis.TxFailedErr {
+			return err
+		}
+
+		backoff := time.Duration(rand.Int63n(int64(i+1) * int64(time.Millisecond)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	return errContention
+}
+
 type RepoCombiner struct {
-	tasks TasksRepo
+	tasks       TasksRepo
+	apiKeys     APIKeysRepo
+	users       UsersRepo
+	comments    CommentsRepo
+	labels      LabelsRepo
+	projects    ProjectsRepo
+	attachments AttachmentsRepo
+	recurring   RecurringRepo
+	locker      Locker
+	rateLimiter RateLimiter
+	idempotency IdempotencyStore
 }
 
 func NewRepoCombiner(ctx context.Context, cfg config.Config) (RepoCombiner, error) {
@@ -26,8 +65,44 @@ func NewRepoCombiner(ctx context.Context, cfg config.Config) (RepoCombiner, erro
 		return RepoCombiner{}, res.Err()
 	}
 
+	metrics, err := newRepoMetrics(rdb)
+	if err != nil {
+		return RepoCombiner{}, err
+	}
+
 	return RepoCombiner{
 		tasks: TasksRepo{
+			rdb:     rdb,
+			metrics: metrics,
+		},
+		apiKeys: APIKeysRepo{
+			rdb: rdb,
+		},
+		users: UsersRepo{
+			rdb: rdb,
+		},
+		comments: CommentsRepo{
+			rdb: rdb,
+		},
+		labels: LabelsRepo{
+			rdb: rdb,
+		},
+		projects: ProjectsRepo{
+			rdb: rdb,
+		},
+		attachments: AttachmentsRepo{
+			rdb: rdb,
+		},
+		recurring: RecurringRepo{
+			rdb: rdb,
+		},
+		locker: Locker{
+			rdb: rdb,
+		},
+		rateLimiter: RateLimiter{
+			rdb: rdb,
+		},
+		idempotency: IdempotencyStore{
 			rdb: rdb,
 		},
 	}, nil
@@ -37,6 +112,46 @@ func (r RepoCombiner) Tasks() TasksRepo {
 	return r.tasks
 }
 
+func (r RepoCombiner) APIKeys() APIKeysRepo {
+	return r.apiKeys
+}
+
+func (r RepoCombiner) Users() UsersRepo {
+	return r.users
+}
+
+func (r RepoCombiner) Comments() CommentsRepo {
+	return r.comments
+}
+
+func (r RepoCombiner) Labels() LabelsRepo {
+	return r.labels
+}
+
+func (r RepoCombiner) Projects() ProjectsRepo {
+	return r.projects
+}
+
+func (r RepoCombiner) Attachments() AttachmentsRepo {
+	return r.attachments
+}
+
+func (r RepoCombiner) Recurring() RecurringRepo {
+	return r.recurring
+}
+
+func (r RepoCombiner) Locker() Locker {
+	return r.locker
+}
+
+func (r RepoCombiner) RateLimiter() RateLimiter {
+	return r.rateLimiter
+}
+
+func (r RepoCombiner) Idempotency() IdempotencyStore {
+	return r.idempotency
+}
+
 func (r RepoCombiner) Close() error {
 	return r.tasks.rdb.Close()
 }
@@ -2,37 +2,225 @@ package redis
 
 import (
 	"context"
-	"errors"
+	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/rank"
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel"
 )
 
 const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/storage/redis"
 
+// Every key of a workspace contains the workspace in a hash tag,
+// so that all of them end up in the same slot of a redis cluster.
+// Tasks are stored in hashes at "tasks:{<workspace>}:<id>",
+// and ids of all tasks of a workspace are in the set at "tasks:{<workspace>}:index".
+// Ids of tasks a user is assigned to or reported are in the sets at "tasks:{<workspace>}:<relation>:<user id>".
+// Ids of tasks with a label are in the sets at "tasks:{<workspace>}:label:<label>".
+// Ids of direct subtasks of a task are in the set at "tasks:{<workspace>}:<id>:subtasks",
+// counts of them and of the done ones are kept in the hash of the task.
+// The checklist of a task is kept in its hash as well, see checklist.go.
+// Tasks on the board of a project are in the sorted sets at "tasks:{<workspace>}:board:<project id>:<column id>"
+// as "<rank> <id>" with the same score, so that they are ordered by rank.
+// Ids of tasks with a due date that are not done yet are in the sorted set at "tasks:{<workspace>}:due",
+// scored by the due date in unix milliseconds.
+// Counts of tasks by status across all workspaces are kept in the hash at "tasks:stats",
+// and "<workspace>/<id>" of every task in the due sets is in the sorted set at "tasks:due".
+// Both are shared by all workspaces, so they are in another slot of a redis cluster
+// and are written after the transactions, see countTask.
+
+const (
+	tasksStatsKey = servicePrefix + ":stats"
+	tasksDueKey   = servicePrefix + ":due"
+)
+
 type TasksRepo struct {
-	rdb *redis.Client
+	rdb     *redis.Client
+	metrics *repoMetrics
+}
+
+var _ tasks.Repository = (*TasksRepo)(nil)
+
+func taskKey(workspace, id string) string {
+	return fmt.Sprintf("%s:{%s}:%s", servicePrefix, workspace, id)
+}
+
+func tasksIndexKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:index", servicePrefix, workspace)
+}
+
+func tasksByUserKey(workspace, relation, userID string) string {
+	return fmt.Sprintf("%s:{%s}:%s:%s", servicePrefix, workspace, relation, userID)
+}
+
+func tasksByLabelKey(workspace, label string) string {
+	return fmt.Sprintf("%s:{%s}:label:%s", servicePrefix, workspace, label)
+}
+
+func subtasksKey(workspace, id string) string {
+	return fmt.Sprintf("%s:{%s}:%s:subtasks", servicePrefix, workspace, id)
+}
+
+func boardColumnKey(workspace, projectID, columnID string) string {
+	return fmt.Sprintf("%s:{%s}:board:%s:%s", servicePrefix, workspace, projectID, columnID)
+}
+
+// Ranks never contain spaces and spaces sort before any character of a rank,
+// so members sort by rank first.
+func boardMember(rank, id string) string {
+	return rank + " " + id
 }
 
-func (r TasksRepo) Create(ctx context.Context, task tasks.Task) (tasks.Task, error) {
+func tasksDueByWorkspaceKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:due", servicePrefix, workspace)
+}
+
+func checkWorkspace(workspace string) error {
+	if !auth.ValidWorkspaceID(workspace) {
+		return fmt.Errorf("%w: %q", auth.ErrInvalidWorkspace, workspace)
+	}
+	return nil
+}
+
+func parseTask(workspace, id string, res map[string]string) tasks.Task {
+	status := res["status"]
+	if status == "" {
+		status = tasks.StatusTodo
+	}
+	priority := res["priority"]
+	if priority == "" {
+		priority = tasks.PriorityDefault
+	}
+
+	task := tasks.Task{
+		ID:          id,
+		WorkspaceID: workspace,
+		Title:       res["title"],
+		Description: res["description"],
+		Status:      status,
+		Priority:    priority,
+		CreatedBy:   res["created_by"],
+		Assignee:    res["assignee"],
+		Reporter:    res["reporter"],
+		Labels:      []string{},
+		ParentID:    res["parent_id"],
+		ProjectID:   res["project_id"],
+		ColumnID:    res["column_id"],
+		Rank:        res["rank"],
+		TemplateID:  res["template_id"],
+	}
+	if res["labels"] != "" {
+		task.Labels = strings.Split(res["labels"], ",")
+	}
+	task.CommentCount, _ = strconv.Atoi(res["comment_count"])
+	task.AttachmentCount, _ = strconv.Atoi(res["attachment_count"])
+	task.Checklist = checklistField(res["checklist"])
+	if total, _ := strconv.Atoi(res["subtasks_total"]); total > 0 {
+		done, _ := strconv.Atoi(res["subtasks_done"])
+		task.Subtasks = &tasks.Progress{Done: done, Total: total}
+	}
+	if due, err := time.Parse(time.RFC3339Nano, res["due_at"]); err == nil {
+		task.DueAt = &due
+	}
+
+	return task
+}
+
+func (r TasksRepo) Create(ctx context.Context, workspace string, task tasks.Task, limit int) (tasks.Task, error) {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.Create")
 	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "Create", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return tasks.Task{}, err
+	}
 
 	task.ID = uuid.New().String()
-	key := fmt.Sprintf("%s:%s", servicePrefix, task.ID)
-	return task, r.rdb.HSet(ctx, key, "title", task.Title, "description", task.Description).Err()
+	task.WorkspaceID = workspace
+
+	// The quota, labels, the parent and the column are checked in the same transaction, so that tasks created
+	// meanwhile can't exceed the quota and none of the rest is deleted meanwhile,
+	// and so is the rank, so that no other task is given it meanwhile.
+	err := watch(ctx, r.rdb, func(tx *redis.Tx) error {
+		if limit > 0 {
+			if err := tx.Watch(ctx, tasksIndexKey(workspace)).Err(); err != nil {
+				return err
+			}
+			n, err := tx.SCard(ctx, tasksIndexKey(workspace)).Result()
+			if err != nil {
+				return err
+			}
+			if n >= int64(limit) {
+				return fmt.Errorf("%w: %s", tasks.ErrQuotaExceeded, workspace)
+			}
+		}
+		if err := checkLabels(ctx, tx, workspace, nil, task.Labels); err != nil {
+			return err
+		}
+		if err := checkParent(ctx, tx, workspace, task.ID, task.ParentID); err != nil {
+			return err
+		}
+		if task.ProjectID != "" {
+			if err := checkColumn(ctx, tx, workspace, task.ProjectID, task.ColumnID); err != nil {
+				return err
+			}
+			var err error
+			if task.Rank, err = freeRank(ctx, tx, workspace, task); err != nil {
+				return err
+			}
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, taskKey(workspace, task.ID),
+				"title", task.Title, "description", task.Description, "status", task.Status, "created_by", task.CreatedBy,
+				"assignee", task.Assignee, "reporter", task.Reporter, "labels", strings.Join(task.Labels, ","),
+				"priority", task.Priority, "due_at", dueField(task.DueAt), "parent_id", task.ParentID,
+				"project_id", task.ProjectID, "column_id", task.ColumnID, "rank", task.Rank,
+				"template_id", task.TemplateID,
+			)
+			indexDue(ctx, pipe, workspace, task)
+			moveParent(ctx, pipe, workspace, task.ID, "", "", task.ParentID, task.Status)
+			moveBoardIndex(ctx, pipe, workspace, task.ID, boardPlace{}, placeOf(task))
+			pipe.SAdd(ctx, tasksIndexKey(workspace), task.ID)
+			moveLabelIndex(ctx, pipe, workspace, task.ID, nil, task.Labels)
+			if task.Assignee != "" {
+				pipe.SAdd(ctx, tasksByUserKey(workspace, tasks.RelationAssignee, task.Assignee), task.ID)
+			}
+			if task.Reporter != "" {
+				pipe.SAdd(ctx, tasksByUserKey(workspace, tasks.RelationReporter, task.Reporter), task.ID)
+			}
+			return nil
+		})
+		return err
+	}, labelsKey(workspace))
+	if err != nil {
+		return tasks.Task{}, err
+	}
+	if err := countTask(ctx, r.rdb, workspace, task.ID, "", task.Status, task.DueAt); err != nil {
+		return tasks.Task{}, err
+	}
+	return task, nil
 }
 
-func (r TasksRepo) Read(ctx context.Context, id string) (tasks.Task, error) {
+func (r TasksRepo) Read(ctx context.Context, workspace, id string) (tasks.Task, error) {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.Read")
 	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "Read", time.Now())
 
-	key := fmt.Sprintf("%s:%s", servicePrefix, id)
-	res, err := r.rdb.HGetAll(ctx, key).Result()
+	if err := checkWorkspace(workspace); err != nil {
+		return tasks.Task{}, err
+	}
+
+	res, err := r.rdb.HGetAll(ctx, taskKey(workspace, id)).Result()
 	// return err not found
 	if err != nil {
 		if err == redis.Nil {
@@ -45,62 +233,620 @@ func (r TasksRepo) Read(ctx context.Context, id string) (tasks.Task, error) {
 		return tasks.Task{}, fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, id)
 	}
 
-	return tasks.Task{
-		ID:          id,
-		Title:       res["title"],
-		Description: res["description"],
-	}, nil
+	return parseTask(workspace, id, res), nil
 }
 
-func (r TasksRepo) ReadAll(ctx context.Context) ([]tasks.Task, error) {
+func (r TasksRepo) ReadAll(ctx context.Context, workspace string, filter tasks.Filter) ([]tasks.Task, error) {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadAll")
 	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadAll", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
 
-	keys, err := r.rdb.Keys(ctx, fmt.Sprintf("%s:*", servicePrefix)).Result()
+	var (
+		ids []string
+		err error
+	)
+	switch {
+	case len(filter.Labels) == 0:
+		ids, err = r.rdb.SMembers(ctx, tasksIndexKey(workspace)).Result()
+	case filter.MatchAll:
+		ids, err = r.rdb.SInter(ctx, labelKeys(workspace, filter.Labels)...).Result()
+	default:
+		ids, err = r.rdb.SUnion(ctx, labelKeys(workspace, filter.Labels)...).Result()
+	}
 	if err != nil {
 		return nil, err
 	}
+
+	return r.readMany(ctx, workspace, ids)
+}
+
+func labelKeys(workspace string, labels []string) []string {
+	keys := make([]string, len(labels))
+	for i, l := range labels {
+		keys[i] = tasksByLabelKey(workspace, l)
+	}
+	return keys
+}
+
+func (r TasksRepo) ReadAllByUser(ctx context.Context, workspace, relation, userID string) ([]tasks.Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadAllByUser")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadAllByUser", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	ids, err := r.rdb.SMembers(ctx, tasksByUserKey(workspace, relation, userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.readMany(ctx, workspace, ids)
+}
+
+func (r TasksRepo) ReadDueBefore(ctx context.Context, workspace string, t time.Time) ([]tasks.Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadDueBefore")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadDueBefore", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	ids, err := r.rdb.ZRangeByScore(ctx, tasksDueByWorkspaceKey(workspace), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: "(" + strconv.FormatInt(t.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.readMany(ctx, workspace, ids)
+}
+
+func (r TasksRepo) ReadSubtasks(ctx context.Context, workspace, id string) ([]tasks.Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadSubtasks")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadSubtasks", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	ids, err := r.rdb.SMembers(ctx, subtasksKey(workspace, id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.readMany(ctx, workspace, ids)
+}
+
+func (r TasksRepo) ReadColumn(ctx context.Context, workspace, projectID, columnID string) ([]tasks.Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadColumn")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadColumn", time.Now())
+
+	positions, err := r.ReadPositions(ctx, workspace, projectID, columnID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, len(positions))
+	for i, p := range positions {
+		ids[i] = p.TaskID
+	}
+	return r.readMany(ctx, workspace, ids)
+}
+
+func (r TasksRepo) ReadPositions(ctx context.Context, workspace, projectID, columnID string) ([]tasks.Position, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadPositions")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadPositions", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	members, err := r.rdb.ZRange(ctx, boardColumnKey(workspace, projectID, columnID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	positions := make([]tasks.Position, 0, len(members))
+	for _, m := range members {
+		if rank, id, ok := strings.Cut(m, " "); ok {
+			positions = append(positions, tasks.Position{TaskID: id, Rank: rank})
+		}
+	}
+	return positions, nil
+}
+
+func (r TasksRepo) ReadMany(ctx context.Context, workspace string, ids []string) ([]tasks.Task, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.ReadMany")
+	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "ReadMany", time.Now())
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	return r.readMany(ctx, workspace, ids)
+}
+
+func (r TasksRepo) readMany(ctx context.Context, workspace string, ids []string) ([]tasks.Task, error) {
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err := r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, taskKey(workspace, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	var result []tasks.Task
-	for _, key := range keys {
-		res, err := r.rdb.HGetAll(ctx, key).Result()
-		if err != nil {
-			return nil, err
+	for i, cmd := range cmds {
+		res := cmd.Val()
+		if len(res) == 0 {
+			continue
 		}
-		result = append(result, tasks.Task{
-			ID:          strings.ReplaceAll(key, fmt.Sprintf("%s:", servicePrefix), ""), // TODO: refactor
-			Title:       res["title"],
-			Description: res["description"],
-		})
+		result = append(result, parseTask(workspace, ids[i], res))
 	}
 	return result, nil
 }
 
-func (r TasksRepo) Update(ctx context.Context, task tasks.Task) (tasks.Task, error) {
+func (r TasksRepo) Update(ctx context.Context, workspace, id string, change func(task *tasks.Task) error) (tasks.Task, error) {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.Update")
 	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "Update", time.Now())
 
-	key := fmt.Sprintf("%s:%s", servicePrefix, task.ID)
-	err := r.rdb.HSet(ctx, key, "title", task.Title, "description", task.Description).Err()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
-			return tasks.Task{}, fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, task.ID)
+	if err := checkWorkspace(workspace); err != nil {
+		return tasks.Task{}, err
+	}
+
+	// The task is changed as it is in the transaction, so that changes made meanwhile are not overwritten,
+	// and indexes and counters are moved from what it was. Labels it is given, its parent, ancestors of the parent,
+	// the column it goes to and its rank are checked in the same transaction.
+	key := taskKey(workspace, id)
+	var (
+		task       tasks.Task
+		prevStatus string
+	)
+	err := watch(ctx, r.rdb, func(tx *redis.Tx) error {
+		res, err := tx.HGetAll(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(res) == 0 {
+			return fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, id)
+		}
+
+		task = parseTask(workspace, id, res)
+		// Tasks stored before statuses existed have none and are not counted.
+		prevStatus = res["status"]
+		prevAssignee, prevReporter := task.Assignee, task.Reporter
+		prevLabels, prevParent, prevPlace := append([]string(nil), task.Labels...), task.ParentID, placeOf(task)
+		if err := change(&task); err != nil {
+			return err
+		}
+		task.ID, task.WorkspaceID = id, workspace
+
+		if err := checkLabels(ctx, tx, workspace, prevLabels, task.Labels); err != nil {
+			return err
 		}
+		if task.ParentID != prevParent {
+			if err := checkParent(ctx, tx, workspace, task.ID, task.ParentID); err != nil {
+				return err
+			}
+		}
+		countedPrev, err := liveParent(ctx, tx, workspace, prevParent)
+		if err != nil {
+			return err
+		}
+		countedNext := task.ParentID
+		if countedNext == prevParent {
+			countedNext = countedPrev
+		}
+		if next := placeOf(task); next != prevPlace && next.project != "" {
+			if next.project != prevPlace.project || next.column != prevPlace.column {
+				if err := checkColumn(ctx, tx, workspace, next.project, next.column); err != nil {
+					return err
+				}
+			}
+			if task.Rank, err = freeRank(ctx, tx, workspace, task); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key,
+				"title", task.Title, "description", task.Description, "status", task.Status,
+				"assignee", task.Assignee, "reporter", task.Reporter, "labels", strings.Join(task.Labels, ","),
+				"priority", task.Priority, "due_at", dueField(task.DueAt), "parent_id", task.ParentID,
+				"project_id", task.ProjectID, "column_id", task.ColumnID, "rank", task.Rank,
+			)
+			indexDue(ctx, pipe, workspace, task)
+			moveParent(ctx, pipe, workspace, task.ID, countedPrev, prevStatus, countedNext, task.Status)
+			moveBoardIndex(ctx, pipe, workspace, task.ID, prevPlace, placeOf(task))
+			moveUserIndex(ctx, pipe, workspace, tasks.RelationAssignee, task.ID, prevAssignee, task.Assignee)
+			moveUserIndex(ctx, pipe, workspace, tasks.RelationReporter, task.ID, prevReporter, task.Reporter)
+			moveLabelIndex(ctx, pipe, workspace, task.ID, prevLabels, task.Labels)
+			return nil
+		})
+		return err
+	}, key, labelsKey(workspace))
+	if err != nil {
+		return tasks.Task{}, err
+	}
+	if err := countTask(ctx, r.rdb, workspace, id, prevStatus, task.Status, task.DueAt); err != nil {
 		return tasks.Task{}, err
 	}
 	return task, nil
 }
 
-func (r TasksRepo) Delete(ctx context.Context, id string) error {
+func (r TasksRepo) Delete(ctx context.Context, workspace, id string, orphan bool) error {
 	ctx, span := otel.Tracer(otelName).Start(ctx, "TasksRepo.Delete")
 	defer span.End()
+	defer r.metrics.observe(ctx, "tasks", "Delete", time.Now())
 
-	key := fmt.Sprintf("%s:%s", servicePrefix, id)
-	err := r.rdb.Del(ctx, key).Err()
-	if err != nil {
-		if errors.Is(err, redis.Nil) {
+	if err := checkWorkspace(workspace); err != nil {
+		return err
+	}
+
+	// Indexes and counters are cleaned up after what the task was, so it must not change in between,
+	// and a task deleted twice at once must be uncounted once. Subtasks are watched,
+	// so that one added meanwhile is either refused or orphaned too.
+	key := taskKey(workspace, id)
+	var status string
+	err := watch(ctx, r.rdb, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
 			return fmt.Errorf("%w: %s", tasks.ErrTaskNotFound, id)
 		}
+
+		previous, err := tx.HMGet(ctx, key,
+			"status", "assignee", "reporter", "labels", "parent_id", "project_id", "column_id", "rank",
+		).Result()
+		if err != nil {
+			return err
+		}
+		status = stringField(previous[0])
+		assignee, reporter := stringField(previous[1]), stringField(previous[2])
+		labels, parent := listField(previous[3]), stringField(previous[4])
+		place := boardPlace{project: stringField(previous[5]), column: stringField(previous[6]), rank: stringField(previous[7])}
+
+		// Comments and links go away with their task, so does metadata of attachments, their files are removed by the service.
+		commentIDs, err := tx.HKeys(ctx, commentsKey(workspace, id)).Result()
+		if err != nil {
+			return err
+		}
+		links, err := readLinks(ctx, tx, workspace, id)
+		if err != nil {
+			return err
+		}
+		if parent, err = liveParent(ctx, tx, workspace, parent); err != nil {
+			return err
+		}
+		subtasks, err := tx.SMembers(ctx, subtasksKey(workspace, id)).Result()
+		if err != nil {
+			return err
+		}
+		if len(subtasks) > 0 && !orphan {
+			return fmt.Errorf("%w: %s", tasks.ErrHasSubtasks, id)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.SRem(ctx, tasksIndexKey(workspace), id)
+			pipe.ZRem(ctx, tasksDueByWorkspaceKey(workspace), id)
+			moveUserIndex(ctx, pipe, workspace, tasks.RelationAssignee, id, assignee, "")
+			moveUserIndex(ctx, pipe, workspace, tasks.RelationReporter, id, reporter, "")
+			moveLabelIndex(ctx, pipe, workspace, id, labels, nil)
+			moveParent(ctx, pipe, workspace, id, parent, status, "", "")
+			moveBoardIndex(ctx, pipe, workspace, id, place, boardPlace{})
+			pipe.Del(ctx, subtasksKey(workspace, id))
+			// Subtasks leave the set when they are deleted or moved, so all of them are still there.
+			for _, sub := range subtasks {
+				pipe.HDel(ctx, taskKey(workspace, sub), "parent_id")
+			}
+			deleteTaskComments(ctx, pipe, workspace, id, commentIDs)
+			deleteTaskLinks(ctx, pipe, workspace, id, links)
+			pipe.Del(ctx, attachmentsKey(workspace, id))
+			return nil
+		})
+		return err
+	}, key, subtasksKey(workspace, id), commentsKey(workspace, id), linksOutKey(workspace, id), linksInKey(workspace, id))
+	if err != nil {
+		return err
+	}
+	return countTask(ctx, r.rdb, workspace, id, status, "", nil)
+}
+
+// moveUserIndex moves the task from the index of the previous user to the one of the next user.
+func moveUserIndex(ctx context.Context, pipe redis.Pipeliner, workspace, relation, id, previous, next string) {
+	if previous == next {
+		return
+	}
+	if previous != "" {
+		pipe.SRem(ctx, tasksByUserKey(workspace, relation, previous), id)
+	}
+	if next != "" {
+		pipe.SAdd(ctx, tasksByUserKey(workspace, relation, next), id)
+	}
+}
+
+// moveLabelIndex removes the task from indexes of labels it no longer has and adds it to the new ones.
+func moveLabelIndex(ctx context.Context, pipe redis.Pipeliner, workspace, id string, previous, next []string) {
+	keep := make(map[string]bool, len(next))
+	for _, l := range next {
+		keep[l] = true
+	}
+	for _, l := range previous {
+		if !keep[l] {
+			pipe.SRem(ctx, tasksByLabelKey(workspace, l), id)
+		}
+		delete(keep, l)
+	}
+	for l := range keep {
+		pipe.SAdd(ctx, tasksByLabelKey(workspace, l), id)
+	}
+}
+
+// checkLabels makes sure labels the task is given are still in the catalogue,
+// which has to be watched so that none of them is deleted before the task is written.
+func checkLabels(ctx context.Context, tx *redis.Tx, workspace string, previous, next []string) error {
+	had := make(map[string]bool, len(previous))
+	for _, l := range previous {
+		had[l] = true
+	}
+	added := make([]string, 0, len(next))
+	for _, l := range next {
+		if !had[l] {
+			added = append(added, l)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	found, err := tx.HMGet(ctx, labelsKey(workspace), added...).Result()
+	if err != nil {
+		return err
+	}
+	for i, v := range found {
+		if v == nil {
+			return fmt.Errorf("%w: %s", labels.ErrLabelNotFound, added[i])
+		}
+	}
+	return nil
+}
+
+// checkParent watches the parent and its ancestors and makes sure the parent exists and the task is not among them,
+// so that the parent can't be deleted and tasks moved at the same time can't make a cycle before the task is written.
+func checkParent(ctx context.Context, tx *redis.Tx, workspace, id, parent string) error {
+	seen := make(map[string]bool)
+	for current := parent; current != ""; {
+		if current == id || seen[current] {
+			return fmt.Errorf("%w: %s", tasks.ErrParentCycle, id)
+		}
+		if len(seen) == tasks.MaxParentDepth {
+			return fmt.Errorf("%w: %s", tasks.ErrParentTooDeep, id)
+		}
+		seen[current] = true
+
+		key := taskKey(workspace, current)
+		if err := tx.Watch(ctx, key).Err(); err != nil {
+			return err
+		}
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			if current == parent {
+				return fmt.Errorf("%w: %s", tasks.ErrParentNotFound, parent)
+			}
+			// The tree ends at an ancestor that is gone.
+			return nil
+		}
+		next, err := tx.HGet(ctx, key, "parent_id").Result()
+		if err != nil && err != redis.Nil {
+			return err
+		}
+		current = next
+	}
+	return nil
+}
+
+// liveParent watches the parent and returns it if it still exists or "" if it is gone,
+// since counting progress in the hash of a deleted parent would bring it back.
+func liveParent(ctx context.Context, tx *redis.Tx, workspace, parent string) (string, error) {
+	if parent == "" {
+		return "", nil
+	}
+	key := taskKey(workspace, parent)
+	if err := tx.Watch(ctx, key).Err(); err != nil {
+		return "", err
+	}
+	n, err := tx.Exists(ctx, key).Result()
+	if err != nil || n == 0 {
+		return "", err
+	}
+	return parent, nil
+}
+
+// moveParent moves the task between sets of subtasks of its previous and next parents
+// and keeps their progress in line with the status of the task.
+func moveParent(ctx context.Context, pipe redis.Pipeliner, workspace, id, prevParent, prevStatus, parent, status string) {
+	wasDone, isDone := prevStatus == tasks.StatusDone, status == tasks.StatusDone
+	if prevParent == parent {
+		if parent != "" && wasDone != isDone {
+			done := int64(1)
+			if wasDone {
+				done = -1
+			}
+			pipe.HIncrBy(ctx, taskKey(workspace, parent), "subtasks_done", done)
+		}
+		return
+	}
+
+	if prevParent != "" {
+		pipe.SRem(ctx, subtasksKey(workspace, prevParent), id)
+		pipe.HIncrBy(ctx, taskKey(workspace, prevParent), "subtasks_total", -1)
+		if wasDone {
+			pipe.HIncrBy(ctx, taskKey(workspace, prevParent), "subtasks_done", -1)
+		}
+	}
+	if parent != "" {
+		pipe.SAdd(ctx, subtasksKey(workspace, parent), id)
+		pipe.HIncrBy(ctx, taskKey(workspace, parent), "subtasks_total", 1)
+		if isDone {
+			pipe.HIncrBy(ctx, taskKey(workspace, parent), "subtasks_done", 1)
+		}
+	}
+}
+
+// checkColumn watches projects of the workspace and makes sure the project still has the column,
+// so that a task is never put to a board of a project that has been deleted or changed meanwhile.
+func checkColumn(ctx context.Context, tx *redis.Tx, workspace, projectID, columnID string) error {
+	if err := tx.Watch(ctx, projectsKey(workspace)).Err(); err != nil {
 		return err
 	}
+	raw, err := tx.HGet(ctx, projectsKey(workspace), projectID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return fmt.Errorf("%w: %s", projects.ErrProjectNotFound, projectID)
+		}
+		return err
+	}
+
+	var p projects.Project
+	if err := json.Unmarshal([]byte(raw), &p); err != nil {
+		return err
+	}
+	if _, ok := p.Column(columnID); !ok {
+		return fmt.Errorf("%w: %s", projects.ErrColumnNotFound, columnID)
+	}
 	return nil
 }
+
+// freeRank watches the column the task goes to and returns its rank, unless another task there has it already.
+// Ranks are computed from positions read before the transaction, so a task placed at the same time may have got
+// the same one, in which case the task goes right after it.
+func freeRank(ctx context.Context, tx *redis.Tx, workspace string, task tasks.Task) (string, error) {
+	key := boardColumnKey(workspace, task.ProjectID, task.ColumnID)
+	if err := tx.Watch(ctx, key).Err(); err != nil {
+		return "", err
+	}
+	members, err := tx.ZRangeByLex(ctx, key, &redis.ZRangeBy{Min: "[" + boardMember(task.Rank, ""), Max: "+"}).Result()
+	if err != nil {
+		return "", err
+	}
+
+	taken, next := false, ""
+	for _, m := range members {
+		r, id, _ := strings.Cut(m, " ")
+		if id == task.ID {
+			continue
+		}
+		if r != task.Rank {
+			next = r
+			break
+		}
+		taken = true
+	}
+	if !taken {
+		return task.Rank, nil
+	}
+	return rank.Between(task.Rank, next)
+}
+
+// boardPlace is where a task is on the board of its project, the zero value is off any board.
+type boardPlace struct {
+	project, column, rank string
+}
+
+func placeOf(task tasks.Task) boardPlace {
+	if task.ProjectID == "" {
+		return boardPlace{}
+	}
+	return boardPlace{project: task.ProjectID, column: task.ColumnID, rank: task.Rank}
+}
+
+// moveBoardIndex moves the task between columns of boards or within a column.
+func moveBoardIndex(ctx context.Context, pipe redis.Pipeliner, workspace, id string, previous, next boardPlace) {
+	if previous == next {
+		return
+	}
+	if previous.project != "" {
+		pipe.ZRem(ctx, boardColumnKey(workspace, previous.project, previous.column), boardMember(previous.rank, id))
+	}
+	if next.project != "" {
+		pipe.ZAdd(ctx, boardColumnKey(workspace, next.project, next.column), redis.Z{Member: boardMember(next.rank, id)})
+	}
+}
+
+// indexDue keeps the task in the due set of the workspace while it has a due date and is not done.
+func indexDue(ctx context.Context, pipe redis.Pipeliner, workspace string, task tasks.Task) {
+	if task.DueAt == nil || task.Status == tasks.StatusDone {
+		pipe.ZRem(ctx, tasksDueByWorkspaceKey(workspace), task.ID)
+		return
+	}
+	pipe.ZAdd(ctx, tasksDueByWorkspaceKey(workspace), redis.Z{Score: float64(task.DueAt.UnixMilli()), Member: task.ID})
+}
+
+// countTask keeps the counts by status and the due set shared by all workspaces in line with a task
+// which status changed from prevStatus to status, an empty status means the task is deleted.
+// They only feed metrics, so they are written after the transaction and may drift if it fails in between.
+func countTask(ctx context.Context, rdb redis.Cmdable, workspace, id, prevStatus, status string, due *time.Time) error {
+	member := workspace + "/" + id
+	_, err := rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		if prevStatus != status {
+			// Tasks stored before statuses existed are not counted yet.
+			if prevStatus != "" {
+				pipe.HIncrBy(ctx, tasksStatsKey, prevStatus, -1)
+			}
+			if status != "" {
+				pipe.HIncrBy(ctx, tasksStatsKey, status, 1)
+			}
+		}
+		if status == "" || due == nil || status == tasks.StatusDone {
+			pipe.ZRem(ctx, tasksDueKey, member)
+		} else {
+			pipe.ZAdd(ctx, tasksDueKey, redis.Z{Score: float64(due.UnixMilli()), Member: member})
+		}
+		return nil
+	})
+	return err
+}
+
+func dueField(due *time.Time) string {
+	if due == nil {
+		return ""
+	}
+	return due.Format(time.RFC3339Nano)
+}
+
+// listField converts a comma separated value returned by HMGET.
+func listField(v any) []string {
+	s := stringField(v)
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+// stringField converts a value returned by HMGET, missing fields are nil.
+func stringField(v any) string {
+	s, _ := v.(string)
+	return s
+}
@@ -0,0 +1,222 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+)
+
+// Users of a workspace are stored in hashes at "users:{<workspace>}:<id>",
+// ids of all of them are in the set at "users:{<workspace>}:index".
+// "users:{<workspace>}:email:<email>" holds the id of the user with that email, which keeps emails unique in the workspace.
+const usersPrefix = "users"
+
+type UsersRepo struct {
+	rdb *redis.Client
+}
+
+var _ users.Repository = (*UsersRepo)(nil)
+
+func userKey(workspace, id string) string {
+	return fmt.Sprintf("%s:{%s}:%s", usersPrefix, workspace, id)
+}
+
+func usersIndexKey(workspace string) string {
+	return fmt.Sprintf("%s:{%s}:index", usersPrefix, workspace)
+}
+
+func userEmailKey(workspace, email string) string {
+	return fmt.Sprintf("%s:{%s}:email:%s", usersPrefix, workspace, email)
+}
+
+func parseUser(workspace, id string, res map[string]string) (users.User, error) {
+	u := users.User{
+		ID:          id,
+		WorkspaceID: workspace,
+		DisplayName: res["display_name"],
+		Email:       res["email"],
+		Active:      res["active"] == "1",
+	}
+
+	var err error
+	if u.CreatedAt, err = time.Parse(time.RFC3339Nano, res["created_at"]); err != nil {
+		return users.User{}, err
+	}
+	return u, nil
+}
+
+func userFields(u users.User) []any {
+	return []any{
+		"display_name", u.DisplayName,
+		"email", u.Email,
+		"active", boolField(u.Active),
+		"created_at", u.CreatedAt.Format(time.RFC3339Nano),
+	}
+}
+
+func boolField(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// claimEmail reserves email for the user with id, it fails if another user has it.
+func (r UsersRepo) claimEmail(ctx context.Context, workspace, email, id string) error {
+	ok, err := r.rdb.SetNX(ctx, userEmailKey(workspace, email), id, 0).Result()
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+
+	owner, err := r.rdb.Get(ctx, userEmailKey(workspace, email)).Result()
+	if err != nil {
+		return err
+	}
+	if owner != id {
+		return fmt.Errorf("%w: %s", users.ErrEmailTaken, email)
+	}
+	return nil
+}
+
+func (r UsersRepo) Create(ctx context.Context, workspace string, user users.User) (users.User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "UsersRepo.Create")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return users.User{}, err
+	}
+
+	user.ID = uuid.New().String()
+	user.WorkspaceID = workspace
+	if err := r.claimEmail(ctx, workspace, user.Email, user.ID); err != nil {
+		return users.User{}, err
+	}
+
+	_, err := r.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, userKey(workspace, user.ID), userFields(user)...)
+		pipe.SAdd(ctx, usersIndexKey(workspace), user.ID)
+		return nil
+	})
+	if err != nil {
+		r.rdb.Del(ctx, userEmailKey(workspace, user.Email))
+		return users.User{}, err
+	}
+	return user, nil
+}
+
+func (r UsersRepo) Read(ctx context.Context, workspace, id string) (users.User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "UsersRepo.Read")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return users.User{}, err
+	}
+
+	res, err := r.rdb.HGetAll(ctx, userKey(workspace, id)).Result()
+	if err != nil {
+		return users.User{}, err
+	}
+	if len(res) == 0 {
+		return users.User{}, fmt.Errorf("%w: %s", users.ErrUserNotFound, id)
+	}
+
+	return parseUser(workspace, id, res)
+}
+
+func (r UsersRepo) ReadAll(ctx context.Context, workspace string) ([]users.User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "UsersRepo.ReadAll")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return nil, err
+	}
+
+	ids, err := r.rdb.SMembers(ctx, usersIndexKey(workspace)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.MapStringStringCmd, len(ids))
+	_, err = r.rdb.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(ctx, userKey(workspace, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]users.User, 0, len(ids))
+	for i, cmd := range cmds {
+		res := cmd.Val()
+		if len(res) == 0 {
+			continue
+		}
+		u, err := parseUser(workspace, ids[i], res)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	return result, nil
+}
+
+func (r UsersRepo) Update(ctx context.Context, workspace string, user users.User) (users.User, error) {
+	ctx, span := otel.Tracer(otelName).Start(ctx, "UsersRepo.Update")
+	defer span.End()
+
+	if err := checkWorkspace(workspace); err != nil {
+		return users.User{}, err
+	}
+
+	// The previous email is released and the new one taken in the same transaction,
+	// so that neither is left claimed by a user that doesn't have it if anything fails.
+	key := userKey(workspace, user.ID)
+	err := watch(ctx, r.rdb, func(tx *redis.Tx) error {
+		previous, err := tx.HGet(ctx, key, "email").Result()
+		if err != nil {
+			if err == redis.Nil {
+				return fmt.Errorf("%w: %s", users.ErrUserNotFound, user.ID)
+			}
+			return err
+		}
+
+		emailKey := userEmailKey(workspace, user.Email)
+		if previous != user.Email {
+			if err := tx.Watch(ctx, emailKey, userEmailKey(workspace, previous)).Err(); err != nil {
+				return err
+			}
+			owner, err := tx.Get(ctx, emailKey).Result()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			if owner != "" && owner != user.ID {
+				return fmt.Errorf("%w: %s", users.ErrEmailTaken, user.Email)
+			}
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, userFields(user)...)
+			if previous != user.Email {
+				pipe.Set(ctx, emailKey, user.ID, 0)
+				pipe.Del(ctx, userEmailKey(workspace, previous))
+			}
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return users.User{}, err
+	}
+	user.WorkspaceID = workspace
+	return user, nil
+}
@@ -0,0 +1,120 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+)
+
+type (
+	RequestAPIKeyIssue struct {
+		Name   string   `json:"name" validate:"required,max=100"`
+		Scopes []string `json:"scopes" validate:"required,min=1,dive,oneof=tasks:read tasks:write"`
+	}
+
+	RequestAPIKeyByID struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+
+	// ResponseAPIKeyIssued is the only response that contains the plaintext key.
+	ResponseAPIKeyIssued struct {
+		auth.APIKey
+		Key string `json:"key"`
+	}
+
+	apiKeysHandler struct {
+		authService auth.Service
+	}
+)
+
+func NewAPIKeysHandler(authService auth.Service) apiKeysHandler {
+	return apiKeysHandler{
+		authService: authService,
+	}
+}
+
+// RegisterV1 mounts the admin api for api keys on the group.
+func (h apiKeysHandler) RegisterV1(g *echo.Group) {
+	g.Use(requireRole("admin"))
+
+	g.POST("", h.Issue)
+	g.GET("", h.ReadAll)
+	g.DELETE("/:id", h.Revoke)
+	g.POST("/:id/rotate", h.Rotate)
+}
+
+func respondAPIKeyErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrAPIKeyNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, auth.ErrAPIKeyRevoked):
+		code = http.StatusConflict
+	case errors.Is(err, auth.ErrInvalidScope), errors.Is(err, auth.ErrWorkspaceRequired):
+		code = http.StatusBadRequest
+	}
+	return ctx.JSON(code, echo.Map{"error": err.Error()})
+}
+
+func (h apiKeysHandler) Issue(ctx echo.Context) error {
+	req := new(RequestAPIKeyIssue)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	key, raw, err := h.authService.IssueAPIKey(ctx.Request().Context(), req.Name, req.Scopes)
+	if err != nil {
+		return respondAPIKeyErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, ResponseAPIKeyIssued{APIKey: key, Key: raw})
+}
+
+func (h apiKeysHandler) ReadAll(ctx echo.Context) error {
+	keys, err := h.authService.ReadAllAPIKeys(ctx.Request().Context())
+	if err != nil {
+		return respondAPIKeyErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, keys)
+}
+
+func (h apiKeysHandler) Revoke(ctx echo.Context) error {
+	req := new(RequestAPIKeyByID)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := h.authService.RevokeAPIKey(ctx.Request().Context(), req.ID); err != nil {
+		return respondAPIKeyErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+func (h apiKeysHandler) Rotate(ctx echo.Context) error {
+	req := new(RequestAPIKeyByID)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	key, raw, err := h.authService.RotateAPIKey(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondAPIKeyErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, ResponseAPIKeyIssued{APIKey: key, Key: raw})
+}
@@ -0,0 +1,177 @@
+package httprest
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/attachments"
+)
+
+const (
+	// headerChecksum carries the hex encoded sha256 of an attachment, in both directions.
+	headerChecksum = "X-Checksum-SHA256"
+	// formFieldFile is the multipart field with the content of an upload, other fields are ignored.
+	formFieldFile = "file"
+)
+
+type (
+	RequestAttachmentUpload struct {
+		TaskID string `param:"id" validate:"required,uuid"`
+		SHA256 string `validate:"omitempty,len=64,hexadecimal"` // from headerChecksum
+	}
+
+	RequestAttachmentReadAll struct {
+		TaskID string `param:"id" validate:"required,uuid"`
+	}
+
+	RequestAttachment struct {
+		TaskID string `param:"id" validate:"required,uuid"`
+		ID     string `param:"attachmentId" validate:"required,uuid"`
+	}
+
+	attachmentsHandler struct {
+		attachmentsService attachments.Service
+	}
+)
+
+func NewAttachmentsHandler(attachmentsService attachments.Service) attachmentsHandler {
+	return attachmentsHandler{
+		attachmentsService: attachmentsService,
+	}
+}
+
+// RegisterV1 mounts attachments as a subresource on the tasks group.
+func (h attachmentsHandler) RegisterV1(g *echo.Group) {
+	g.POST("/:id/attachments", h.Upload)
+	g.GET("/:id/attachments", h.ReadAll)
+	g.GET("/:id/attachments/:attachmentId", h.Download)
+	g.DELETE("/:id/attachments/:attachmentId", h.Delete)
+}
+
+func respondAttachmentErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, attachments.ErrAttachmentNotFound):
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, attachments.ErrTooLarge):
+		return ctx.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": err.Error()})
+	case errors.Is(err, attachments.ErrTypeNotAllowed):
+		return ctx.JSON(http.StatusUnsupportedMediaType, echo.Map{"error": err.Error()})
+	case errors.Is(err, attachments.ErrChecksumMismatch):
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+	return respondErr(ctx, code, err)
+}
+
+// Upload streams the "file" field of a multipart form to the blob store, the form is never buffered.
+func (h attachmentsHandler) Upload(ctx echo.Context) error {
+	req := new(RequestAttachmentUpload)
+	// Only the path is bound, the body is a multipart stream.
+	if err := (&echo.DefaultBinder{}).BindPathParams(ctx, req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+	req.SHA256 = ctx.Request().Header.Get(headerChecksum)
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	form, err := ctx.Request().MultipartReader()
+	if err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return ctx.JSON(http.StatusBadRequest, echo.Map{"error": "multipart form has no " + formFieldFile + " field"})
+		}
+		if err != nil {
+			return respondErr(ctx, http.StatusBadRequest, err)
+		}
+		if part.FormName() != formFieldFile {
+			part.Close()
+			continue
+		}
+
+		attachment, err := h.attachmentsService.Upload(ctx.Request().Context(), req.TaskID, attachments.Upload{
+			Filename: part.FileName(),
+			Content:  part,
+			SHA256:   req.SHA256,
+		})
+		part.Close()
+		if err != nil {
+			return respondAttachmentErr(ctx, http.StatusInternalServerError, err)
+		}
+
+		ctx.Response().Header().Set(headerChecksum, attachment.SHA256)
+		return ctx.JSON(http.StatusCreated, attachment)
+	}
+}
+
+func (h attachmentsHandler) ReadAll(ctx echo.Context) error {
+	req := new(RequestAttachmentReadAll)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	res, err := h.attachmentsService.ReadAll(ctx.Request().Context(), req.TaskID)
+	if err != nil {
+		return respondAttachmentErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+// Download streams the content with its checksum, so that clients can verify what they got.
+func (h attachmentsHandler) Download(ctx echo.Context) error {
+	req := new(RequestAttachment)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	attachment, content, err := h.attachmentsService.Download(ctx.Request().Context(), req.TaskID, req.ID)
+	if err != nil {
+		return respondAttachmentErr(ctx, http.StatusInternalServerError, err)
+	}
+	defer content.Close()
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	header := ctx.Response().Header()
+	header.Set(echo.HeaderContentDisposition, disposition)
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(attachment.Size, 10))
+	header.Set(echo.HeaderXContentTypeOptions, "nosniff")
+	header.Set(headerChecksum, attachment.SHA256)
+	return ctx.Stream(http.StatusOK, attachment.ContentType, content)
+}
+
+func (h attachmentsHandler) Delete(ctx echo.Context) error {
+	req := new(RequestAttachment)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := h.attachmentsService.Delete(ctx.Request().Context(), req.TaskID, req.ID); err != nil {
+		return respondAttachmentErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
@@ -0,0 +1,254 @@
+package httprest
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/config"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+)
+
+const headerAPIKey = "X-API-Key"
+
+type (
+	// authenticator identifies callers either by an api key in the X-API-Key header,
+	// or by a JWT in the Authorization header.
+	authenticator struct {
+		disabled bool
+		apiKeys  auth.Service
+		secret   []byte
+		keys     map[string]*rsa.PublicKey // RS256 keys by kid
+		issuer   string
+		audience string
+		parser   *jwt.Parser
+	}
+
+	jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+)
+
+func newAuthenticator(cfg config.Config, apiKeys auth.Service) (*authenticator, error) {
+	a := &authenticator{
+		disabled: cfg.Auth.Disabled,
+		apiKeys:  apiKeys,
+		secret:   []byte(cfg.Auth.JWTSecret),
+		keys:     map[string]*rsa.PublicKey{},
+		issuer:   cfg.Auth.JWTIssuer,
+		audience: cfg.Auth.JWTAudience,
+	}
+	if a.disabled {
+		return a, nil
+	}
+
+	var methods []string
+	if len(a.secret) != 0 {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if cfg.Auth.JWKSFile != "" {
+		keys, err := loadJWKS(cfg.Auth.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		a.keys = keys
+		methods = append(methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	if len(methods) == 0 {
+		return nil, errors.New("auth: neither AUTH_JWT_SECRET nor AUTH_JWT_JWKS_FILE is set")
+	}
+	a.parser = &jwt.Parser{ValidMethods: methods}
+
+	return a, nil
+}
+
+func loadJWKS(filename string) (map[string]*rsa.PublicKey, error) {
+	raw, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("auth: could not read jwks: %w", err)
+	}
+
+	var set jwks
+	if err := json.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("auth: could not parse jwks: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q has invalid modulus: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %q has invalid exponent: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("auth: jwks does not contain any RSA signing keys")
+	}
+
+	return keys, nil
+}
+
+func (a *authenticator) keyFunc(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return a.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := a.keys[kid]; ok {
+			return key, nil
+		}
+		if kid == "" && len(a.keys) == 1 {
+			for _, key := range a.keys {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+func (a *authenticator) authenticate(raw string) (auth.Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(raw, claims, a.keyFunc); err != nil {
+		return auth.Principal{}, err
+	}
+
+	if a.issuer != "" && !claims.VerifyIssuer(a.issuer, true) {
+		return auth.Principal{}, errors.New("token has invalid issuer")
+	}
+	if a.audience != "" && !claims.VerifyAudience(a.audience, true) {
+		return auth.Principal{}, errors.New("token has invalid audience")
+	}
+
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return auth.Principal{}, errors.New("token has no subject")
+	}
+
+	p := auth.Principal{ID: sub}
+	if roles, ok := claims["roles"].([]any); ok {
+		for _, r := range roles {
+			if s, ok := r.(string); ok {
+				p.Roles = append(p.Roles, s)
+			}
+		}
+	}
+	if scope, ok := claims["scope"].(string); ok {
+		p.Scopes = strings.Fields(scope)
+	}
+	if workspace, ok := claims["workspace"].(string); ok && workspace != "" {
+		p.Workspaces = []string{workspace}
+	}
+	if workspaces, ok := claims["workspaces"].([]any); ok {
+		for _, w := range workspaces {
+			if s, ok := w.(string); ok {
+				p.Workspaces = append(p.Workspaces, s)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// public reports if the path is reachable without credentials,
+// only subpaths of /health and /metrics are, not every path that merely starts the same.
+func public(path string) bool {
+	return strings.HasPrefix(path, "/health/") || path == "/metrics"
+}
+
+// Middleware puts the principal of a valid api key or bearer token into the request context.
+// Health checks and metrics are reachable without credentials, so orchestrators can probe and scrape us.
+func (a *authenticator) Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if a.disabled || public(c.Request().URL.Path) {
+			return next(c)
+		}
+
+		var p auth.Principal
+		if key := c.Request().Header.Get(headerAPIKey); key != "" {
+			var err error
+			p, err = a.apiKeys.AuthenticateAPIKey(c.Request().Context(), key)
+			if err != nil {
+				if errors.Is(err, auth.ErrUnauthenticated) {
+					return unauthorized(c, "invalid_token", "invalid api key")
+				}
+				return respondErr(c, http.StatusInternalServerError, err)
+			}
+		} else {
+			header := c.Request().Header.Get(echo.HeaderAuthorization)
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				return unauthorized(c, "", "missing credentials")
+			}
+
+			var err error
+			p, err = a.authenticate(token)
+			if err != nil {
+				return unauthorized(c, "invalid_token", err.Error())
+			}
+		}
+
+		c.SetRequest(c.Request().WithContext(auth.WithPrincipal(c.Request().Context(), p)))
+		return next(c)
+	}
+}
+
+// requireRole rejects requests without a principal that has role.
+// It fails closed: with auth disabled there are no principals, so routes behind it can't be used at all.
+func requireRole(role string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			p, ok := auth.PrincipalFrom(c.Request().Context())
+			if !ok {
+				return unauthorized(c, "", "missing credentials")
+			}
+			if !p.HasRole(role) {
+				return c.JSON(http.StatusForbidden, echo.Map{"error": auth.ErrForbidden.Error()})
+			}
+			return next(c)
+		}
+	}
+}
+
+func unauthorized(c echo.Context, code, description string) error {
+	challenge := fmt.Sprintf("Bearer realm=%q", ServiceName)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", code, description)
+	}
+	c.Response().Header().Set(echo.HeaderWWWAuthenticate, challenge)
+
+	return c.JSON(http.StatusUnauthorized, echo.Map{"error": auth.ErrUnauthenticated.Error()})
+}
@@ -0,0 +1,139 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/comments"
+)
+
+type (
+	RequestCommentCreate struct {
+		TaskID   string `param:"id" validate:"required,uuid"`
+		ParentID string `json:"parentId" validate:"omitempty,uuid"`
+		Body     string `json:"body" validate:"required,max=5000"`
+	}
+
+	RequestCommentReadAll struct {
+		TaskID string `param:"id" validate:"required,uuid"`
+	}
+
+	RequestCommentUpdate struct {
+		TaskID string `param:"id" validate:"required,uuid"`
+		ID     string `param:"commentId" validate:"required,uuid"`
+		Body   string `json:"body" validate:"required,max=5000"`
+	}
+
+	RequestCommentDelete struct {
+		TaskID string `param:"id" validate:"required,uuid"`
+		ID     string `param:"commentId" validate:"required,uuid"`
+	}
+
+	commentsHandler struct {
+		commentsService comments.Service
+	}
+)
+
+func NewCommentsHandler(commentsService comments.Service) commentsHandler {
+	return commentsHandler{
+		commentsService: commentsService,
+	}
+}
+
+// RegisterV1 mounts comments as a subresource on the tasks group.
+func (h commentsHandler) RegisterV1(g *echo.Group) {
+	g.POST("/:id/comments", h.Create)
+	g.GET("/:id/comments", h.ReadAll)
+	g.PUT("/:id/comments/:commentId", h.Update)
+	g.DELETE("/:id/comments/:commentId", h.Delete)
+}
+
+func respondCommentErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, comments.ErrCommentNotFound):
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, comments.ErrNestedReply):
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+	return respondErr(ctx, code, err)
+}
+
+func (h commentsHandler) Create(ctx echo.Context) error {
+	req := new(RequestCommentCreate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	comment, err := h.commentsService.Create(ctx.Request().Context(), comments.Comment{
+		TaskID:   req.TaskID,
+		ParentID: req.ParentID,
+		Body:     req.Body,
+	})
+	if err != nil {
+		return respondCommentErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, comment)
+}
+
+func (h commentsHandler) ReadAll(ctx echo.Context) error {
+	req := new(RequestCommentReadAll)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	res, err := h.commentsService.ReadAll(ctx.Request().Context(), req.TaskID)
+	if err != nil {
+		return respondCommentErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h commentsHandler) Update(ctx echo.Context) error {
+	req := new(RequestCommentUpdate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	comment, err := h.commentsService.Update(ctx.Request().Context(), comments.Comment{
+		ID:     req.ID,
+		TaskID: req.TaskID,
+		Body:   req.Body,
+	})
+	if err != nil {
+		return respondCommentErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, comment)
+}
+
+func (h commentsHandler) Delete(ctx echo.Context) error {
+	req := new(RequestCommentDelete)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := h.commentsService.Delete(ctx.Request().Context(), req.TaskID, req.ID); err != nil {
+		return respondCommentErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
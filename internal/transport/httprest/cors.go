@@ -0,0 +1,21 @@
+package httprest
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+
+	"github.com/rasulov-emirlan/topenergy-interview/config"
+)
+
+// corsMiddleware answers preflight requests and adds CORS headers for the origins allowed in cfg,
+// which is expected to be validated by config.LoadConfig.
+func corsMiddleware(cfg config.Config) echo.MiddlewareFunc {
+	return middleware.CORSWithConfig(middleware.CORSConfig{
+		AllowOrigins:     cfg.Server.AllowedOrigins,
+		AllowMethods:     cfg.Server.CORS.AllowedMethods,
+		AllowHeaders:     cfg.Server.CORS.AllowedHeaders,
+		ExposeHeaders:    cfg.Server.CORS.ExposedHeaders,
+		AllowCredentials: cfg.Server.CORS.AllowCredentials,
+		MaxAge:           int(cfg.Server.CORS.MaxAge.Seconds()),
+	})
+}
@@ -0,0 +1,117 @@
+package httprest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/config"
+)
+
+func corsRouter(origins []string, credentials bool) *echo.Echo {
+	var cfg config.Config
+	cfg.Server.AllowedOrigins = origins
+	cfg.Server.CORS.AllowedMethods = []string{http.MethodGet, http.MethodPost}
+	cfg.Server.CORS.AllowedHeaders = []string{"Authorization", "Content-Type"}
+	cfg.Server.CORS.ExposedHeaders = []string{"X-Request-ID"}
+	cfg.Server.CORS.AllowCredentials = credentials
+	cfg.Server.CORS.MaxAge = 10 * time.Minute
+
+	router := echo.New()
+	router.Use(corsMiddleware(cfg))
+	router.GET("/v1/tasks", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	return router
+}
+
+func preflight(router *echo.Echo, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodOptions, "/v1/tasks", nil)
+	req.Header.Set(echo.HeaderOrigin, origin)
+	req.Header.Set(echo.HeaderAccessControlRequestMethod, http.MethodPost)
+	req.Header.Set(echo.HeaderAccessControlRequestHeaders, "Authorization")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestCORSPreflightAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+	}{
+		{"exact origin", []string{"https://app.example.com"}, "https://app.example.com"},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "https://admin.example.com"},
+		{"any origin", []string{"*"}, "https://anything.test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := preflight(corsRouter(tt.origins, false), tt.origin)
+
+			if rec.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusNoContent)
+			}
+			h := rec.Header()
+			if got := h.Get(echo.HeaderAccessControlAllowOrigin); got != tt.origin && got != "*" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.origin)
+			}
+			if got := h.Get(echo.HeaderAccessControlAllowMethods); got != "GET,POST" {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, "GET,POST")
+			}
+			if got := h.Get(echo.HeaderAccessControlAllowHeaders); got != "Authorization,Content-Type" {
+				t.Errorf("Access-Control-Allow-Headers = %q, want %q", got, "Authorization,Content-Type")
+			}
+			if got := h.Get(echo.HeaderAccessControlMaxAge); got != "600" {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, "600")
+			}
+		})
+	}
+}
+
+func TestCORSPreflightDisallowedOrigin(t *testing.T) {
+	tests := []struct {
+		name   string
+		origin string
+	}{
+		{"other host", "https://evil.test"},
+		{"other scheme", "http://app.example.com"},
+		{"suffix of allowed host", "https://app.example.com.evil.test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := preflight(corsRouter([]string{"https://app.example.com"}, true), tt.origin)
+
+			h := rec.Header()
+			if got := h.Get(echo.HeaderAccessControlAllowOrigin); got != "" {
+				t.Errorf("Access-Control-Allow-Origin = %q, want none", got)
+			}
+			if got := h.Get(echo.HeaderAccessControlAllowCredentials); got != "" {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want none", got)
+			}
+		})
+	}
+}
+
+func TestCORSCredentials(t *testing.T) {
+	router := corsRouter([]string{"https://app.example.com"}, true)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	req.Header.Set(echo.HeaderOrigin, "https://app.example.com")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	h := rec.Header()
+	if got := h.Get(echo.HeaderAccessControlAllowOrigin); got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want the origin itself", got)
+	}
+	if got := h.Get(echo.HeaderAccessControlAllowCredentials); got != "true" {
+		t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, "true")
+	}
+	if got := h.Get(echo.HeaderAccessControlExposeHeaders); got != "X-Request-ID" {
+		t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, "X-Request-ID")
+	}
+}
@@ -0,0 +1,155 @@
+package httprest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/idempotency"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+)
+
+const (
+	headerIdempotencyKey      = "Idempotency-Key"
+	headerIdempotentReplayed  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength   = 255
+	maxIdempotentRequestBytes = 1 << 20
+)
+
+var errRequestTooLarge = errors.New("request body is too large")
+
+type (
+	idempotencyGuard struct {
+		store idempotency.Store // nil if idempotency keys are not supported
+		ttl   time.Duration     // of completed responses
+		// lockTTL is how long a request may be in progress, it is much shorter than ttl,
+		// so that a request that never completes doesn't block its retries for long.
+		lockTTL time.Duration
+		log     *logging.Logger
+	}
+
+	// responseRecorder copies the response body, while still writing it to the client.
+	responseRecorder struct {
+		http.ResponseWriter
+		body bytes.Buffer
+	}
+)
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// middleware makes POST requests with an Idempotency-Key header safe to retry:
+// the first response is stored and replayed for every retry with the same key and body.
+// Keys are scoped to the resource, workspace and principal, so they can't collide between callers.
+func (g idempotencyGuard) middleware(resource string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			key := req.Header.Get(headerIdempotencyKey)
+			if g.store == nil || req.Method != http.MethodPost || key == "" {
+				return next(c)
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				return c.JSON(http.StatusBadRequest, echo.Map{"error": "idempotency key is too long"})
+			}
+
+			fingerprint, err := fingerprintRequest(req)
+			if errors.Is(err, errRequestTooLarge) {
+				return c.JSON(http.StatusRequestEntityTooLarge, echo.Map{"error": err.Error()})
+			}
+			if err != nil {
+				return respondErr(c, http.StatusBadRequest, err)
+			}
+
+			ctx := req.Context()
+			workspace, _ := auth.WorkspaceFrom(ctx)
+			principal, _ := auth.PrincipalFrom(ctx)
+			storeKey := hash(resource, workspace, principal.ID, key)
+
+			record, first, err := g.store.Begin(ctx, storeKey, fingerprint, g.lockTTL)
+			if err != nil {
+				g.log.ErrorContext(ctx, "idempotency", logging.String("stage", "begin"), logging.Error("err", err))
+				return respondErr(c, http.StatusInternalServerError, err)
+			}
+
+			if !first {
+				switch {
+				case record.Fingerprint != fingerprint:
+					return c.JSON(http.StatusUnprocessableEntity, echo.Map{"error": "idempotency key was already used with a different request"})
+				case record.InProgress():
+					c.Response().Header().Set("Retry-After", "1")
+					return c.JSON(http.StatusConflict, echo.Map{"error": idempotency.ErrInProgress.Error()})
+				}
+
+				c.Response().Header().Set(headerIdempotentReplayed, "true")
+				return c.Blob(record.Status, record.ContentType, record.Body)
+			}
+
+			rec := &responseRecorder{ResponseWriter: c.Response().Writer}
+			c.Response().Writer = rec
+			err = next(c)
+			c.Response().Writer = rec.ResponseWriter
+
+			// Server errors are not remembered, the client should be able to retry them.
+			status := c.Response().Status
+			if err != nil || status >= http.StatusInternalServerError || !c.Response().Committed {
+				if errr := g.store.Abort(ctx, storeKey); errr != nil {
+					g.log.ErrorContext(ctx, "idempotency", logging.String("stage", "abort"), logging.Error("err", errr))
+				}
+				return err
+			}
+
+			record.Status = status
+			record.ContentType = c.Response().Header().Get(echo.HeaderContentType)
+			record.Body = rec.body.Bytes()
+			if err := g.store.Complete(ctx, storeKey, record, g.ttl); err != nil {
+				g.log.ErrorContext(ctx, "idempotency", logging.String("stage", "complete"), logging.Error("err", err))
+			}
+
+			return nil
+		}
+	}
+}
+
+// fingerprintRequest identifies the request, so that a key reused for a different one can be told apart.
+// Multipart uploads are streamed by their handlers and can be much larger than maxIdempotentRequestBytes,
+// so they are not read here; their content is identified by the X-Checksum-SHA256 header instead,
+// which the upload is checked against. Other bodies are buffered, up to maxIdempotentRequestBytes.
+func fingerprintRequest(req *http.Request) (string, error) {
+	if strings.HasPrefix(req.Header.Get(echo.HeaderContentType), echo.MIMEMultipartForm) {
+		return hash(req.Method, req.URL.Path, echo.MIMEMultipartForm, req.Header.Get(headerChecksum)), nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxIdempotentRequestBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > maxIdempotentRequestBytes {
+		return "", errRequestTooLarge
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return hash(req.Method, req.URL.Path, string(body)), nil
+}
+
+func hash(parts ...string) string {
+	h := sha256.New()
+	for _, p := range parts {
+		// Length prefixes keep ("ab", "c") and ("a", "bc") apart.
+		h.Write([]byte(strconv.Itoa(len(p))))
+		h.Write([]byte{':'})
+		h.Write([]byte(p))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,140 @@
+package httprest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/idempotency"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+)
+
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]idempotency.Record
+}
+
+func (s *memoryIdempotencyStore) Begin(_ context.Context, key, fingerprint string, _ time.Duration) (idempotency.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if record, ok := s.records[key]; ok {
+		return record, false, nil
+	}
+	record := idempotency.Record{Fingerprint: fingerprint}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(_ context.Context, key string, record idempotency.Record, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records[key] = record
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Abort(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.records, key)
+	return nil
+}
+
+// idempotentRouter counts the bytes every request handler reads and responds with it.
+func idempotentRouter(t *testing.T, handled *int) *echo.Echo {
+	t.Helper()
+
+	log, err := logging.NewLogger("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := idempotencyGuard{
+		store:   &memoryIdempotencyStore{records: map[string]idempotency.Record{}},
+		ttl:     time.Hour,
+		lockTTL: time.Minute,
+		log:     log,
+	}
+
+	router := echo.New()
+	router.POST("/v1/tasks/:id/attachments", func(c echo.Context) error {
+		*handled++
+		n, err := io.Copy(io.Discard, c.Request().Body)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusCreated, echo.Map{"read": n})
+	}, guard.middleware("tasks"))
+	return router
+}
+
+func upload(t *testing.T, router *echo.Echo, content []byte, checksum string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile(formFieldFile, "big.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/tasks/1/attachments", &body)
+	req.Header.Set(echo.HeaderContentType, form.FormDataContentType())
+	req.Header.Set(headerIdempotencyKey, "upload-1")
+	if checksum != "" {
+		req.Header.Set(headerChecksum, checksum)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIdempotencyStreamsLargeUploads(t *testing.T) {
+	handled := 0
+	router := idempotentRouter(t, &handled)
+	content := bytes.Repeat([]byte{'x'}, 2*maxIdempotentRequestBytes)
+
+	first := upload(t, router, content, "aa")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("first upload: status = %d, want %d: %s", first.Code, http.StatusCreated, first.Body)
+	}
+
+	retry := upload(t, router, content, "aa")
+	if retry.Code != http.StatusCreated || retry.Header().Get(headerIdempotentReplayed) != "true" {
+		t.Fatalf("retry: status = %d, replayed = %q", retry.Code, retry.Header().Get(headerIdempotentReplayed))
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Fatalf("retry body = %s, want %s", retry.Body, first.Body)
+	}
+	if handled != 1 {
+		t.Fatalf("handler ran %d times, want 1", handled)
+	}
+
+	if other := upload(t, router, content, "bb"); other.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("upload with another checksum: status = %d, want %d", other.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestIdempotencyRejectsLargeBodies(t *testing.T) {
+	handled := 0
+	router := idempotentRouter(t, &handled)
+
+	body := bytes.Repeat([]byte{'x'}, maxIdempotentRequestBytes+1)
+	req := httptest.NewRequest(http.MethodPost, "/v1/tasks/1/attachments", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set(headerIdempotencyKey, "big-1")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge || handled != 0 {
+		t.Fatalf("status = %d, handled = %d, want %d and 0", rec.Code, handled, http.StatusRequestEntityTooLarge)
+	}
+}
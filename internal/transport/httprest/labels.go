@@ -0,0 +1,146 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+)
+
+type (
+	RequestLabelCreate struct {
+		Name        string `json:"name" validate:"required,max=32"`
+		Color       string `json:"color" validate:"required,hexcolor"`
+		Description string `json:"description" validate:"max=200"`
+	}
+
+	RequestLabelByName struct {
+		Name string `param:"name" validate:"required,max=32"`
+	}
+
+	RequestLabelUpdate struct {
+		Name        string `param:"name" validate:"required,max=32"`
+		Color       string `json:"color" validate:"omitempty,hexcolor"`
+		Description string `json:"description" validate:"max=200"`
+	}
+
+	labelsHandler struct {
+		labelsService labels.Service
+	}
+)
+
+func NewLabelsHandler(labelsService labels.Service) labelsHandler {
+	return labelsHandler{
+		labelsService: labelsService,
+	}
+}
+
+// RegisterV1 mounts the label catalogue of the workspace on the group.
+func (h labelsHandler) RegisterV1(g *echo.Group) {
+	g.POST("", h.Create)
+	g.GET("", h.ReadAll)
+	g.GET("/:name", h.Read)
+	g.PUT("/:name", h.Update)
+	g.DELETE("/:name", h.Delete)
+}
+
+func respondLabelErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, labels.ErrLabelNotFound):
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, labels.ErrLabelExists):
+		return ctx.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	case errors.Is(err, labels.ErrInvalidLabel):
+		return ctx.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	return respondErr(ctx, code, err)
+}
+
+func (h labelsHandler) Create(ctx echo.Context) error {
+	req := new(RequestLabelCreate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	label, err := h.labelsService.Create(ctx.Request().Context(), labels.Label{
+		Name:        req.Name,
+		Color:       req.Color,
+		Description: req.Description,
+	})
+	if err != nil {
+		return respondLabelErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, label)
+}
+
+func (h labelsHandler) ReadAll(ctx echo.Context) error {
+	res, err := h.labelsService.ReadAll(ctx.Request().Context())
+	if err != nil {
+		return respondLabelErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h labelsHandler) Read(ctx echo.Context) error {
+	req := new(RequestLabelByName)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	label, err := h.labelsService.Read(ctx.Request().Context(), req.Name)
+	if err != nil {
+		return respondLabelErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, label)
+}
+
+func (h labelsHandler) Update(ctx echo.Context) error {
+	req := new(RequestLabelUpdate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	label, err := h.labelsService.Update(ctx.Request().Context(), labels.Label{
+		Name:        req.Name,
+		Color:       req.Color,
+		Description: req.Description,
+	})
+	if err != nil {
+		return respondLabelErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, label)
+}
+
+func (h labelsHandler) Delete(ctx echo.Context) error {
+	req := new(RequestLabelByName)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := h.labelsService.Delete(ctx.Request().Context(), req.Name); err != nil {
+		return respondLabelErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
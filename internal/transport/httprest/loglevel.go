@@ -0,0 +1,27 @@
+package httprest
+
+import (
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+)
+
+type logLevelHandler struct {
+	log *logging.Logger
+}
+
+func NewLogLevelHandler(log *logging.Logger) logLevelHandler {
+	return logLevelHandler{
+		log: log,
+	}
+}
+
+// RegisterV1 mounts the admin api for the log level on the group.
+// GET returns {"level": "info"}, PUT with the same body changes it without a restart.
+func (h logLevelHandler) RegisterV1(g *echo.Group) {
+	g.Use(requireRole("admin"))
+
+	levels := echo.WrapHandler(h.log.LevelHandler())
+	g.GET("", levels)
+	g.PUT("", levels)
+}
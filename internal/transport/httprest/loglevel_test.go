@@ -0,0 +1,53 @@
+package httprest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+)
+
+func TestLogLevelRequiresAdmin(t *testing.T) {
+	log, err := logging.NewLogger("info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		want      int
+	}{
+		{"no principal", nil, http.StatusUnauthorized},
+		{"not an admin", &auth.Principal{ID: "user", Roles: []string{auth.RoleMember}}, http.StatusForbidden},
+		{"admin", &auth.Principal{ID: "admin", Roles: []string{auth.RoleAdmin}}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := echo.New()
+			if tt.principal != nil {
+				router.Use(func(next echo.HandlerFunc) echo.HandlerFunc {
+					return func(c echo.Context) error {
+						c.SetRequest(c.Request().WithContext(auth.WithPrincipal(c.Request().Context(), *tt.principal)))
+						return next(c)
+					}
+				})
+			}
+			NewLogLevelHandler(log).RegisterV1(router.Group("/v1/admin/loglevel"))
+
+			req := httptest.NewRequest(http.MethodPut, "/v1/admin/loglevel", strings.NewReader(`{"level":"info"}`))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// newMetricsMiddleware records rate, errors and duration of requests per route and status.
+// Routes are route templates (e.g. /v1/tasks/:id), so that ids don't blow up cardinality.
+func newMetricsMiddleware() (echo.MiddlewareFunc, error) {
+	duration, err := otel.Meter(otelName).Float64Histogram(
+		"http.server.duration",
+		metric.WithUnit("s"),
+		metric.WithDescription("Duration of HTTP requests"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			begin := time.Now()
+
+			err := next(c)
+
+			// Errors are turned into responses only later by the error handler,
+			// so their status has to be figured out here.
+			status := c.Response().Status
+			if err != nil {
+				status = http.StatusInternalServerError
+				var httpErr *echo.HTTPError
+				if errors.As(err, &httpErr) {
+					status = httpErr.Code
+				}
+			}
+
+			duration.Record(c.Request().Context(), time.Since(begin).Seconds(), metric.WithAttributes(
+				attribute.String("http.method", c.Request().Method),
+				attribute.String("http.route", c.Path()),
+				attribute.String("http.status_code", strconv.Itoa(status)),
+			))
+
+			return err
+		}
+	}, nil
+}
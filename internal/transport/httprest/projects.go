@@ -0,0 +1,189 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+)
+
+type (
+	RequestColumn struct {
+		ID     string `json:"id" validate:"omitempty,uuid"` // empty for new columns
+		Name   string `json:"name" validate:"required,max=50"`
+		Status string `json:"status" validate:"required,oneof=todo in_progress done"`
+	}
+
+	RequestProjectCreate struct {
+		Name        string          `json:"name" validate:"required,max=100"`
+		Description string          `json:"description" validate:"max=1000"`
+		Columns     []RequestColumn `json:"columns" validate:"max=20,dive"`
+	}
+
+	RequestProjectRead struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+
+	// RequestProjectUpdate replaces columns of the board if they are given, in the given order.
+	RequestProjectUpdate struct {
+		ID          string          `param:"id" validate:"required,uuid"`
+		Name        string          `json:"name" validate:"max=100"`
+		Description string          `json:"description" validate:"max=1000"`
+		Columns     []RequestColumn `json:"columns" validate:"omitempty,max=20,dive"`
+	}
+
+	projectsHandler struct {
+		projectsService projects.Service
+		tasksService    tasks.Service
+	}
+)
+
+func NewProjectsHandler(projectsService projects.Service, tasksService tasks.Service) projectsHandler {
+	return projectsHandler{
+		projectsService: projectsService,
+		tasksService:    tasksService,
+	}
+}
+
+// RegisterV1 mounts projects and their boards on the group.
+func (h projectsHandler) RegisterV1(g *echo.Group) {
+	g.POST("", h.Create)
+	g.GET("", h.ReadAll)
+	g.GET("/:id", h.Read)
+	g.PUT("/:id", h.Update)
+	g.DELETE("/:id", h.Delete)
+	g.GET("/:id/board", h.ReadBoard)
+}
+
+func respondProjectErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, projects.ErrProjectNotFound):
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, projects.ErrColumnNotFound), errors.Is(err, projects.ErrInvalidColumns):
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	case errors.Is(err, projects.ErrProjectNotEmpty), errors.Is(err, projects.ErrColumnNotEmpty):
+		return ctx.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	return respondErr(ctx, code, err)
+}
+
+func columnsOf(req []RequestColumn) []projects.Column {
+	if req == nil {
+		return nil
+	}
+	columns := make([]projects.Column, len(req))
+	for i, c := range req {
+		columns[i] = projects.Column{ID: c.ID, Name: c.Name, Status: c.Status}
+	}
+	return columns
+}
+
+func (h projectsHandler) Create(ctx echo.Context) error {
+	req := new(RequestProjectCreate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	project, err := h.projectsService.Create(ctx.Request().Context(), projects.Project{
+		Name:        req.Name,
+		Description: req.Description,
+		Columns:     columnsOf(req.Columns),
+	})
+	if err != nil {
+		return respondProjectErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, project)
+}
+
+func (h projectsHandler) ReadAll(ctx echo.Context) error {
+	res, err := h.projectsService.ReadAll(ctx.Request().Context())
+	if err != nil {
+		return respondProjectErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h projectsHandler) Read(ctx echo.Context) error {
+	req := new(RequestProjectRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	project, err := h.projectsService.Read(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondProjectErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, project)
+}
+
+func (h projectsHandler) Update(ctx echo.Context) error {
+	req := new(RequestProjectUpdate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	project, err := h.projectsService.Update(ctx.Request().Context(), projects.Project{
+		ID:          req.ID,
+		Name:        req.Name,
+		Description: req.Description,
+		Columns:     columnsOf(req.Columns),
+	})
+	if err != nil {
+		return respondProjectErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, project)
+}
+
+func (h projectsHandler) Delete(ctx echo.Context) error {
+	req := new(RequestProjectRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := h.projectsService.Delete(ctx.Request().Context(), req.ID); err != nil {
+		return respondProjectErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// ReadBoard returns the columns of the project with their tasks in order.
+func (h projectsHandler) ReadBoard(ctx echo.Context) error {
+	req := new(RequestProjectRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	board, err := h.tasksService.ReadBoard(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondProjectErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, board)
+}
@@ -0,0 +1,117 @@
+package httprest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/config"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/ratelimit"
+)
+
+const anyResource = "*"
+
+type rateLimiter struct {
+	limiter ratelimit.Limiter // nil if rate limiting is off
+	rules   map[string]config.RateLimitRule
+	perIP   *config.RateLimitRule // nil if there is no limit per client IP
+	log     *logging.Logger
+}
+
+// ipExtractor takes the client IP from the connection, so that clients can't pick their own bucket
+// by sending X-Forwarded-For. The header is only trusted when the connection comes from a trusted proxy.
+func ipExtractor(cfg config.Config) echo.IPExtractor {
+	if len(cfg.Server.TrustedProxies) == 0 {
+		return echo.ExtractIPDirect()
+	}
+
+	// Loopback, link-local and private addresses are trusted by default, only the configured ones should be.
+	opts := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, network := range cfg.Server.TrustedProxies {
+		opts = append(opts, echo.TrustIPRange(network))
+	}
+	return echo.ExtractIPFromXFFHeader(opts...)
+}
+
+// perClient limits requests of every client IP across all resources. It runs before authentication,
+// so that floods of requests with bad credentials never reach it. Health checks and metrics are not limited.
+func (rl rateLimiter) perClient(next echo.HandlerFunc) echo.HandlerFunc {
+	if rl.limiter == nil || rl.perIP == nil {
+		return next
+	}
+
+	limit := ratelimit.Limit{Rate: rl.perIP.Rate, Burst: rl.perIP.Burst}
+
+	return func(c echo.Context) error {
+		if public(c.Request().URL.Path) {
+			return next(c)
+		}
+		if !rl.allow(c, "client", "ip:"+c.RealIP(), limit) {
+			return c.JSON(http.StatusTooManyRequests, echo.Map{"error": "too many requests"})
+		}
+		return next(c)
+	}
+}
+
+// middleware limits requests to the resource, buckets are per principal,
+// or per client IP for anonymous requests.
+func (rl rateLimiter) middleware(resource string) echo.MiddlewareFunc {
+	rule, ok := rl.rules[resource]
+	if !ok {
+		rule, ok = rl.rules[anyResource]
+	}
+	if rl.limiter == nil || !ok {
+		return func(next echo.HandlerFunc) echo.HandlerFunc { return next }
+	}
+
+	limit := ratelimit.Limit{Rate: rule.Rate, Burst: rule.Burst}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+
+			key := "ip:" + c.RealIP()
+			if p, ok := auth.PrincipalFrom(ctx); ok {
+				key = "principal:" + p.ID
+			}
+
+			if !rl.allow(c, resource, key, limit) {
+				return c.JSON(http.StatusTooManyRequests, echo.Map{"error": "too many requests"})
+			}
+
+			return next(c)
+		}
+	}
+}
+
+// allow takes a token from the bucket of key and sets the rate limit headers.
+// It lets the request through if the limiter fails.
+func (rl rateLimiter) allow(c echo.Context, resource, key string, limit ratelimit.Limit) bool {
+	ctx := c.Request().Context()
+
+	res, err := rl.limiter.Allow(ctx, resource+":"+key, limit)
+	if err != nil {
+		// Failing closed would make the limiter backend a single point of failure.
+		rl.log.ErrorContext(ctx, "rate limit", logging.String("resource", resource), logging.Error("err", err))
+		return true
+	}
+
+	h := c.Response().Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+	if !res.Allowed {
+		h.Set("Retry-After", ceilSeconds(res.RetryAfter))
+	}
+	return res.Allowed
+}
+
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,90 @@
+package httprest
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/rasulov-emirlan/topenergy-interview/config"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/ratelimit"
+)
+
+func perIPRouter(t *testing.T, trustedProxies ...string) *echo.Echo {
+	t.Helper()
+
+	var cfg config.Config
+	for _, raw := range trustedProxies {
+		_, network, err := net.ParseCIDR(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Server.TrustedProxies = append(cfg.Server.TrustedProxies, network)
+	}
+
+	log, err := logging.NewLogger("error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limits := rateLimiter{
+		limiter: ratelimit.NewMemory(),
+		perIP:   &config.RateLimitRule{Rate: 0.001, Burst: 1},
+		log:     log,
+	}
+
+	router := echo.New()
+	router.IPExtractor = ipExtractor(cfg)
+	router.Use(limits.perClient)
+	router.GET("/v1/tasks", func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+	return router
+}
+
+func requestFrom(router *echo.Echo, remoteAddr, forwardedFor string) int {
+	req := httptest.NewRequest(http.MethodGet, "/v1/tasks", nil)
+	req.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		req.Header.Set(echo.HeaderXForwardedFor, forwardedFor)
+		req.Header.Set(echo.HeaderXRealIP, forwardedFor)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestPerClientLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	router := perIPRouter(t)
+
+	if code := requestFrom(router, "203.0.113.7:4000", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first request: status = %d, want %d", code, http.StatusOK)
+	}
+	// Another X-Forwarded-For must not give the same client a fresh bucket.
+	if code := requestFrom(router, "203.0.113.7:4000", "198.51.100.2"); code != http.StatusTooManyRequests {
+		t.Fatalf("spoofed request: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	if code := requestFrom(router, "203.0.113.8:4000", ""); code != http.StatusOK {
+		t.Fatalf("other client: status = %d, want %d", code, http.StatusOK)
+	}
+}
+
+func TestPerClientLimitTrustsConfiguredProxies(t *testing.T) {
+	router := perIPRouter(t, "10.0.0.0/8")
+
+	if code := requestFrom(router, "10.1.2.3:4000", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("first client: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := requestFrom(router, "10.1.2.3:4000", "198.51.100.2"); code != http.StatusOK {
+		t.Fatalf("second client behind the proxy: status = %d, want %d", code, http.StatusOK)
+	}
+	if code := requestFrom(router, "10.1.2.3:4000", "198.51.100.1"); code != http.StatusTooManyRequests {
+		t.Fatalf("first client again: status = %d, want %d", code, http.StatusTooManyRequests)
+	}
+	// Private addresses are not trusted unless they are configured.
+	if code := requestFrom(router, "192.168.1.1:4000", "198.51.100.1"); code != http.StatusOK {
+		t.Fatalf("untrusted proxy: status = %d, want %d", code, http.StatusOK)
+	}
+}
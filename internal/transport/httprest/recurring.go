@@ -0,0 +1,188 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/recurring"
+)
+
+type (
+	// RequestTemplate is used both to create and to replace a template.
+	RequestTemplate struct {
+		ID          string   `param:"id" validate:"omitempty,uuid"` // only when replacing
+		Title       string   `json:"title" validate:"required,min=5,max=100"`
+		Description string   `json:"description" validate:"required,max=1000"`
+		Priority    string   `json:"priority" validate:"omitempty,oneof=P0 P1 P2 P3 P4"`
+		Assignee    string   `json:"assignee" validate:"omitempty,uuid"`
+		Labels      []string `json:"labels" validate:"max=20,dive,required,max=32"`
+		ProjectID   string   `json:"projectId" validate:"omitempty,uuid"`
+		ColumnID    string   `json:"columnId" validate:"omitempty,uuid"`
+		// RRule is like "FREQ=WEEKLY;BYDAY=MO", Start is an RFC 3339 timestamp of the first possible occurrence.
+		RRule        string    `json:"rrule" validate:"required,max=200"`
+		Start        time.Time `json:"start" validate:"required"`
+		Timezone     string    `json:"timezone" validate:"max=64"`
+		DueInMinutes int       `json:"dueInMinutes" validate:"min=0,max=525600"`
+	}
+
+	RequestTemplateRead struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+
+	RequestTemplateOccurrences struct {
+		ID    string `param:"id" validate:"required,uuid"`
+		Limit int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	}
+
+	recurringHandler struct {
+		recurringService recurring.Service
+	}
+)
+
+func NewRecurringHandler(recurringService recurring.Service) recurringHandler {
+	return recurringHandler{
+		recurringService: recurringService,
+	}
+}
+
+// RegisterV1 mounts recurring task templates on the group.
+func (h recurringHandler) RegisterV1(g *echo.Group) {
+	g.POST("", h.Create)
+	g.GET("", h.ReadAll)
+	g.GET("/:id", h.Read)
+	g.PUT("/:id", h.Update)
+	g.DELETE("/:id", h.Delete)
+	g.GET("/:id/occurrences", h.Occurrences)
+}
+
+func respondRecurringErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, recurring.ErrTemplateNotFound):
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, recurring.ErrInvalidRule), errors.Is(err, recurring.ErrInvalidTimezone):
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+	return respondErr(ctx, code, err)
+}
+
+func templateOf(req *RequestTemplate) recurring.Template {
+	return recurring.Template{
+		ID:           req.ID,
+		Title:        req.Title,
+		Description:  req.Description,
+		Priority:     req.Priority,
+		Assignee:     req.Assignee,
+		Labels:       req.Labels,
+		ProjectID:    req.ProjectID,
+		ColumnID:     req.ColumnID,
+		RRule:        req.RRule,
+		Start:        req.Start,
+		Timezone:     req.Timezone,
+		DueInMinutes: req.DueInMinutes,
+	}
+}
+
+func (h recurringHandler) Create(ctx echo.Context) error {
+	req := new(RequestTemplate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	template, err := h.recurringService.Create(ctx.Request().Context(), templateOf(req))
+	if err != nil {
+		return respondRecurringErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, template)
+}
+
+func (h recurringHandler) ReadAll(ctx echo.Context) error {
+	res, err := h.recurringService.ReadAll(ctx.Request().Context())
+	if err != nil {
+		return respondRecurringErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h recurringHandler) Read(ctx echo.Context) error {
+	req := new(RequestTemplateRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	template, err := h.recurringService.Read(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondRecurringErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, template)
+}
+
+// Update replaces the template, so every field has to be given as for Create.
+func (h recurringHandler) Update(ctx echo.Context) error {
+	req := new(RequestTemplate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	template, err := h.recurringService.Update(ctx.Request().Context(), templateOf(req))
+	if err != nil {
+		return respondRecurringErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, template)
+}
+
+func (h recurringHandler) Delete(ctx echo.Context) error {
+	req := new(RequestTemplateRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := h.recurringService.Delete(ctx.Request().Context(), req.ID); err != nil {
+		return respondRecurringErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// Occurrences previews when the next tasks of the template will be due to start, 10 of them by default.
+func (h recurringHandler) Occurrences(ctx echo.Context) error {
+	req := new(RequestTemplateOccurrences)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+	if req.Limit == 0 {
+		req.Limit = 10
+	}
+
+	res, err := h.recurringService.Occurrences(ctx.Request().Context(), req.ID, req.Limit)
+	if err != nil {
+		return respondRecurringErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, echo.Map{"occurrences": res})
+}
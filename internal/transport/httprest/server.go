@@ -13,17 +13,21 @@ import (
 	"github.com/rasulov-emirlan/topenergy-interview/config"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/domains"
 	"github.com/rasulov-emirlan/topenergy-interview/pkg/health"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/idempotency"
 	"github.com/rasulov-emirlan/topenergy-interview/pkg/logging"
+	"github.com/rasulov-emirlan/topenergy-interview/pkg/ratelimit"
 )
 
 const ServiceName = "tasks-service"
 
 type server struct {
+	cfg config.Config
 	srv *http.Server
 }
 
 func NewServer(cfg config.Config) server {
 	return server{
+		cfg: cfg,
 		srv: &http.Server{
 			Addr:         cfg.Server.Port,
 			ReadTimeout:  cfg.Server.TimeoutRead,
@@ -40,17 +44,52 @@ func (v *validatorWrapper) Validate(i any) error {
 	return v.validator.Struct(i)
 }
 
-func (s server) Start(log *logging.Logger, doms domains.DomainCombiner, checks []health.Checker) error {
+// Dependencies of the server, optional ones can be left nil.
+type Dependencies struct {
+	Log     *logging.Logger
+	Domains domains.DomainCombiner
+	Health  *health.Monitor
+
+	Limiter     ratelimit.Limiter // rate limiting is off without it
+	Idempotency idempotency.Store // Idempotency-Key headers are ignored without it
+	Metrics     http.Handler      // serves /metrics
+}
+
+func (s server) Start(deps Dependencies) error {
+	log, doms := deps.Log, deps.Domains
+
+	authenticator, err := newAuthenticator(s.cfg, doms.AuthService())
+	if err != nil {
+		return err
+	}
+
+	metrics, err := newMetricsMiddleware()
+	if err != nil {
+		return err
+	}
+
+	limits := rateLimiter{
+		limiter: deps.Limiter,
+		rules:   s.cfg.RateLimit.Rules,
+		perIP:   s.cfg.RateLimit.PerIP,
+		log:     log,
+	}
+
 	router := echo.New()
 	router.HideBanner = true
 	router.HidePort = true
+	router.IPExtractor = ipExtractor(s.cfg)
 	router.Validator = &validatorWrapper{validator: validator.New()}
+	router.Use(logging.EchoRequestID)
+	// Logs are written inside the span of the request, so that they carry its trace and span ids.
+	router.Use(otelecho.Middleware(ServiceName))
 	router.Use(log.NewEchoMiddleware)
 	router.Use(middleware.Gzip())
 	router.Use(middleware.Recover())
-	router.Use(middleware.CORS())
-
-	router.Use(otelecho.Middleware(ServiceName))
+	router.Use(corsMiddleware(s.cfg))
+	router.Use(metrics)
+	router.Use(limits.perClient)
+	router.Use(authenticator.Middleware)
 	router.HTTPErrorHandler = func(err error, c echo.Context) {
 		ctx := c.Request().Context()
 		trace.SpanFromContext(ctx).RecordError(err)
@@ -58,19 +97,67 @@ func (s server) Start(log *logging.Logger, doms domains.DomainCombiner, checks [
 		router.DefaultHTTPErrorHandler(err, c)
 	}
 
-	router.Any("/health*", echo.WrapHandler(health.NewHTTPHandler(ServiceName, checks)))
+	router.Any("/health/*", echo.WrapHandler(health.NewHTTPHandler(ServiceName, deps.Health)))
+	if deps.Metrics != nil {
+		router.GET("/metrics", echo.WrapHandler(deps.Metrics))
+	}
 	router.GET("/routes", func(ctx echo.Context) error {
 		return ctx.JSON(http.StatusOK, router.Routes())
 	})
 
 	tasksHandler := NewTasksHandler(doms.TasksService())
-	tasksGroup := router.Group("/tasks")
-	{
-		tasksGroup.POST("", tasksHandler.Create)
-		tasksGroup.GET("", tasksHandler.ReadAll)
-		tasksGroup.GET("/:id", tasksHandler.Read)
-		tasksGroup.PUT("/:id", tasksHandler.Update)
-		tasksGroup.DELETE("/:id", tasksHandler.Delete)
+	apiKeysHandler := NewAPIKeysHandler(doms.AuthService())
+	logLevelHandler := NewLogLevelHandler(log)
+	usersHandler := NewUsersHandler(doms.UsersService(), doms.TasksService())
+	commentsHandler := NewCommentsHandler(doms.CommentsService())
+	labelsHandler := NewLabelsHandler(doms.LabelsService())
+	projectsHandler := NewProjectsHandler(doms.ProjectsService(), doms.TasksService())
+	attachmentsHandler := NewAttachmentsHandler(doms.AttachmentsService())
+	recurringHandler := NewRecurringHandler(doms.RecurringService())
+	v1 := map[string]func(g *echo.Group){
+		"/tasks":         nested(tasksHandler.RegisterV1, commentsHandler.RegisterV1, attachmentsHandler.RegisterV1),
+		"/users":         usersHandler.RegisterV1,
+		"/labels":        labelsHandler.RegisterV1,
+		"/projects":      projectsHandler.RegisterV1,
+		"/recurring":     recurringHandler.RegisterV1,
+		"/admin/apikeys": apiKeysHandler.RegisterV1,
+	}
+	// requireRole already refuses requests without a principal, but without authentication
+	// nothing should be able to change the log level, so the route doesn't exist at all.
+	if !s.cfg.Auth.Disabled {
+		v1["/admin/loglevel"] = logLevelHandler.RegisterV1
+	}
+	versions := []apiVersion{
+		{
+			name:      "v1",
+			prefix:    "/v1",
+			resources: v1,
+		},
+		// New features are not backported to the legacy alias.
+		{
+			name:   "legacy",
+			prefix: "",
+			resources: map[string]func(g *echo.Group){
+				"/tasks": tasksHandler.RegisterV1,
+			},
+			deprecatedAt: legacyDeprecatedAt,
+			sunset:       s.cfg.Server.LegacySunset,
+			successor:    "/v1",
+		},
+	}
+	workspaces := func(string) echo.MiddlewareFunc {
+		return workspaceMiddleware(s.cfg.Tenancy.DefaultWorkspace)
+	}
+	idempotent := idempotencyGuard{
+		store:   deps.Idempotency,
+		ttl:     s.cfg.Server.IdempotencyTTL,
+		lockTTL: s.cfg.Server.IdempotencyLockTTL,
+		log:     log,
+	}
+	for _, v := range versions {
+		if err := v.mount(router, workspaces, limits.middleware, idempotent.middleware); err != nil {
+			return err
+		}
 	}
 
 	s.srv.Handler = router
@@ -1,32 +1,124 @@
 package httprest
 
 import (
+	"encoding/json"
 	"net/http"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/labels"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/projects"
 	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
 )
 
 type (
 	RequestTaskCreate struct {
-		Title       string `json:"title" validate:"required,min=5,max=100"`
-		Description string `json:"description" validate:"required,max=1000"`
+		Title       string   `json:"title" validate:"required,min=5,max=100"`
+		Description string   `json:"description" validate:"required,max=1000"`
+		Status      string   `json:"status" validate:"omitempty,oneof=todo in_progress done"`
+		Assignee    string   `json:"assignee" validate:"omitempty,uuid"`
+		Reporter    string   `json:"reporter" validate:"omitempty,uuid"`
+		Labels      []string `json:"labels" validate:"max=20,dive,required,max=32"`
+		Priority    string   `json:"priority" validate:"omitempty,oneof=P0 P1 P2 P3 P4"`
+		// DueAt is an RFC 3339 timestamp, its offset is kept as given.
+		DueAt    *time.Time `json:"dueAt"`
+		ParentID string     `json:"parentId" validate:"omitempty,uuid"`
+		// The task goes to the end of the column, or of the first column for its status if it is empty.
+		ProjectID string `json:"projectId" validate:"omitempty,uuid"`
+		ColumnID  string `json:"columnId" validate:"omitempty,uuid"`
 	}
 
 	RequestTaskRead struct {
 		ID string `param:"id" validate:"required,uuid"`
 	}
 
+	// RequestTaskReadAll filters tasks by labels, ?label=a&label=b matches tasks with any of them,
+	// adding &match=all matches only tasks with all of them.
+	RequestTaskReadAll struct {
+		Labels []string `query:"label" validate:"max=20,dive,required,max=32"`
+		Match  string   `query:"match" validate:"omitempty,oneof=all any"`
+	}
+
+	// RequestTaskUpdate changes the fields that are given, the rest are left as they are.
+	// A due date that is null is removed.
 	RequestTaskUpdate struct {
-		ID          string `param:"id" validate:"required,uuid"`
-		Title       string `json:"title" validate:"omitempty,min=5,max=100"`
-		Description string `json:"description" validate:"omitempty,max=1000"`
+		ID          string       `param:"id" validate:"required,uuid"`
+		Title       *string      `json:"title" validate:"omitempty,min=5,max=100"`
+		Description *string      `json:"description" validate:"omitempty,max=1000"`
+		Status      *string      `json:"status" validate:"omitempty,oneof=todo in_progress done"`
+		Priority    *string      `json:"priority" validate:"omitempty,oneof=P0 P1 P2 P3 P4"`
+		DueAt       optionalTime `json:"dueAt"`
 	}
 
 	RequestTaskDelete struct {
 		ID string `param:"id" validate:"required,uuid"`
 	}
 
+	RequestTaskLabel struct {
+		ID    string `param:"id" validate:"required,uuid"`
+		Label string `param:"label" validate:"required,max=32"`
+	}
+
+	RequestTaskParent struct {
+		ID       string `param:"id" validate:"required,uuid"`
+		ParentID string `json:"parentId" validate:"required,uuid"`
+	}
+
+	RequestTaskLink struct {
+		ID     string `param:"id" validate:"required,uuid"`
+		Type   string `json:"type" validate:"required,oneof=blocks relates_to duplicates"`
+		TaskID string `json:"taskId" validate:"required,uuid"`
+	}
+
+	RequestTaskUnlink struct {
+		ID     string `param:"id" validate:"required,uuid"`
+		Type   string `param:"type" validate:"required,oneof=blocks relates_to duplicates"`
+		TaskID string `param:"taskId" validate:"required,uuid"`
+	}
+
+	// RequestTaskMove puts the task at position in the column, counting from 0, or at its end without position.
+	// Tasks that are not in a project yet need ProjectID.
+	RequestTaskMove struct {
+		ID        string `param:"id" validate:"required,uuid"`
+		ProjectID string `json:"projectId" validate:"omitempty,uuid"`
+		ColumnID  string `json:"columnId" validate:"required,uuid"`
+		Position  *int   `json:"position" validate:"omitempty,min=0"`
+	}
+
+	// RequestChecklistItemAdd puts the item at position, counting from 0, or at the end without position.
+	RequestChecklistItemAdd struct {
+		ID       string `param:"id" validate:"required,uuid"`
+		Text     string `json:"text" validate:"required,max=500"`
+		Position *int   `json:"position" validate:"omitempty,min=0"`
+	}
+
+	// RequestChecklistItemChange sets done or text of the item, whichever is given.
+	// Setting done instead of flipping it makes retries and concurrent toggles safe.
+	RequestChecklistItemChange struct {
+		ID     string  `param:"id" validate:"required,uuid"`
+		ItemID string  `param:"itemId" validate:"required,uuid"`
+		Text   *string `json:"text" validate:"omitempty,min=1,max=500"`
+		Done   *bool   `json:"done"`
+	}
+
+	RequestChecklistItemMove struct {
+		ID       string `param:"id" validate:"required,uuid"`
+		ItemID   string `param:"itemId" validate:"required,uuid"`
+		Position *int   `json:"position" validate:"required,min=0"`
+	}
+
+	RequestChecklistItem struct {
+		ID     string `param:"id" validate:"required,uuid"`
+		ItemID string `param:"itemId" validate:"required,uuid"`
+	}
+
+	RequestTaskAssign struct {
+		ID       string `param:"id" validate:"required,uuid"`
+		Assignee string `json:"assignee" validate:"required,uuid"`
+	}
+
 	tasksHandler struct {
 		tasksService tasks.Service
 	}
@@ -38,10 +130,77 @@ func NewTasksHandler(tasksService tasks.Service) tasksHandler {
 	}
 }
 
+// RegisterV1 mounts the v1 representation of tasks on the group.
+func (h tasksHandler) RegisterV1(g *echo.Group) {
+	g.POST("", h.Create)
+	g.GET("", h.ReadAll)
+	g.GET("/overdue", h.ReadOverdue)
+	g.GET("/:id", h.Read)
+	g.PUT("/:id", h.Update)
+	g.DELETE("/:id", h.Delete)
+	g.PUT("/:id/assignee", h.Assign)
+	g.DELETE("/:id/assignee", h.Unassign)
+	g.PUT("/:id/labels/:label", h.AddLabel)
+	g.DELETE("/:id/labels/:label", h.RemoveLabel)
+	g.GET("/:id/subtasks", h.ReadSubtasks)
+	g.PUT("/:id/parent", h.SetParent)
+	g.DELETE("/:id/parent", h.UnsetParent)
+	g.GET("/:id/links", h.ReadLinks)
+	g.POST("/:id/links", h.AddLink)
+	g.DELETE("/:id/links/:type/:taskId", h.RemoveLink)
+	g.GET("/:id/graph", h.ReadGraph)
+	g.POST("/:id/move", h.Move)
+	g.POST("/:id/checklist", h.AddChecklistItem)
+	g.PATCH("/:id/checklist/:itemId", h.ChangeChecklistItem)
+	g.POST("/:id/checklist/:itemId/move", h.MoveChecklistItem)
+	g.DELETE("/:id/checklist/:itemId", h.DeleteChecklistItem)
+}
+
+// optionalTime tells a timestamp that is null, which is set with a nil Time, from one that is left out.
+type optionalTime struct {
+	Set  bool
+	Time *time.Time
+}
+
+func (o *optionalTime) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	return json.Unmarshal(data, &o.Time)
+}
+
 func respondErr(ctx echo.Context, code int, err error) error {
 	if err == tasks.ErrTaskNotFound {
 		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
 	}
+	if err == auth.ErrForbidden {
+		return ctx.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+	if err == auth.ErrUnauthenticated {
+		return ctx.JSON(http.StatusUnauthorized, echo.Map{"error": err.Error()})
+	}
+	if err == auth.ErrWorkspaceRequired {
+		return ctx.JSON(http.StatusBadRequest, echo.Map{"error": err.Error()})
+	}
+	if err == tasks.ErrQuotaExceeded {
+		return ctx.JSON(http.StatusForbidden, echo.Map{"error": err.Error()})
+	}
+	if err == tasks.ErrParentNotFound || err == tasks.ErrParentCycle || err == tasks.ErrParentTooDeep {
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+	if err == tasks.ErrHasSubtasks || err == tasks.ErrBlocked || err == tasks.ErrChecklistFull {
+		return ctx.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	if err == tasks.ErrLinkedTaskNotFound || err == tasks.ErrInvalidLink || err == tasks.ErrLinkCycle || err == tasks.ErrGraphTooLarge {
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+	if err == tasks.ErrLinkNotFound || err == tasks.ErrChecklistItemNotFound {
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	}
+	if err == projects.ErrProjectNotFound || err == projects.ErrColumnNotFound {
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
+	if err == users.ErrUserNotFound || err == users.ErrUserInactive || err == labels.ErrLabelNotFound {
+		return ctx.JSON(http.StatusUnprocessableEntity, echo.Map{"error": err.Error()})
+	}
 	return ctx.JSON(code, echo.Map{"error": err.Error()})
 }
 
@@ -58,6 +217,15 @@ func (h tasksHandler) Create(ctx echo.Context) error {
 	task, err := h.tasksService.Create(ctx.Request().Context(), tasks.Task{
 		Title:       req.Title,
 		Description: req.Description,
+		Status:      req.Status,
+		Assignee:    req.Assignee,
+		Reporter:    req.Reporter,
+		Labels:      req.Labels,
+		Priority:    req.Priority,
+		DueAt:       req.DueAt,
+		ParentID:    req.ParentID,
+		ProjectID:   req.ProjectID,
+		ColumnID:    req.ColumnID,
 	})
 	if err != nil {
 		return respondErr(ctx, http.StatusInternalServerError, err)
@@ -85,7 +253,19 @@ func (h tasksHandler) Read(ctx echo.Context) error {
 }
 
 func (h tasksHandler) ReadAll(ctx echo.Context) error {
-	res, err := h.tasksService.ReadAll(ctx.Request().Context())
+	req := new(RequestTaskReadAll)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	res, err := h.tasksService.ReadAll(ctx.Request().Context(), tasks.Filter{
+		Labels:   req.Labels,
+		MatchAll: req.Match == "all",
+	})
 	if err != nil {
 		return respondErr(ctx, http.StatusInternalServerError, err)
 	}
@@ -103,10 +283,13 @@ func (h tasksHandler) Update(ctx echo.Context) error {
 		return respondErr(ctx, http.StatusBadRequest, err)
 	}
 
-	task, err := h.tasksService.Update(ctx.Request().Context(), tasks.Task{
-		ID:          req.ID,
+	task, err := h.tasksService.Update(ctx.Request().Context(), req.ID, tasks.TaskChange{
 		Title:       req.Title,
 		Description: req.Description,
+		Status:      req.Status,
+		Priority:    req.Priority,
+		DueAt:       req.DueAt.Time,
+		ClearDueAt:  req.DueAt.Set && req.DueAt.Time == nil,
 	})
 	if err != nil {
 		return respondErr(ctx, http.StatusInternalServerError, err)
@@ -115,6 +298,16 @@ func (h tasksHandler) Update(ctx echo.Context) error {
 	return ctx.JSON(http.StatusOK, task)
 }
 
+// ReadOverdue lists tasks past their due date that are not done, earliest due first.
+func (h tasksHandler) ReadOverdue(ctx echo.Context) error {
+	res, err := h.tasksService.ReadOverdue(ctx.Request().Context())
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
 func (h tasksHandler) Delete(ctx echo.Context) error {
 	req := new(RequestTaskDelete)
 	if err := ctx.Bind(req); err != nil {
@@ -131,3 +324,305 @@ func (h tasksHandler) Delete(ctx echo.Context) error {
 
 	return ctx.NoContent(http.StatusOK)
 }
+
+func (h tasksHandler) Assign(ctx echo.Context) error {
+	req := new(RequestTaskAssign)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.Assign(ctx.Request().Context(), req.ID, req.Assignee)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) Unassign(ctx echo.Context) error {
+	req := new(RequestTaskRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.Assign(ctx.Request().Context(), req.ID, "")
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) AddLabel(ctx echo.Context) error {
+	req := new(RequestTaskLabel)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.AddLabel(ctx.Request().Context(), req.ID, req.Label)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) RemoveLabel(ctx echo.Context) error {
+	req := new(RequestTaskLabel)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.RemoveLabel(ctx.Request().Context(), req.ID, req.Label)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) ReadSubtasks(ctx echo.Context) error {
+	req := new(RequestTaskRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	res, err := h.tasksService.ReadSubtasks(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h tasksHandler) SetParent(ctx echo.Context) error {
+	req := new(RequestTaskParent)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.SetParent(ctx.Request().Context(), req.ID, req.ParentID)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) UnsetParent(ctx echo.Context) error {
+	req := new(RequestTaskRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.SetParent(ctx.Request().Context(), req.ID, "")
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) ReadLinks(ctx echo.Context) error {
+	req := new(RequestTaskRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	res, err := h.tasksService.ReadLinks(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h tasksHandler) AddLink(ctx echo.Context) error {
+	req := new(RequestTaskLink)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	link := tasks.Link{Type: req.Type, From: req.ID, To: req.TaskID}
+	if err := h.tasksService.AddLink(ctx.Request().Context(), link); err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, link)
+}
+
+func (h tasksHandler) RemoveLink(ctx echo.Context) error {
+	req := new(RequestTaskUnlink)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	link := tasks.Link{Type: req.Type, From: req.ID, To: req.TaskID}
+	if err := h.tasksService.RemoveLink(ctx.Request().Context(), link); err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.NoContent(http.StatusOK)
+}
+
+// ReadGraph returns tasks connected to the task through blocking links, directly or not.
+func (h tasksHandler) ReadGraph(ctx echo.Context) error {
+	req := new(RequestTaskRead)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	graph, err := h.tasksService.ReadGraph(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, graph)
+}
+
+func (h tasksHandler) Move(ctx echo.Context) error {
+	req := new(RequestTaskMove)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+
+	task, err := h.tasksService.Move(ctx.Request().Context(), req.ID, req.ProjectID, req.ColumnID, position)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) AddChecklistItem(ctx echo.Context) error {
+	req := new(RequestChecklistItemAdd)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+
+	task, err := h.tasksService.AddChecklistItem(ctx.Request().Context(), req.ID, req.Text, position)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, task)
+}
+
+func (h tasksHandler) ChangeChecklistItem(ctx echo.Context) error {
+	req := new(RequestChecklistItemChange)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.ChangeChecklistItem(ctx.Request().Context(), req.ID, req.ItemID, tasks.ChecklistChange{
+		Text: req.Text,
+		Done: req.Done,
+	})
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) MoveChecklistItem(ctx echo.Context) error {
+	req := new(RequestChecklistItemMove)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.MoveChecklistItem(ctx.Request().Context(), req.ID, req.ItemID, *req.Position)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
+
+func (h tasksHandler) DeleteChecklistItem(ctx echo.Context) error {
+	req := new(RequestChecklistItem)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	task, err := h.tasksService.DeleteChecklistItem(ctx.Request().Context(), req.ID, req.ItemID)
+	if err != nil {
+		return respondErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, task)
+}
@@ -0,0 +1,183 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/tasks"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/users"
+)
+
+type (
+	RequestUserCreate struct {
+		DisplayName string `json:"displayName" validate:"required,max=100"`
+		Email       string `json:"email" validate:"required,email"`
+	}
+
+	RequestUserByID struct {
+		ID string `param:"id" validate:"required,uuid"`
+	}
+
+	RequestUserUpdate struct {
+		ID          string `param:"id" validate:"required,uuid"`
+		DisplayName string `json:"displayName" validate:"omitempty,max=100"`
+		Email       string `json:"email" validate:"omitempty,email"`
+	}
+
+	RequestUserSetActive struct {
+		ID     string `param:"id" validate:"required,uuid"`
+		Active *bool  `json:"active" validate:"required"`
+	}
+
+	RequestUserTasks struct {
+		ID       string `param:"id" validate:"required,uuid"`
+		Relation string `query:"relation" validate:"omitempty,oneof=assignee reporter"`
+	}
+
+	usersHandler struct {
+		usersService users.Service
+		tasksService tasks.Service
+	}
+)
+
+func NewUsersHandler(usersService users.Service, tasksService tasks.Service) usersHandler {
+	return usersHandler{
+		usersService: usersService,
+		tasksService: tasksService,
+	}
+}
+
+// RegisterV1 mounts the v1 representation of users on the group.
+// Everyone can look users up, changes are authorized by the service.
+func (h usersHandler) RegisterV1(g *echo.Group) {
+	g.POST("", h.Create)
+	g.GET("", h.ReadAll)
+	g.GET("/:id", h.Read)
+	g.PUT("/:id", h.Update)
+	g.PUT("/:id/active", h.SetActive)
+	g.GET("/:id/tasks", h.ReadTasks)
+}
+
+// respondUserErr differs from respondErr in that a missing user is the resource not found here.
+func respondUserErr(ctx echo.Context, code int, err error) error {
+	switch {
+	case errors.Is(err, users.ErrUserNotFound):
+		return ctx.JSON(http.StatusNotFound, echo.Map{"error": err.Error()})
+	case errors.Is(err, users.ErrEmailTaken):
+		return ctx.JSON(http.StatusConflict, echo.Map{"error": err.Error()})
+	}
+	return respondErr(ctx, code, err)
+}
+
+func (h usersHandler) Create(ctx echo.Context) error {
+	req := new(RequestUserCreate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	user, err := h.usersService.Create(ctx.Request().Context(), users.User{
+		DisplayName: req.DisplayName,
+		Email:       req.Email,
+	})
+	if err != nil {
+		return respondUserErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusCreated, user)
+}
+
+func (h usersHandler) ReadAll(ctx echo.Context) error {
+	res, err := h.usersService.ReadAll(ctx.Request().Context())
+	if err != nil {
+		return respondUserErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
+
+func (h usersHandler) Read(ctx echo.Context) error {
+	req := new(RequestUserByID)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	user, err := h.usersService.Read(ctx.Request().Context(), req.ID)
+	if err != nil {
+		return respondUserErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, user)
+}
+
+func (h usersHandler) Update(ctx echo.Context) error {
+	req := new(RequestUserUpdate)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	user, err := h.usersService.Update(ctx.Request().Context(), users.User{
+		ID:          req.ID,
+		DisplayName: req.DisplayName,
+		Email:       req.Email,
+	})
+	if err != nil {
+		return respondUserErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, user)
+}
+
+func (h usersHandler) SetActive(ctx echo.Context) error {
+	req := new(RequestUserSetActive)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	user, err := h.usersService.SetActive(ctx.Request().Context(), req.ID, *req.Active)
+	if err != nil {
+		return respondUserErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, user)
+}
+
+// ReadTasks returns tasks of the current workspace assigned to the user,
+// or reported by them with ?relation=reporter.
+func (h usersHandler) ReadTasks(ctx echo.Context) error {
+	req := new(RequestUserTasks)
+	if err := ctx.Bind(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if err := ctx.Validate(req); err != nil {
+		return respondErr(ctx, http.StatusBadRequest, err)
+	}
+
+	if req.Relation == "" {
+		req.Relation = tasks.RelationAssignee
+	}
+
+	res, err := h.tasksService.ReadAllByUser(ctx.Request().Context(), req.ID, req.Relation)
+	if err != nil {
+		return respondUserErr(ctx, http.StatusInternalServerError, err)
+	}
+
+	return ctx.JSON(http.StatusOK, res)
+}
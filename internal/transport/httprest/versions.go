@@ -0,0 +1,114 @@
+package httprest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+const otelName = "github.com/rasulov-emirlan/topenergy-interview/internal/transport/httprest"
+
+// Unversioned routes are kept as an alias of v1 so existing clients
+// keep working, but they are deprecated and will be removed at the configured sunset.
+var legacyDeprecatedAt = time.Date(2023, time.July, 1, 0, 0, 0, 0, time.UTC)
+
+type (
+	// resourceMiddleware builds middleware for the resource at path.
+	resourceMiddleware func(path string) echo.MiddlewareFunc
+
+	// apiVersion is a set of resources that are mounted under a common prefix.
+	// Several versions can coexist, each one with its own handlers.
+	apiVersion struct {
+		name      string
+		prefix    string
+		resources map[string]func(g *echo.Group)
+
+		// Deprecated versions still serve requests, but every response
+		// carries Deprecation, Sunset and Link headers.
+		// The Link points to the same resource under the successor prefix.
+		deprecatedAt time.Time
+		sunset       time.Time
+		successor    string
+	}
+)
+
+// nested mounts a resource and its subresources on the same group.
+func nested(registers ...func(g *echo.Group)) func(g *echo.Group) {
+	return func(g *echo.Group) {
+		for _, register := range registers {
+			register(g)
+		}
+	}
+}
+
+func (v apiVersion) deprecated() bool {
+	return !v.deprecatedAt.IsZero()
+}
+
+// mount registers all resources of the version on the router,
+// mw is applied to every resource.
+func (v apiVersion) mount(router *echo.Echo, mw ...resourceMiddleware) error {
+	var deprecation echo.MiddlewareFunc
+	if v.deprecated() {
+		var err error
+		if deprecation, err = v.deprecationMiddleware(); err != nil {
+			return err
+		}
+	}
+
+	for path, register := range v.resources {
+		var group []echo.MiddlewareFunc
+		if deprecation != nil {
+			group = append(group, deprecation)
+		}
+		for _, m := range mw {
+			group = append(group, m(strings.Trim(path, "/")))
+		}
+		register(router.Group(v.prefix+path, group...))
+	}
+
+	return nil
+}
+
+func (v apiVersion) deprecationMiddleware() (echo.MiddlewareFunc, error) {
+	hits, err := otel.Meter(otelName).Int64Counter(
+		"http.server.deprecated_requests",
+		metric.WithDescription("Number of requests that hit deprecated API versions"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deprecation := "@" + strconv.FormatInt(v.deprecatedAt.Unix(), 10)
+	sunset := ""
+	if !v.sunset.IsZero() {
+		sunset = v.sunset.UTC().Format(http.TimeFormat)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			h := c.Response().Header()
+			h.Set("Deprecation", deprecation)
+			if sunset != "" {
+				h.Set("Sunset", sunset)
+			}
+			if v.successor != "" {
+				path := strings.TrimPrefix(c.Request().URL.Path, v.prefix)
+				h.Add("Link", "<"+v.successor+path+">; rel=\"successor-version\"")
+			}
+
+			hits.Add(c.Request().Context(), 1, metric.WithAttributes(
+				attribute.String("version", v.name),
+				attribute.String("route", c.Path()),
+			))
+
+			return next(c)
+		}
+	}, nil
+}
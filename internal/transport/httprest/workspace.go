@@ -0,0 +1,44 @@
+package httprest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rasulov-emirlan/topenergy-interview/internal/domains/auth"
+)
+
+const headerWorkspaceID = "X-Workspace-ID"
+
+// workspaceMiddleware resolves the workspace of the request from the principal
+// and the X-Workspace-ID header, and puts it into the request context.
+// Principals that are not members of any workspace belong to fallback.
+func workspaceMiddleware(fallback string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := c.Request().Context()
+			requested := c.Request().Header.Get(headerWorkspaceID)
+
+			var principal *auth.Principal
+			if p, ok := auth.PrincipalFrom(ctx); ok {
+				if len(p.Workspaces) == 0 && fallback != "" {
+					p.Workspaces = []string{fallback}
+					ctx = auth.WithPrincipal(ctx, p)
+				}
+				principal = &p
+			}
+
+			workspace, err := auth.ResolveWorkspace(principal, requested, fallback)
+			if err != nil {
+				code := http.StatusBadRequest
+				if errors.Is(err, auth.ErrForbidden) {
+					code = http.StatusForbidden
+				}
+				return c.JSON(code, echo.Map{"error": err.Error()})
+			}
+
+			c.SetRequest(c.Request().WithContext(auth.WithWorkspace(ctx, workspace)))
+			return next(c)
+		}
+	}
+}
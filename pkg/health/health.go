@@ -5,17 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"runtime"
 )
 
 const (
-	StatusDOWN = "DOWN"
-	StatusUP   = "UP"
+	StatusDOWN     = "DOWN"
+	StatusDEGRADED = "DEGRADED" // only non-critical checks failed, we can still serve traffic
+	StatusUP       = "UP"
 )
 
 type (
 	response struct {
-		Status string  `json:"status"`           // UP or DOWN
+		Status string  `json:"status"`           // UP, DEGRADED or DOWN
 		Checks []Check `json:"checks,omitempty"` // List of checks. Most likely external services
 		Data   any     `json:"data,omitempty"`
 	}
@@ -25,35 +25,22 @@ type (
 		Status   string `json:"status"`            // UP or DOWN
 		Critical bool   `json:"critical"`          // If true, the service is considered down
 		Message  string `json:"message,omitempty"` // Optional message. Could be used for errors
+		Latency  string `json:"latency,omitempty"` // How long the check took, set by the Monitor
 	}
 
 	// Checker function should perform a check of some sort of external service
 	Checker func(ctx context.Context) Check
-
-	system struct {
-		Memory memory `json:"memory"`
-		CPU    cpu    `json:"-"` // TODO: implement
-	}
-
-	memory struct {
-		Used int64 `json:"used"` // bytes used on the heap
-		Free int64 `json:"free"` // bytes free on the heap
-	}
-
-	cpu struct {
-		Used int64 `json:"used"` // cpu used in nanoseconds
-		Free int64 `json:"free"` // cpu free in nanoseconds
-	}
 )
 
-func NewHTTPHandler(serviceName string, checkers []Checker) http.Handler {
+func NewHTTPHandler(serviceName string, monitor *Monitor) http.Handler {
 	router := http.NewServeMux()
 	router.HandleFunc(
 		"/health/ping",
 		handlePing([]byte(fmt.Sprintf("pong from %s", serviceName))),
 	)
-	router.HandleFunc("/health/ready", handleReady(checkers))
-	router.HandleFunc("/health/live", handleLive)
+	router.HandleFunc("/health/ready", handleReady(monitor))
+	router.HandleFunc("/health/startup", handleStartup(monitor))
+	router.HandleFunc("/health/live", handleLive(monitor))
 
 	return router
 }
@@ -65,52 +52,60 @@ func handlePing(msg []byte) http.HandlerFunc {
 	}
 }
 
-func handleReady(checkers []Checker) http.HandlerFunc {
+func handleReady(monitor *Monitor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		status := StatusUP
-		checks := make([]Check, len(checkers))
-		for i, checker := range checkers {
-			check := checker(r.Context())
-			checks[i] = check
-			if check.Status != StatusUP {
-				if check.Critical {
-					status = StatusDOWN
-				}
-				w.WriteHeader(http.StatusInternalServerError)
-			}
+		checks := monitor.Checks(r.Context())
+		status := Aggregate(checks)
+		if !monitor.Started() {
+			status = StatusDOWN
 		}
 
-		resp, err := json.Marshal(response{
+		writeJSON(w, httpStatus(status), response{
 			Status: status,
 			Checks: checks,
 		})
-		if err != nil {
-			http.Error(
-				w,
-				fmt.Sprintf("could not marshal response err: %s", err.Error()),
-				http.StatusInternalServerError,
-			)
-			return
+	}
+}
+
+// handleStartup reports DOWN until the service has finished starting up.
+// Orchestrators hold off liveness and readiness probes until it reports UP.
+func handleStartup(monitor *Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := StatusUP
+		if !monitor.Started() {
+			status = StatusDOWN
+		}
+
+		writeJSON(w, httpStatus(status), response{Status: status})
+	}
+}
+
+// Aggregate returns DOWN if any critical check is not UP,
+// DEGRADED if only non-critical ones are not, and UP otherwise.
+func Aggregate(checks []Check) string {
+	status := StatusUP
+	for _, check := range checks {
+		if check.Status == StatusUP {
+			continue
+		}
+		if check.Critical {
+			return StatusDOWN
 		}
+		status = StatusDEGRADED
+	}
+	return status
+}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Write(resp)
+// httpStatus maps a status to a response code, a degraded service is still ready to take traffic.
+func httpStatus(status string) int {
+	if status == StatusDOWN {
+		return http.StatusServiceUnavailable
 	}
+	return http.StatusOK
 }
 
-func handleLive(w http.ResponseWriter, r *http.Request) {
-	memStats := runtime.MemStats{}
-	runtime.ReadMemStats(&memStats)
-
-	resp, err := json.Marshal(response{
-		Status: "UP",
-		Data: system{
-			Memory: memory{
-				Used: int64(memStats.Alloc),
-				Free: int64(memStats.Sys - memStats.Alloc),
-			},
-		},
-	})
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	resp, err := json.Marshal(v)
 	if err != nil {
 		http.Error(
 			w,
@@ -120,6 +115,24 @@ func handleLive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Headers are ignored once WriteHeader is called.
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
 	w.Write(resp)
 }
+
+// handleLive reports DOWN when the process looks stuck beyond recovery,
+// e.g. goroutines are leaking or the heap is about to be exhausted, so that it gets restarted.
+func handleLive(monitor *Monitor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := monitor.system()
+		checks := monitor.opts.Liveness.check(stats)
+
+		status := Aggregate(checks)
+		writeJSON(w, httpStatus(status), response{
+			Status: status,
+			Checks: checks,
+			Data:   stats,
+		})
+	}
+}
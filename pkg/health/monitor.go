@@ -0,0 +1,308 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultTimeout = 2 * time.Second
+
+type (
+	// Registration describes a checker and how it is run by the Monitor.
+	Registration struct {
+		Name    string
+		Checker Checker
+		Timeout time.Duration // Monitor's timeout is used if zero
+		// Critical marks the check as critical even when it fails without reporting it, e.g. on timeouts.
+		Critical bool
+
+		// DependsOn lists the names of checks that have to be UP before this one runs.
+		// If any of them is not, the check is reported DOWN without running it.
+		DependsOn []string
+	}
+
+	Options struct {
+		Timeout time.Duration // deadline of a single check, 2s if zero
+		// Results are reused for this long, not cached if zero. While Start runs,
+		// the results of its last run are used instead.
+		CacheTTL time.Duration
+		Liveness Thresholds
+	}
+
+	// Monitor runs checks concurrently, each with its own deadline, and caches their results.
+	Monitor struct {
+		opts   Options
+		checks []Registration
+
+		mu        sync.Mutex
+		last      []Check
+		checkedAt time.Time
+		running   *checkRun     // nil if checks are not running
+		interval  time.Duration // of Start, zero if it is not running
+
+		started atomic.Bool
+		cpu     cpuSampler
+	}
+
+	// checkRun is a single run of all checks that callers of Checks wait for together.
+	checkRun struct {
+		done   chan struct{}
+		checks []Check // set before done is closed
+	}
+)
+
+func NewMonitor(opts Options, checks ...Registration) (*Monitor, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	byName := make(map[string]Registration, len(checks))
+	for _, c := range checks {
+		if c.Name == "" {
+			return nil, fmt.Errorf("health: check has no name")
+		}
+		if _, ok := byName[c.Name]; ok {
+			return nil, fmt.Errorf("health: check %q is registered twice", c.Name)
+		}
+		byName[c.Name] = c
+	}
+	for _, c := range checks {
+		for _, dep := range c.DependsOn {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("health: check %q depends on unknown check %q", c.Name, dep)
+			}
+		}
+	}
+	if err := checkCycles(byName); err != nil {
+		return nil, err
+	}
+
+	return &Monitor{
+		opts:   opts,
+		checks: checks,
+	}, nil
+}
+
+func checkCycles(checks map[string]Registration) error {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[string]int, len(checks))
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			return fmt.Errorf("health: check %q is part of a dependency cycle", name)
+		case visited:
+			return nil
+		}
+		state[name] = visiting
+		for _, dep := range checks[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+		return nil
+	}
+
+	for name := range checks {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkStarted flips the startup probe to UP, call it once migrations and warm-up are done.
+func (m *Monitor) MarkStarted() {
+	m.started.Store(true)
+}
+
+func (m *Monitor) Started() bool {
+	return m.started.Load()
+}
+
+// Checks returns the results of all checks, from the cache if they are fresh enough.
+// Concurrent callers wait for a single run instead of starting their own,
+// and checks never run while the monitor is locked.
+func (m *Monitor) Checks(ctx context.Context) []Check {
+	m.mu.Lock()
+	if m.last != nil && m.fresh(time.Since(m.checkedAt)) {
+		checks := m.last
+		m.mu.Unlock()
+		return checks
+	}
+	r := m.start()
+	m.mu.Unlock()
+
+	select {
+	case <-r.done:
+		return r.checks
+	case <-ctx.Done():
+		// The caller gave up, the run goes on for the next one.
+		return m.unfinished(ctx.Err())
+	}
+}
+
+// fresh reports if results of the given age can be served, m.mu must be held.
+func (m *Monitor) fresh(age time.Duration) bool {
+	if m.interval > 0 {
+		// Results of Start are served as long as it keeps up, checks run on demand again if it got stuck.
+		return age < 2*m.interval+m.opts.Timeout
+	}
+	return age < m.opts.CacheTTL
+}
+
+// start returns the run in progress, or starts a new one, m.mu must be held.
+func (m *Monitor) start() *checkRun {
+	if m.running != nil {
+		return m.running
+	}
+
+	r := &checkRun{done: make(chan struct{})}
+	m.running = r
+	go func() {
+		// The run doesn't belong to any caller, the deadlines of the checks bound it.
+		checks := m.run(context.Background())
+
+		m.mu.Lock()
+		m.last = checks
+		m.checkedAt = time.Now()
+		m.running = nil
+		m.mu.Unlock()
+
+		r.checks = checks
+		close(r.done)
+	}()
+	return r
+}
+
+// unfinished returns the last results, or DOWN for every check if there are none yet.
+func (m *Monitor) unfinished(err error) []Check {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.last != nil {
+		return m.last
+	}
+	checks := make([]Check, len(m.checks))
+	for i, c := range m.checks {
+		checks[i] = Check{
+			Name:     c.Name,
+			Status:   StatusDOWN,
+			Critical: c.Critical,
+			Message:  fmt.Sprintf("not checked yet: %s", err),
+		}
+	}
+	return checks
+}
+
+// Start refreshes the cached results every interval until ctx is done,
+// so that probes are answered without waiting for the checks.
+func (m *Monitor) Start(ctx context.Context, interval time.Duration) {
+	m.mu.Lock()
+	m.interval = interval
+	m.mu.Unlock()
+	defer func() {
+		m.mu.Lock()
+		m.interval = 0
+		m.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.mu.Lock()
+		r := m.start()
+		m.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.done:
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run runs every check in its own goroutine,
+// checks with dependencies wait until their dependencies are done.
+func (m *Monitor) run(ctx context.Context) []Check {
+	results := make([]Check, len(m.checks))
+	done := make(map[string]chan struct{}, len(m.checks))
+	index := make(map[string]int, len(m.checks))
+	for i, c := range m.checks {
+		done[c.Name] = make(chan struct{})
+		index[c.Name] = i
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range m.checks {
+		wg.Add(1)
+		go func(i int, c Registration) {
+			defer wg.Done()
+			defer close(done[c.Name])
+
+			for _, dep := range c.DependsOn {
+				<-done[dep]
+				if results[index[dep]].Status != StatusUP {
+					results[i] = Check{
+						Name:     c.Name,
+						Status:   StatusDOWN,
+						Critical: c.Critical,
+						Message:  fmt.Sprintf("dependency %s is %s", dep, results[index[dep]].Status),
+					}
+					return
+				}
+			}
+
+			results[i] = m.runOne(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	return results
+}
+
+func (m *Monitor) runOne(ctx context.Context, c Registration) Check {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = m.opts.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	res := make(chan Check, 1)
+	go func() {
+		res <- c.Checker(ctx)
+	}()
+
+	var check Check
+	select {
+	case check = <-res:
+	case <-ctx.Done():
+		// The checker ignored its deadline, we don't wait for it any longer.
+		check = Check{
+			Status:  StatusDOWN,
+			Message: fmt.Sprintf("timed out after %s", timeout),
+		}
+	}
+
+	check.Name = c.Name
+	check.Critical = check.Critical || c.Critical
+	check.Latency = time.Since(start).String()
+	return check
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// clockTicks is USER_HZ, which /proc reports cpu times in.
+// It is 100 on every architecture Go supports, reading it needs cgo.
+const clockTicks = 100
+
+// readCPUTimes returns the user and system cpu time of the process.
+func readCPUTimes() (user, system time.Duration, err error) {
+	raw, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// The command name is in parentheses and may contain spaces,
+	// so fields are counted from the last closing parenthesis.
+	i := strings.LastIndexByte(string(raw), ')')
+	if i < 0 {
+		return 0, 0, fmt.Errorf("health: unexpected /proc/self/stat format")
+	}
+	fields := strings.Fields(string(raw[i+1:]))
+	// utime and stime are the 14th and 15th fields, the first two are pid and comm.
+	if len(fields) < 13 {
+		return 0, 0, fmt.Errorf("health: unexpected /proc/self/stat format")
+	}
+
+	utime, err := strconv.ParseInt(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	stime, err := strconv.ParseInt(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tick := time.Second / clockTicks
+	return time.Duration(utime) * tick, time.Duration(stime) * tick, nil
+}
+
+func openFDs() (int, error) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, err
+	}
+	// One of them is the directory we have just read.
+	return len(entries) - 1, nil
+}
+
+func fdLimit() (uint64, error) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, err
+	}
+	return rlimit.Cur, nil
+}
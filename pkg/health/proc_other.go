@@ -0,0 +1,22 @@
+//go:build !linux
+
+package health
+
+import (
+	"errors"
+	"time"
+)
+
+var errNoProc = errors.New("health: process stats need /proc")
+
+func readCPUTimes() (user, system time.Duration, err error) {
+	return 0, 0, errNoProc
+}
+
+func openFDs() (int, error) {
+	return 0, errNoProc
+}
+
+func fdLimit() (uint64, error) {
+	return 0, errNoProc
+}
@@ -0,0 +1,143 @@
+package health
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// startedAt approximates the process start, the package is initialized right after it.
+var startedAt = time.Now()
+
+type (
+	// Thresholds above which the process is considered not alive. Zero values disable a threshold.
+	Thresholds struct {
+		MaxGoroutines int
+		MaxHeapBytes  uint64
+	}
+
+	system struct {
+		Uptime     string `json:"uptime"`
+		Goroutines int    `json:"goroutines"`
+		Memory     memory `json:"memory"`
+		CPU        *cpu   `json:"cpu,omitempty"` // nil where /proc is not available
+		GC         gc     `json:"gc"`
+		FDs        *fds   `json:"fds,omitempty"` // nil where /proc is not available
+	}
+
+	memory struct {
+		Used int64 `json:"used"` // bytes of allocated heap objects
+		Free int64 `json:"free"` // bytes obtained from the OS but not used by heap objects
+		Sys  int64 `json:"sys"`  // bytes obtained from the OS
+	}
+
+	cpu struct {
+		User   float64 `json:"user"`   // seconds spent in user mode
+		System float64 `json:"system"` // seconds spent in kernel mode
+		// Usage since the previous sample, in percent of one core like top reports it.
+		Usage float64 `json:"usage"`
+	}
+
+	gc struct {
+		Count      uint32     `json:"count"`
+		PauseTotal string     `json:"pauseTotal"`
+		LastPause  string     `json:"lastPause"`
+		LastAt     *time.Time `json:"lastAt,omitempty"`
+	}
+
+	fds struct {
+		Open  int    `json:"open"`
+		Limit uint64 `json:"limit"`
+	}
+
+	// cpuSampler remembers the previous reading, usage is measured between two of them.
+	cpuSampler struct {
+		mu      sync.Mutex
+		at      time.Time
+		cpuTime time.Duration
+	}
+)
+
+func (m *Monitor) system() system {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	s := system{
+		Uptime:     time.Since(startedAt).Round(time.Second).String(),
+		Goroutines: runtime.NumGoroutine(),
+		Memory: memory{
+			Used: int64(ms.HeapAlloc),
+			Free: int64(ms.Sys - ms.HeapAlloc),
+			Sys:  int64(ms.Sys),
+		},
+		GC: gc{
+			Count:      ms.NumGC,
+			PauseTotal: time.Duration(ms.PauseTotalNs).String(),
+			LastPause:  time.Duration(ms.PauseNs[(ms.NumGC+255)%256]).String(),
+		},
+	}
+	if ms.LastGC != 0 {
+		lastAt := time.Unix(0, int64(ms.LastGC)).UTC()
+		s.GC.LastAt = &lastAt
+	}
+
+	if user, sys, err := readCPUTimes(); err == nil {
+		s.CPU = &cpu{
+			User:   user.Seconds(),
+			System: sys.Seconds(),
+			Usage:  m.cpu.sample(user + sys),
+		}
+	}
+
+	if open, err := openFDs(); err == nil {
+		s.FDs = &fds{Open: open}
+		s.FDs.Limit, _ = fdLimit()
+	}
+
+	return s
+}
+
+func (c *cpuSampler) sample(cpuTime time.Duration) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	prevAt, prevCPU := c.at, c.cpuTime
+	c.at, c.cpuTime = now, cpuTime
+
+	if prevAt.IsZero() {
+		// Nothing to compare with yet, average over the whole lifetime.
+		prevAt = startedAt
+		prevCPU = 0
+	}
+	wall := now.Sub(prevAt)
+	if wall <= 0 {
+		return 0
+	}
+	return float64(cpuTime-prevCPU) / float64(wall) * 100
+}
+
+func (t Thresholds) check(s system) []Check {
+	var checks []Check
+
+	if t.MaxGoroutines > 0 {
+		c := Check{Name: "goroutines", Status: StatusUP, Critical: true}
+		if s.Goroutines > t.MaxGoroutines {
+			c.Status = StatusDOWN
+			c.Message = fmt.Sprintf("%d goroutines, more than %d", s.Goroutines, t.MaxGoroutines)
+		}
+		checks = append(checks, c)
+	}
+
+	if t.MaxHeapBytes > 0 {
+		c := Check{Name: "memory", Status: StatusUP, Critical: true}
+		if uint64(s.Memory.Used) > t.MaxHeapBytes {
+			c.Status = StatusDOWN
+			c.Message = fmt.Sprintf("%d bytes on the heap, more than %d", s.Memory.Used, t.MaxHeapBytes)
+		}
+		checks = append(checks, c)
+	}
+
+	return checks
+}
@@ -0,0 +1,38 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInProgress means that a request with the same key is still being processed.
+	ErrInProgress = errors.New("request with this idempotency key is in progress")
+)
+
+type (
+	// Record is what is remembered about a request with an idempotency key.
+	// A record without status belongs to a request that is still in progress.
+	Record struct {
+		Fingerprint string `json:"fingerprint"`
+		Status      int    `json:"status,omitempty"`
+		ContentType string `json:"contentType,omitempty"`
+		Body        []byte `json:"body,omitempty"`
+	}
+
+	Store interface {
+		// Begin saves an in-progress record for key that expires after ttl if there is none yet and returns true,
+		// otherwise it returns the existing record and false. The ttl bounds how long a request that never
+		// completes, e.g. because its replica died, blocks retries.
+		Begin(ctx context.Context, key, fingerprint string, ttl time.Duration) (Record, bool, error)
+		// Complete stores the response of the request that began with key, it expires after ttl.
+		Complete(ctx context.Context, key string, record Record, ttl time.Duration) error
+		// Abort forgets key, so that the request can be retried.
+		Abort(ctx context.Context, key string) error
+	}
+)
+
+func (r Record) InProgress() bool {
+	return r.Status == 0
+}
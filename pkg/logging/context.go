@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx that carries the request id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the request id stored in ctx by WithRequestID.
+func RequestIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(requestIDKey{}).(string)
+	return id, ok && id != ""
+}
+
+// contextFields returns fields that correlate a log entry with its trace and request.
+func contextFields(ctx context.Context, fields []Field) []Field {
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		fields = append(fields,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()),
+		)
+	}
+	if id, ok := RequestIDFrom(ctx); ok {
+		fields = append(fields, zap.String("request_id", id))
+	}
+	return fields
+}
+
+// Methods below behave like their counterparts without Context,
+// but also add trace_id, span_id and request_id found in ctx.
+
+func (l *Logger) InfoContext(ctx context.Context, msg string, fields ...Field) {
+	l.logger.Info(msg, contextFields(ctx, fields)...)
+}
+
+func (l *Logger) DebugContext(ctx context.Context, msg string, fields ...Field) {
+	l.logger.Debug(msg, contextFields(ctx, fields)...)
+}
+
+func (l *Logger) WarnContext(ctx context.Context, msg string, fields ...Field) {
+	l.logger.Warn(msg, contextFields(ctx, fields)...)
+}
+
+func (l *Logger) ErrorContext(ctx context.Context, msg string, fields ...Field) {
+	l.logger.Error(msg, contextFields(ctx, fields)...)
+}
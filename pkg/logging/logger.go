@@ -1,16 +1,28 @@
 package logging
 
 import (
+	"net/http"
+	"time"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"gopkg.in/natefinch/lumberjack.v2"
 )
 
 type (
 	Logger struct {
 		logger *zap.Logger
+		level  zap.AtomicLevel
 		closer func() error
 	}
 
+	Option func(*options)
+
+	options struct {
+		sampling *zap.SamplingConfig
+		file     *lumberjack.Logger
+	}
+
 	ErrCloser struct {
 		Errors []error
 	}
@@ -40,13 +52,51 @@ func (e *ErrCloser) Error() string {
 	return err
 }
 
+// WithSampling keeps the first initial entries with the same level and message
+// every second, and then only every thereafter-th of them. It is ignored in dev mode.
+func WithSampling(initial, thereafter int) Option {
+	return func(o *options) {
+		o.sampling = &zap.SamplingConfig{Initial: initial, Thereafter: thereafter}
+	}
+}
+
+// WithFile also writes JSON logs to filename, which is rotated once it grows beyond maxSizeMB megabytes.
+// Rotated files are removed after maxAgeDays days, or when there are more than maxBackups of them.
+func WithFile(filename string, maxSizeMB, maxAgeDays, maxBackups int) Option {
+	return func(o *options) {
+		o.file = &lumberjack.Logger{
+			Filename:   filename,
+			MaxSize:    maxSizeMB,
+			MaxAge:     maxAgeDays,
+			MaxBackups: maxBackups,
+			Compress:   true,
+		}
+	}
+}
+
 // If logLevel is not valid, it will be set to debug.
-func NewLogger(logLevel string) (*Logger, error) {
+// The "dev" level logs colored human readable lines, all other levels log JSON.
+func NewLogger(logLevel string, opts ...Option) (*Logger, error) {
 	var (
 		logger Logger
 		err    error
+		o      options
 	)
-	config := zap.NewDevelopmentConfig()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var config zap.Config
+	if logLevel == "dev" {
+		config = zap.NewDevelopmentConfig()
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		o.sampling = nil
+	} else {
+		config = zap.NewProductionConfig()
+		config.EncoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder
+	}
+	// Sampling is applied below, so that it covers the file as well.
+	config.Sampling = nil
 
 	lvl := zapcore.DebugLevel
 	if l, ok := logLevels[logLevel]; ok {
@@ -55,10 +105,8 @@ func NewLogger(logLevel string) (*Logger, error) {
 
 	config.EncoderConfig.StacktraceKey = zapcore.OmitKey
 	config.EncoderConfig.CallerKey = zapcore.OmitKey
-	if logLevel == "dev" {
-		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-	}
 	config.Level = zap.NewAtomicLevelAt(lvl)
+	logger.level = config.Level
 
 	logger.closer = func() error {
 		// If this logger will have any connections to external services, close them here.
@@ -66,7 +114,25 @@ func NewLogger(logLevel string) (*Logger, error) {
 		return nil
 	}
 
-	logger.logger, err = config.Build()
+	var buildOpts []zap.Option
+	if o.file != nil {
+		fileEncoder := config.EncoderConfig
+		fileEncoder.EncodeLevel = zapcore.LowercaseLevelEncoder
+		fileCore := zapcore.NewCore(zapcore.NewJSONEncoder(fileEncoder), zapcore.AddSync(o.file), config.Level)
+
+		buildOpts = append(buildOpts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(core, fileCore)
+		}))
+		logger.closer = o.file.Close
+	}
+	if o.sampling != nil {
+		sampling := o.sampling
+		buildOpts = append(buildOpts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewSamplerWithOptions(core, time.Second, sampling.Initial, sampling.Thereafter)
+		}))
+	}
+
+	logger.logger, err = config.Build(buildOpts...)
 	if err != nil {
 		if errr := logger.closer(); errr != nil {
 			return nil, &ErrCloser{
@@ -83,6 +149,17 @@ func (l *Logger) Close() error {
 	return l.closer()
 }
 
+// Level returns the current level.
+func (l *Logger) Level() string {
+	return l.level.String()
+}
+
+// LevelHandler reports the level on GET and changes it on PUT, both with {"level": "<level>"} bodies.
+// It has no authentication of its own.
+func (l *Logger) LevelHandler() http.Handler {
+	return l.level
+}
+
 func (l Logger) Goosed() GooseLogger {
 	return GooseLogger{Logger: l.logger}
 }
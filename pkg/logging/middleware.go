@@ -1,13 +1,19 @@
 package logging
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/labstack/echo/v4"
 )
 
-const reqLogMsg = "transport_log"
+const (
+	reqLogMsg       = "transport_log"
+	HeaderRequestID = "X-Request-ID"
+	maxRequestIDLen = 128
+)
 
 func (l *Logger) NewHTTPMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -15,7 +21,8 @@ func (l *Logger) NewHTTPMiddleware(next http.Handler) http.Handler {
 
 		next.ServeHTTP(w, r)
 
-		l.Info(
+		l.InfoContext(
+			r.Context(),
 			reqLogMsg,
 			String("method", r.Method),
 			String("path", r.URL.Path),
@@ -24,30 +31,64 @@ func (l *Logger) NewHTTPMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// EchoRequestID takes the request id from the X-Request-ID header, or generates one,
+// puts it into the request context and echoes it back in the response.
+func EchoRequestID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		id := c.Request().Header.Get(HeaderRequestID)
+		if id == "" || len(id) > maxRequestIDLen {
+			id = uuid.New().String()
+		}
+
+		c.Response().Header().Set(HeaderRequestID, id)
+		c.SetRequest(c.Request().WithContext(WithRequestID(c.Request().Context(), id)))
+
+		return next(c)
+	}
+}
+
+// NewEchoMiddleware logs every request once it is handled. It has to be registered after
+// the tracing middleware, which puts the request it got back when it returns,
+// so that entries carry the trace_id and span_id of the request.
 func (l *Logger) NewEchoMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 	return func(c echo.Context) error {
 		begin := time.Now()
 
 		err := next(c)
 
+		// Inner middlewares replace the request to add e.g. principals to its context, so it is taken after next.
+		req := c.Request()
+		fields := []Field{
+			String("method", req.Method),
+			String("path", req.URL.Path),
+			String("route", c.Path()),
+			Int("status", responseStatus(c, err)),
+			Int64("bytes", c.Response().Size),
+			String("client_ip", c.RealIP()),
+			String("duration", time.Since(begin).String()),
+		}
+
 		if err != nil {
-			l.Error(
-				reqLogMsg,
-				String("method", c.Request().Method),
-				String("path", c.Request().URL.Path),
-				String("duration", time.Since(begin).String()),
-				String("error", err.Error()),
-			)
+			l.ErrorContext(req.Context(), reqLogMsg, append(fields, String("error", err.Error()))...)
 			return err
 		}
 
-		l.Info(
-			reqLogMsg,
-			String("method", c.Request().Method),
-			String("path", c.Request().URL.Path),
-			String("duration", time.Since(begin).String()),
-		)
+		l.InfoContext(req.Context(), reqLogMsg, fields...)
 
 		return err
 	}
 }
+
+// responseStatus is the status the client gets, errors are only
+// turned into responses after all middlewares returned.
+func responseStatus(c echo.Context, err error) int {
+	if err == nil {
+		return c.Response().Status
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code
+	}
+	return http.StatusInternalServerError
+}
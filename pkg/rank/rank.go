@@ -0,0 +1,108 @@
+// Package rank generates lexicographically ordered keys for lists that are reordered often.
+// A key between any two others can always be generated, so moving an item never rewrites its neighbours.
+package rank
+
+import (
+	"errors"
+	"strings"
+)
+
+// Keys are written in base 36 and never end with the smallest digit,
+// which leaves room for a key before any other key.
+const digits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+var ErrInvalidRange = errors.New("rank: prev must not be greater than next")
+
+// Between returns a key that sorts after prev and before next.
+// An empty prev means the start of the list and an empty next means its end.
+// Equal prev and next, which two items get if they were ranked at the same time, are a tie:
+// nothing sorts between them, so the key sorts after both of them instead.
+func Between(prev, next string) (string, error) {
+	if !valid(prev) || !valid(next) || (next != "" && prev > next) {
+		return "", ErrInvalidRange
+	}
+	if prev != "" && prev == next {
+		return prev + midpoint("", ""), nil
+	}
+
+	// Appending and prepending step by one digit instead of halving the space that is left,
+	// so that keys grow slowly when items keep being added to the ends of a list.
+	if prev != "" && next == "" {
+		for i := 0; i < len(prev); i++ {
+			if d := strings.IndexByte(digits, prev[i]); d < len(digits)-1 {
+				return prev[:i] + string(digits[d+1]), nil
+			}
+		}
+	}
+	if prev == "" && next != "" {
+		for i := 0; i < len(next); i++ {
+			if d := strings.IndexByte(digits, next[i]); d > 1 {
+				return next[:i] + string(digits[d-1]), nil
+			}
+		}
+	}
+
+	return midpoint(prev, next), nil
+}
+
+func valid(key string) bool {
+	if key == "" {
+		return true
+	}
+	if key[len(key)-1] == digits[0] {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if strings.IndexByte(digits, key[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// midpoint expects prev < next, with an empty next being greater than anything.
+func midpoint(prev, next string) string {
+	if next != "" {
+		// The common prefix stays as is, prev is padded with the smallest digit.
+		n := 0
+		for n < len(next) && digitAt(prev, n) == next[n] {
+			n++
+		}
+		if n > 0 {
+			rest := ""
+			if n < len(prev) {
+				rest = prev[n:]
+			}
+			return next[:n] + midpoint(rest, next[n:])
+		}
+	}
+
+	lo := 0
+	if prev != "" {
+		lo = strings.IndexByte(digits, prev[0])
+	}
+	hi := len(digits)
+	if next != "" {
+		hi = strings.IndexByte(digits, next[0])
+	}
+
+	if hi-lo > 1 {
+		return string(digits[(lo+hi+1)/2])
+	}
+	// The first digits are adjacent, so the key has to be longer.
+	if len(next) > 1 {
+		return next[:1]
+	}
+	rest := ""
+	if prev != "" {
+		rest = prev[1:]
+	}
+	return string(digits[lo]) + midpoint(rest, "")
+}
+
+func digitAt(key string, i int) byte {
+	if i < len(key) {
+		return key[i]
+	}
+	return digits[0]
+}
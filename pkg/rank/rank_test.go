@@ -0,0 +1,92 @@
+package rank
+
+import "testing"
+
+func TestBetween(t *testing.T) {
+	tests := []struct {
+		name       string
+		prev, next string
+		want       string
+		wantErr    bool
+	}{
+		{"empty list", "", "", "i", false},
+		{"append", "a", "", "b", false},
+		{"append after last digit", "z", "", "zi", false},
+		{"prepend", "", "c", "b", false},
+		{"prepend before smallest key", "", "1", "0i", false},
+		{"room between", "a", "c", "b", false},
+		{"adjacent digits", "a", "b", "ai", false},
+		{"next is longer", "a", "b1", "b", false},
+		{"common prefix", "a", "a1", "a0i", false},
+		{"tie", "x", "x", "xi", false},
+		{"reversed", "b", "a", "", true},
+		{"ends with smallest digit", "a0", "", "", true},
+		{"unknown digit", "A", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Between(tt.prev, tt.next)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Between(%q, %q) = %q, want error", tt.prev, tt.next, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Between(%q, %q) failed: %v", tt.prev, tt.next, err)
+			}
+			if got != tt.want {
+				t.Errorf("Between(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+			}
+			if !valid(got) || got <= tt.prev || (tt.next != "" && tt.prev != tt.next && got >= tt.next) {
+				t.Errorf("Between(%q, %q) = %q, which is out of order", tt.prev, tt.next, got)
+			}
+		})
+	}
+}
+
+func TestMidpoint(t *testing.T) {
+	tests := []struct {
+		prev, next string
+		want       string
+	}{
+		{"", "", "i"},
+		{"", "1", "0i"},
+		{"a", "", "n"},
+		{"ai", "b", "ar"},
+		{"a", "a1", "a0i"},
+		{"a1", "a2", "a1i"},
+		{"z", "", "zi"},
+	}
+	for _, tt := range tests {
+		got := midpoint(tt.prev, tt.next)
+		if got != tt.want {
+			t.Errorf("midpoint(%q, %q) = %q, want %q", tt.prev, tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestBetweenKeepsOrder(t *testing.T) {
+	between := func(prev, next string) string {
+		key, err := Between(prev, next)
+		if err != nil {
+			t.Fatalf("Between(%q, %q) failed: %v", prev, next, err)
+		}
+		return key
+	}
+
+	// Keep inserting at both ends and right after the first key, the list must stay sorted.
+	keys := []string{between("", "")}
+	for i := 0; i < 200; i++ {
+		keys = append([]string{between("", keys[0])}, keys...)
+		keys = append(keys, between(keys[len(keys)-1], ""))
+		second := between(keys[0], keys[1])
+		keys = append(keys[:1], append([]string{second}, keys[1:]...)...)
+	}
+
+	for i := 1; i < len(keys); i++ {
+		if keys[i-1] >= keys[i] || !valid(keys[i]) {
+			t.Fatalf("keys %q and %q are out of order", keys[i-1], keys[i])
+		}
+	}
+}
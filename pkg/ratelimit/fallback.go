@@ -0,0 +1,66 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Fallback uses primary, and secondary whenever primary fails.
+// It keeps limits enforced, although less precisely, while a shared backend is down.
+type Fallback struct {
+	primary   Limiter
+	secondary Limiter
+	reports   *reporter
+}
+
+var _ Limiter = (*Fallback)(nil)
+
+// NewFallback creates a Fallback. While primary keeps failing, onError is called with its first error
+// and then at most once every interval with the latest one and how many errors there were since the last call,
+// so that a backend that is down doesn't flood the logs. onError may be nil.
+func NewFallback(primary, secondary Limiter, interval time.Duration, onError func(err error, failures int)) Fallback {
+	return Fallback{
+		primary:   primary,
+		secondary: secondary,
+		reports:   &reporter{interval: interval, report: onError},
+	}
+}
+
+func (f Fallback) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	res, err := f.primary.Allow(ctx, key, limit)
+	if err == nil {
+		return res, nil
+	}
+
+	f.reports.failed(err)
+	return f.secondary.Allow(ctx, key, limit)
+}
+
+type reporter struct {
+	interval time.Duration
+	report   func(err error, failures int)
+
+	mu       sync.Mutex
+	last     time.Time
+	failures int
+}
+
+func (r *reporter) failed(err error) {
+	if r.report == nil {
+		return
+	}
+
+	r.mu.Lock()
+	r.failures++
+	now := time.Now()
+	if !r.last.IsZero() && now.Sub(r.last) < r.interval {
+		r.mu.Unlock()
+		return
+	}
+	failures := r.failures
+	r.last, r.failures = now, 0
+	r.mu.Unlock()
+
+	r.report(err, failures)
+}
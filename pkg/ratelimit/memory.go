@@ -0,0 +1,76 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+type (
+	// Memory keeps buckets in the process memory.
+	// Limits are not shared between replicas, so it is meant for single-node setups
+	// and as a fallback when the shared backend is unavailable.
+	Memory struct {
+		mu        sync.Mutex
+		buckets   map[string]*bucket
+		lastSweep time.Time
+	}
+
+	bucket struct {
+		tokens  float64
+		updated time.Time
+		full    time.Time // when the bucket becomes full and can be forgotten
+	}
+)
+
+var _ Limiter = (*Memory)(nil)
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets:   map[string]*bucket{},
+		lastSweep: time.Now(),
+	}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+elapsed*limit.Rate)
+	b.updated = now
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(seconds((float64(limit.Burst) - b.tokens) / limit.Rate))
+
+	return NewResult(limit, allowed, b.tokens), nil
+}
+
+// sweep drops buckets that are full, they are no different from missing ones.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < sweepInterval {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
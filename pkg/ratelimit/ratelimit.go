@@ -0,0 +1,49 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+type (
+	// Limit is a token bucket: it holds up to Burst tokens and is refilled at Rate tokens per second.
+	// Every request takes one token.
+	Limit struct {
+		Rate  float64
+		Burst int
+	}
+
+	Result struct {
+		Allowed    bool
+		Limit      int           // size of the bucket
+		Remaining  int           // whole tokens left in the bucket
+		RetryAfter time.Duration // when the next token will be available, zero if allowed
+		Reset      time.Duration // when the bucket will be full again
+	}
+
+	Limiter interface {
+		Allow(ctx context.Context, key string, limit Limit) (Result, error)
+	}
+)
+
+// NewResult builds a Result out of the amount of tokens left in the bucket after a request.
+func NewResult(limit Limit, allowed bool, tokens float64) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     limit.Burst,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(limit.Burst) - tokens) / limit.Rate),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / limit.Rate)
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	if s < 0 {
+		return 0
+	}
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,525 @@
+// Package rrule computes occurrences of recurrence rules written as a subset of RFC 5545 RRULE.
+//
+// Supported parts are FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH and WKST. Ordinal weekdays like "1MO" or "-1FR" count within a month,
+// so they can only be used with FREQ=MONTHLY or YEARLY, and BYDAY and BYMONTHDAY of yearly rules need BYMONTH.
+// Occurrences keep the wall clock time of the start in its location, across DST changes too.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidRule = errors.New("rrule: invalid rule")
+
+type Frequency int
+
+const (
+	Daily Frequency = iota + 1
+	Weekly
+	Monthly
+	Yearly
+)
+
+var frequencies = map[string]Frequency{
+	"DAILY":   Daily,
+	"WEEKLY":  Weekly,
+	"MONTHLY": Monthly,
+	"YEARLY":  Yearly,
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// The Gregorian calendar repeats itself every 400 years, which are a whole number of weeks too,
+// so a rule that has no occurrence for that long never has one again, e.g. every February 30th.
+// These are the numbers of periods in 400 years, which also bound the periods a rule with an interval
+// looks at before it comes back to a period it has already looked at.
+const (
+	cycleDays   = 146097
+	cycleWeeks  = cycleDays / 7
+	cycleMonths = 400 * 12
+	cycleYears  = 400
+)
+
+// WeekdayNum is a weekday, N of them counting from the start of the month or from its end if negative,
+// or every one of them if N is 0.
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int
+}
+
+type Rule struct {
+	Freq     Frequency
+	Interval int // every Interval periods, at least 1
+	Count    int // number of occurrences, 0 means unlimited
+	// Until is the last moment an occurrence can happen at, unlimited if zero.
+	// If UntilLocal is set, its wall clock is read in the location of the start instead of UTC.
+	Until      time.Time
+	UntilLocal bool
+	ByDay      []WeekdayNum
+	ByMonthDay []int // negative days count from the end of the month
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+}
+
+// Parse parses a rule like "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH", with or without the "RRULE:" prefix.
+func Parse(s string) (Rule, error) {
+	r := Rule{Interval: 1, WeekStart: time.Monday}
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	if s == "" {
+		return Rule{}, fmt.Errorf("%w: empty", ErrInvalidRule)
+	}
+
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.ToUpper(name)
+		if !ok || value == "" {
+			return Rule{}, fmt.Errorf("%w: %q must look like <name>=<value>", ErrInvalidRule, part)
+		}
+		if seen[name] {
+			return Rule{}, fmt.Errorf("%w: %s is given twice", ErrInvalidRule, name)
+		}
+		seen[name] = true
+
+		var err error
+		switch name {
+		case "FREQ":
+			if r.Freq, ok = frequencies[strings.ToUpper(value)]; !ok {
+				err = fmt.Errorf("%w: FREQ must be DAILY, WEEKLY, MONTHLY or YEARLY", ErrInvalidRule)
+			}
+		case "INTERVAL":
+			r.Interval, err = parseInt(name, value, 1, 1000)
+		case "COUNT":
+			r.Count, err = parseInt(name, value, 1, 100000)
+		case "UNTIL":
+			r.Until, r.UntilLocal, err = parseUntil(value)
+		case "BYDAY":
+			r.ByDay, err = parseByDay(value)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseList(name, value, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseList(name, value, 1, 12)
+			for _, m := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(m))
+			}
+		case "WKST":
+			if r.WeekStart, ok = weekdays[strings.ToUpper(value)]; !ok {
+				err = fmt.Errorf("%w: WKST must be a weekday like MO", ErrInvalidRule)
+			}
+		default:
+			err = fmt.Errorf("%w: %s is not supported", ErrInvalidRule, name)
+		}
+		if err != nil {
+			return Rule{}, err
+		}
+	}
+
+	if err := r.validate(); err != nil {
+		return Rule{}, err
+	}
+	return r, nil
+}
+
+func (r Rule) validate() error {
+	if r.Freq == 0 {
+		return fmt.Errorf("%w: FREQ is required", ErrInvalidRule)
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return fmt.Errorf("%w: COUNT and UNTIL can't be used together", ErrInvalidRule)
+	}
+	if r.Freq == Weekly && len(r.ByMonthDay) > 0 {
+		return fmt.Errorf("%w: BYMONTHDAY can't be used with FREQ=WEEKLY", ErrInvalidRule)
+	}
+	if r.Freq == Yearly && len(r.ByMonth) == 0 && (len(r.ByDay) > 0 || len(r.ByMonthDay) > 0) {
+		return fmt.Errorf("%w: BYDAY and BYMONTHDAY need BYMONTH with FREQ=YEARLY", ErrInvalidRule)
+	}
+	for _, d := range r.ByDay {
+		if d.N != 0 && r.Freq != Monthly && r.Freq != Yearly {
+			return fmt.Errorf("%w: ordinal weekdays need FREQ=MONTHLY or FREQ=YEARLY", ErrInvalidRule)
+		}
+	}
+	return nil
+}
+
+func parseInt(name, value string, min, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%w: %s must be between %d and %d", ErrInvalidRule, name, min, max)
+	}
+	return n, nil
+}
+
+func parseList(name, value string, min, max int) ([]int, error) {
+	var result []int
+	for _, v := range strings.Split(value, ",") {
+		n, err := parseInt(name, v, min, max)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, fmt.Errorf("%w: %s can't be 0", ErrInvalidRule, name)
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var result []WeekdayNum
+	for _, v := range strings.Split(strings.ToUpper(value), ",") {
+		if len(v) < 2 {
+			return nil, fmt.Errorf("%w: BYDAY must list weekdays like MO or 1MO", ErrInvalidRule)
+		}
+		day, ok := weekdays[v[len(v)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("%w: BYDAY must list weekdays like MO or 1MO", ErrInvalidRule)
+		}
+		n := 0
+		if ordinal := v[:len(v)-2]; ordinal != "" {
+			var err error
+			if n, err = parseInt("BYDAY ordinal", strings.TrimPrefix(ordinal, "+"), -5, 5); err != nil || n == 0 {
+				return nil, fmt.Errorf("%w: BYDAY ordinals must be between -5 and 5, except 0", ErrInvalidRule)
+			}
+		}
+		result = append(result, WeekdayNum{Weekday: day, N: n})
+	}
+	return result, nil
+}
+
+// parseUntil accepts a date, a local date-time or a UTC date-time.
+func parseUntil(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.Parse("20060102T150405", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102", value); err == nil {
+		// A date includes the whole day.
+		return t.Add(24*time.Hour - time.Second), true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("%w: UNTIL must look like 20060102, 20060102T150405 or 20060102T150405Z", ErrInvalidRule)
+}
+
+// String formats the rule the way Parse reads it, with parts in a fixed order.
+func (r Rule) String() string {
+	var parts []string
+	for name, f := range frequencies {
+		if f == r.Freq {
+			parts = append(parts, "FREQ="+name)
+		}
+	}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		if r.UntilLocal {
+			parts = append(parts, "UNTIL="+r.Until.Format("20060102T150405"))
+		} else {
+			parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+		}
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, d := range r.ByDay {
+			days[i] = weekdayName(d.Weekday)
+			if d.N != 0 {
+				days[i] = strconv.Itoa(d.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		parts = append(parts, "BYMONTHDAY="+joinInts(r.ByMonthDay))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]int, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = int(m)
+		}
+		parts = append(parts, "BYMONTH="+joinInts(months))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+weekdayName(r.WeekStart))
+	}
+	return strings.Join(parts, ";")
+}
+
+func weekdayName(d time.Weekday) string {
+	for name, w := range weekdays {
+		if w == d {
+			return name
+		}
+	}
+	return ""
+}
+
+func joinInts(values []int) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = strconv.Itoa(v)
+	}
+	return strings.Join(s, ",")
+}
+
+// Between returns occurrences of the rule starting at start that are after after and not after before, in order.
+func (r Rule) Between(start, after, before time.Time) []time.Time {
+	var result []time.Time
+	r.each(start, after, func(t time.Time) bool {
+		if t.After(before) {
+			return false
+		}
+		result = append(result, t)
+		return true
+	})
+	return result
+}
+
+// Next returns the first occurrence of the rule starting at start that is after after,
+// false if there are no more.
+func (r Rule) Next(start, after time.Time) (time.Time, bool) {
+	var next time.Time
+	r.each(start, after, func(t time.Time) bool {
+		next = t
+		return false
+	})
+	return next, !next.IsZero()
+}
+
+// each calls yield with occurrences after after, in order, until it returns false or the rule ends.
+func (r Rule) each(start, after time.Time, yield func(time.Time) bool) {
+	loc := start.Location()
+	until := r.Until
+	if r.UntilLocal {
+		until = time.Date(until.Year(), until.Month(), until.Day(), until.Hour(), until.Minute(), until.Second(), 0, loc)
+	}
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	count, empty, maxEmpty := 0, 0, r.maxEmptyPeriods()
+	for k := r.skip(start, after, interval); empty < maxEmpty; k++ {
+		days := r.expand(start, k*interval)
+		if len(days) == 0 {
+			empty++
+			continue
+		}
+		empty = 0
+
+		for _, d := range days {
+			t := time.Date(d.Year(), d.Month(), d.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+			if t.Before(start) {
+				continue
+			}
+			if !until.IsZero() && t.After(until) {
+				return
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return
+			}
+			if t.After(after) && !yield(t) {
+				return
+			}
+		}
+	}
+}
+
+// maxEmptyPeriods is how many periods in a row can go without occurrences before the rule can't have any more.
+// Rules that never match look at all of them, which is a few milliseconds of work.
+func (r Rule) maxEmptyPeriods() int {
+	switch r.Freq {
+	case Daily:
+		return cycleDays
+	case Weekly:
+		return cycleWeeks
+	case Monthly:
+		return cycleMonths
+	}
+	return cycleYears
+}
+
+// skip returns the first period that can have occurrences after after,
+// periods before it are not looked at unless occurrences have to be counted.
+func (r Rule) skip(start, after time.Time, interval int) int {
+	if r.Count > 0 || !after.After(start) {
+		return 0
+	}
+	after = after.In(start.Location())
+
+	var periods int
+	switch r.Freq {
+	case Daily:
+		periods = daysBetween(start, after)
+	case Weekly:
+		periods = daysBetween(weekOf(start, r.WeekStart), weekOf(after, r.WeekStart)) / 7
+	case Monthly:
+		periods = (after.Year()-start.Year())*12 + int(after.Month()-start.Month())
+	case Yearly:
+		periods = after.Year() - start.Year()
+	}
+	// One period back, since the wall clock time of an occurrence can be before after on the same day.
+	if k := periods/interval - 1; k > 0 {
+		return k
+	}
+	return 0
+}
+
+// expand returns the days of the period that is offset periods after the one of start, in order.
+func (r Rule) expand(start time.Time, offset int) []time.Time {
+	y, m, d := start.Date()
+	switch r.Freq {
+	case Daily:
+		day := date(y, m, d+offset)
+		if r.matches(day) {
+			return []time.Time{day}
+		}
+	case Weekly:
+		first := weekOf(start, r.WeekStart).AddDate(0, 0, 7*offset)
+		var days []time.Time
+		for i := 0; i < 7; i++ {
+			day := first.AddDate(0, 0, i)
+			if len(r.ByDay) == 0 && day.Weekday() != start.Weekday() {
+				continue
+			}
+			if r.matches(day) {
+				days = append(days, day)
+			}
+		}
+		return days
+	case Monthly:
+		month := date(y, m+time.Month(offset), 1)
+		if !r.inMonths(month.Month()) {
+			return nil
+		}
+		return r.expandMonth(month, d)
+	case Yearly:
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		sorted := append([]time.Month(nil), months...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		var days []time.Time
+		for _, month := range sorted {
+			days = append(days, r.expandMonth(date(y+offset, month, 1), d)...)
+		}
+		return days
+	}
+	return nil
+}
+
+// expandMonth returns the days of the month starting at first that match BYMONTHDAY and BYDAY,
+// or the day of the start if there are neither.
+func (r Rule) expandMonth(first time.Time, startDay int) []time.Time {
+	length := first.AddDate(0, 1, -1).Day()
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 {
+		if startDay > length {
+			return nil
+		}
+		return []time.Time{first.AddDate(0, 0, startDay-1)}
+	}
+
+	var days []time.Time
+	for i := 1; i <= length; i++ {
+		day := first.AddDate(0, 0, i-1)
+		if len(r.ByMonthDay) > 0 && !r.inMonthDays(i, length) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !r.inWeekdays(day, i, length) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// matches filters days of daily and weekly rules.
+func (r Rule) matches(day time.Time) bool {
+	if !r.inMonths(day.Month()) {
+		return false
+	}
+	length := date(day.Year(), day.Month()+1, 0).Day()
+	if len(r.ByMonthDay) > 0 && !r.inMonthDays(day.Day(), length) {
+		return false
+	}
+	if len(r.ByDay) > 0 && !r.inWeekdays(day, day.Day(), length) {
+		return false
+	}
+	return true
+}
+
+func (r Rule) inMonths(m time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, month := range r.ByMonth {
+		if month == m {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) inMonthDays(day, length int) bool {
+	for _, d := range r.ByMonthDay {
+		if d == day || (d < 0 && length+d+1 == day) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r Rule) inWeekdays(t time.Time, day, length int) bool {
+	for _, w := range r.ByDay {
+		if w.Weekday != t.Weekday() {
+			continue
+		}
+		switch {
+		case w.N == 0:
+			return true
+		case w.N > 0 && (day-1)/7+1 == w.N:
+			return true
+		case w.N < 0 && (length-day)/7+1 == -w.N:
+			return true
+		}
+	}
+	return false
+}
+
+// date is a calendar day, normalized like time.Date, kept in UTC so that days are all 24 hours long.
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// weekOf returns the first day of the week of t.
+func weekOf(t time.Time, weekStart time.Weekday) time.Time {
+	y, m, d := t.Date()
+	back := (int(t.Weekday()) - int(weekStart) + 7) % 7
+	return date(y, m, d-back)
+}
+
+func daysBetween(from, to time.Time) int {
+	y1, m1, d1 := from.Date()
+	y2, m2, d2 := to.Date()
+	return int(date(y2, m2, d2).Sub(date(y1, m1, d1)) / (24 * time.Hour))
+}
@@ -0,0 +1,215 @@
+package rrule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		rule string
+		want string // how String formats the parsed rule, empty if the rule is invalid
+	}{
+		{"FREQ=DAILY", "FREQ=DAILY"},
+		{"RRULE:freq=weekly;interval=2;byday=mo,th", "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH"},
+		{"FREQ=MONTHLY;BYDAY=-1FR", "FREQ=MONTHLY;BYDAY=-1FR"},
+		{"FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29", "FREQ=YEARLY;BYMONTHDAY=29;BYMONTH=2"},
+		{"FREQ=DAILY;UNTIL=20260131", "FREQ=DAILY;UNTIL=20260131T235959"},
+		{"FREQ=DAILY;UNTIL=20260131T100000Z", "FREQ=DAILY;UNTIL=20260131T100000Z"},
+		{"FREQ=WEEKLY;WKST=SU", "FREQ=WEEKLY;WKST=SU"},
+		{"", ""},
+		{"INTERVAL=2", ""},
+		{"FREQ=HOURLY", ""},
+		{"FREQ=DAILY;FREQ=WEEKLY", ""},
+		{"FREQ=DAILY;COUNT=3;UNTIL=20260131", ""},
+		{"FREQ=DAILY;INTERVAL=0", ""},
+		{"FREQ=DAILY;BYMONTHDAY=0", ""},
+		{"FREQ=DAILY;BYMONTHDAY=32", ""},
+		{"FREQ=WEEKLY;BYMONTHDAY=1", ""},
+		{"FREQ=WEEKLY;BYDAY=1MO", ""},
+		{"FREQ=YEARLY;BYDAY=MO", ""},
+		{"FREQ=MONTHLY;BYDAY=6MO", ""},
+		{"FREQ=DAILY;BYSETPOS=1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidRule) {
+					t.Fatalf("Parse(%q) = %v, %v, want %v", tt.rule, r, err, ErrInvalidRule)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.rule, err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("Parse(%q).String() = %q, want %q", tt.rule, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBetween(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	at := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		rule   string
+		start  time.Time
+		before time.Time // occurrences up to it, inclusive
+		want   []time.Time
+	}{
+		{
+			name:   "every day",
+			rule:   "FREQ=DAILY;COUNT=3",
+			start:  at(time.UTC, 2026, 1, 30, 9, 0),
+			before: at(time.UTC, 2027, 1, 1, 0, 0),
+			want:   []time.Time{at(time.UTC, 2026, 1, 30, 9, 0), at(time.UTC, 2026, 1, 31, 9, 0), at(time.UTC, 2026, 2, 1, 9, 0)},
+		},
+		{
+			name:   "daily across a DST change keeps the wall clock",
+			rule:   "FREQ=DAILY;COUNT=3",
+			start:  at(berlin, 2026, 3, 28, 9, 30),
+			before: at(berlin, 2027, 1, 1, 0, 0),
+			want:   []time.Time{at(berlin, 2026, 3, 28, 9, 30), at(berlin, 2026, 3, 29, 9, 30), at(berlin, 2026, 3, 30, 9, 30)},
+		},
+		{
+			name:   "leap day every day in February",
+			rule:   "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29",
+			start:  at(time.UTC, 2024, 2, 29, 10, 0),
+			before: at(time.UTC, 2032, 12, 31, 0, 0),
+			want:   []time.Time{at(time.UTC, 2024, 2, 29, 10, 0), at(time.UTC, 2028, 2, 29, 10, 0), at(time.UTC, 2032, 2, 29, 10, 0)},
+		},
+		{
+			name:   "leap day every year",
+			rule:   "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29;COUNT=2",
+			start:  at(time.UTC, 2024, 2, 29, 10, 0),
+			before: at(time.UTC, 2100, 1, 1, 0, 0),
+			want:   []time.Time{at(time.UTC, 2024, 2, 29, 10, 0), at(time.UTC, 2028, 2, 29, 10, 0)},
+		},
+		{
+			name:   "leap day skips 2100",
+			rule:   "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=29",
+			start:  at(time.UTC, 2096, 2, 29, 10, 0),
+			before: at(time.UTC, 2104, 12, 31, 0, 0),
+			want:   []time.Time{at(time.UTC, 2096, 2, 29, 10, 0), at(time.UTC, 2104, 2, 29, 10, 0)},
+		},
+		{
+			name:   "twice a week every other week",
+			rule:   "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,TH;COUNT=4",
+			start:  at(time.UTC, 2026, 1, 5, 8, 0),
+			before: at(time.UTC, 2027, 1, 1, 0, 0),
+			want: []time.Time{
+				at(time.UTC, 2026, 1, 5, 8, 0), at(time.UTC, 2026, 1, 8, 8, 0),
+				at(time.UTC, 2026, 1, 19, 8, 0), at(time.UTC, 2026, 1, 22, 8, 0),
+			},
+		},
+		{
+			name:   "last friday of the month",
+			rule:   "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start:  at(time.UTC, 2026, 1, 1, 17, 0),
+			before: at(time.UTC, 2027, 1, 1, 0, 0),
+			want:   []time.Time{at(time.UTC, 2026, 1, 30, 17, 0), at(time.UTC, 2026, 2, 27, 17, 0), at(time.UTC, 2026, 3, 27, 17, 0)},
+		},
+		{
+			name:   "31st skips shorter months",
+			rule:   "FREQ=MONTHLY;BYMONTHDAY=31;COUNT=3",
+			start:  at(time.UTC, 2026, 1, 31, 12, 0),
+			before: at(time.UTC, 2027, 1, 1, 0, 0),
+			want:   []time.Time{at(time.UTC, 2026, 1, 31, 12, 0), at(time.UTC, 2026, 3, 31, 12, 0), at(time.UTC, 2026, 5, 31, 12, 0)},
+		},
+		{
+			name:   "last day of the month",
+			rule:   "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			start:  at(time.UTC, 2024, 1, 31, 12, 0),
+			before: at(time.UTC, 2025, 1, 1, 0, 0),
+			want:   []time.Time{at(time.UTC, 2024, 1, 31, 12, 0), at(time.UTC, 2024, 2, 29, 12, 0), at(time.UTC, 2024, 3, 31, 12, 0)},
+		},
+		{
+			name:   "friday the 13th",
+			rule:   "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13;COUNT=3",
+			start:  at(time.UTC, 2026, 1, 1, 0, 0),
+			before: at(time.UTC, 2030, 1, 1, 0, 0),
+			want:   []time.Time{at(time.UTC, 2026, 2, 13, 0, 0), at(time.UTC, 2026, 3, 13, 0, 0), at(time.UTC, 2026, 11, 13, 0, 0)},
+		},
+		{
+			name:   "until a local date",
+			rule:   "FREQ=DAILY;UNTIL=20260102",
+			start:  at(berlin, 2025, 12, 31, 23, 0),
+			before: at(berlin, 2027, 1, 1, 0, 0),
+			want:   []time.Time{at(berlin, 2025, 12, 31, 23, 0), at(berlin, 2026, 1, 1, 23, 0), at(berlin, 2026, 1, 2, 23, 0)},
+		},
+		{
+			name:   "february 30th never happens",
+			rule:   "FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30",
+			start:  at(time.UTC, 2026, 1, 1, 0, 0),
+			before: at(time.UTC, 3000, 1, 1, 0, 0),
+			want:   nil,
+		},
+		{
+			name:   "february 30th never happens on any day",
+			rule:   "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=30",
+			start:  at(time.UTC, 2026, 1, 1, 0, 0),
+			before: at(time.UTC, 3000, 1, 1, 0, 0),
+			want:   nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.rule, err)
+			}
+
+			got := r.Between(tt.start, tt.start.Add(-time.Second), tt.before)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Between = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Equal(tt.want[i]) {
+					t.Errorf("occurrence %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	start := time.Date(2024, 2, 29, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		rule   string
+		after  time.Time
+		want   time.Time
+		wantOK bool
+	}{
+		{"next leap day", "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29", start, time.Date(2028, 2, 29, 10, 0, 0, 0, time.UTC), true},
+		{"far after the start", "FREQ=DAILY;BYMONTH=2;BYMONTHDAY=29", time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2104, 2, 29, 10, 0, 0, 0, time.UTC), true},
+		{"same day later", "FREQ=DAILY", start.Add(-time.Minute), start, true},
+		{"after the count", "FREQ=DAILY;COUNT=2", start.Add(24 * time.Hour), time.Time{}, false},
+		{"after until", "FREQ=DAILY;UNTIL=20240301T100000Z", start.Add(24 * time.Hour), time.Time{}, false},
+		{"never", "FREQ=MONTHLY;BYMONTH=4;BYMONTHDAY=31", start, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := Parse(tt.rule)
+			if err != nil {
+				t.Fatalf("Parse(%q) failed: %v", tt.rule, err)
+			}
+
+			got, ok := r.Next(start, tt.after)
+			if ok != tt.wantOK || !got.Equal(tt.want) {
+				t.Errorf("Next = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}